@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const defaultSessionFile = "telegram_session.json"
+
+type AccountSource struct {
+	AppID       int    `json:"app_id"`
+	AppHash     string `json:"app_hash"`
+	Phone       string `json:"phone,omitempty"`
+	Bot         string `json:"bot"`
+	SessionFile string `json:"session_file,omitempty"`
+}
+
+func (a *AccountSource) validate() error {
+	switch {
+	case a.AppID == 0 || a.AppHash == "":
+		return errors.New("account: нужны app_id и app_hash (https://my.telegram.org/apps)")
+	case strings.TrimPrefix(a.Bot, "@") == "":
+		return errors.New("account: не указан bot — имя пользователя бота рынка")
+	}
+	a.Bot = strings.TrimPrefix(a.Bot, "@")
+	if a.SessionFile == "" {
+		a.SessionFile = defaultSessionFile
+	}
+	return nil
+}
+
+func cmdAccount(args []string) error {
+	fs := newFlagSet("account")
+	full := fs.Bool("full", false, "прочитать всю историю заново, не только новые сообщения")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Account == nil {
+		return errors.New("в config.json не настроен раздел account")
+	}
+	ctx, stop := deferShutdown()
+	defer stop()
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	cursor := st.AccountCursor
+	if *full {
+		cursor = 0
+	}
+	msgs, last, err := fetchAccountHistory(ctx, cfg.Account, cfg.parsers(), cursor)
+	if ctx.Err() != nil {
+		return errors.New("чтение истории прервано, курсор не сдвинут")
+	}
+	if err != nil {
+		return err
+	}
+	if err := appendLiveMessages(msgs); err != nil {
+		return fmt.Errorf("не удалось записать %s: %w", liveMessagesFile, err)
+	}
+	if last > st.AccountCursor {
+		st.AccountCursor = last
+		if err := st.save(); err != nil {
+			return err
+		}
+	}
+	printLiveMessages(msgs, cfg)
+	if len(msgs) > 0 {
+		notifyGoals(cfg)
+	}
+	fmt.Fprintf(out, "Получено сообщений о сделках: %d\n", len(msgs))
+	return nil
+}
@@ -0,0 +1,130 @@
+//go:build mtproto
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gotd/td/session"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/tg"
+)
+
+const historyPageSize = 100
+
+type terminalAuth struct{ phone string }
+
+func (a terminalAuth) Phone(context.Context) (string, error) {
+	if a.phone != "" {
+		return a.phone, nil
+	}
+	return promptLine("Номер телефона аккаунта Telegram: ")
+}
+
+func (terminalAuth) Password(context.Context) (string, error) {
+	return promptLine("Пароль двухэтапной проверки: ")
+}
+
+func (terminalAuth) Code(context.Context, *tg.AuthSentCode) (string, error) {
+	return promptLine("Код подтверждения из Telegram: ")
+}
+
+func (terminalAuth) AcceptTermsOfService(context.Context, tg.HelpTermsOfService) error {
+	return errors.New("аккаунт не зарегистрирован: сначала войдите в официальный клиент Telegram")
+}
+
+func (terminalAuth) SignUp(context.Context) (auth.UserInfo, error) {
+	return auth.UserInfo{}, errors.New("аккаунт не зарегистрирован: сначала войдите в официальный клиент Telegram")
+}
+
+func promptLine(prompt string) (string, error) {
+	fmt.Fprint(out, prompt)
+	line, ok := readLine()
+	if !ok || line == "" {
+		return "", errors.New("ввод прерван")
+	}
+	return line, nil
+}
+
+func fetchAccountHistory(ctx context.Context, a *AccountSource, parsers parserSet, cursor int64) ([]liveMessage, int64, error) {
+	client := telegram.NewClient(a.AppID, a.AppHash, telegram.Options{
+		SessionStorage: &session.FileStorage{Path: a.SessionFile},
+	})
+	var msgs []liveMessage
+	last := cursor
+	err := client.Run(ctx, func(ctx context.Context) error {
+		flow := auth.NewFlow(terminalAuth{phone: a.Phone}, auth.SendCodeOptions{})
+		if err := client.Auth().IfNecessary(ctx, flow); err != nil {
+			return fmt.Errorf("вход в Telegram: %w", err)
+		}
+		api := client.API()
+		resolved, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: a.Bot})
+		if err != nil {
+			return fmt.Errorf("не удалось найти @%s: %w", a.Bot, err)
+		}
+		var peer tg.InputPeerClass
+		for _, u := range resolved.Users {
+			if user, ok := u.(*tg.User); ok {
+				peer = &tg.InputPeerUser{UserID: user.ID, AccessHash: user.AccessHash}
+				break
+			}
+		}
+		if peer == nil {
+			return fmt.Errorf("@%s не является пользователем или ботом", a.Bot)
+		}
+
+		offset := 0
+		for {
+			res, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+				Peer:     peer,
+				OffsetID: offset,
+				Limit:    historyPageSize,
+				MinID:    int(cursor),
+			})
+			if err != nil {
+				return fmt.Errorf("не удалось прочитать историю @%s: %w", a.Bot, err)
+			}
+			page, ok := res.(tg.ModifiedMessagesMessages)
+			if !ok {
+				return nil
+			}
+			batch := page.GetMessages()
+			for _, m := range batch {
+				msg, ok := m.(*tg.Message)
+				if !ok {
+					continue
+				}
+				if id := int64(msg.ID); id > last {
+					last = id
+				}
+				if offset == 0 || msg.ID < offset {
+					offset = msg.ID
+				}
+				if parsers.match([]byte(msg.Message)) {
+					msgs = append(msgs, liveMessage{ID: int64(msg.ID), Chat: resolvedChatID(peer), Time: time.Unix(int64(msg.Date), 0), Text: msg.Message})
+				}
+			}
+			if len(batch) < historyPageSize {
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, cursor, err
+	}
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs, last, nil
+}
+
+func resolvedChatID(peer tg.InputPeerClass) int64 {
+	if u, ok := peer.(*tg.InputPeerUser); ok {
+		return u.UserID
+	}
+	return 0
+}
@@ -0,0 +1,12 @@
+//go:build !mtproto
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+func fetchAccountHistory(ctx context.Context, a *AccountSource, parsers parserSet, cursor int64) ([]liveMessage, int64, error) {
+	return nil, 0, errors.New("программа собрана без поддержки MTProto; соберите её с тегом: go build -tags mtproto")
+}
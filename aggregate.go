@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func salesRange(sales []Sale) (first, last time.Time, ok bool) {
+	for i, s := range sales {
+		if i == 0 || s.Time.Before(first) {
+			first = s.Time
+		}
+		if i == 0 || s.Time.After(last) {
+			last = s.Time
+		}
+	}
+	return first, last, len(sales) > 0
+}
+
+func extendRange(first, last time.Time, ok bool, t time.Time) (time.Time, time.Time, bool) {
+	if !ok || t.Before(first) {
+		first = t
+	}
+	if !ok || t.After(last) {
+		last = t
+	}
+	return first, last, true
+}
+
+var (
+	asOfLayouts     = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "02.01.2006 15:04:05"}
+	asOfDateLayouts = []string{"2006-01-02", "02.01.2006"}
+)
+
+func parseAsOf(value string) (time.Time, error) {
+	for _, layout := range asOfLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range asOfDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("не удалось разобрать момент времени %q", value)
+}
+
+func salesAsOf(sales []Sale, asOf time.Time) []Sale {
+	var res []Sale
+	for _, s := range sales {
+		if !s.Time.After(asOf) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+func purchasesAsOf(purchases []Purchase, asOf time.Time) []Purchase {
+	var res []Purchase
+	for _, p := range purchases {
+		if !p.Time.After(asOf) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func addPurchases(servers map[string]*Server, purchases []Purchase, now time.Time, per period) {
+	for _, p := range purchases {
+		if !per.contains(p.Time, now) {
+			continue
+		}
+		ch := ensureCharacter(servers, p.Server, p.Character, p.Time)
+		if ch.Bought == nil {
+			ch.Bought = make(map[string]*ItemStats)
+		}
+		st := ch.Bought[p.Item]
+		if st == nil {
+			st = &ItemStats{}
+			ch.Bought[p.Item] = st
+		}
+		st.Count += p.Quantity
+		st.Sum += p.Price
+		ch.Spent += p.Price
+	}
+}
+
+func ensureCharacter(servers map[string]*Server, server, character string, t time.Time) *Character {
+	namePart, idPart := splitCharacter(character)
+	if idPart == "" {
+		idPart = namePart
+	}
+	srv := servers[server]
+	if srv == nil {
+		srv = &Server{Name: server, Characters: make(map[string]*Character)}
+		servers[server] = srv
+	}
+	ch := srv.Characters[idPart]
+	if ch == nil {
+		ch = &Character{ID: idPart, Name: namePart, FirstSeen: t, LastSeen: t, Items: make(map[string]*ItemStats), Days: make(map[string]bool)}
+		srv.Characters[idPart] = ch
+	}
+	return ch
+}
+
+func aggregateSales(sales []Sale, now time.Time, p period) map[string]*Server {
+	servers := make(map[string]*Server)
+	for _, s := range sales {
+		if p.contains(s.Time, now) {
+			aggregateSale(servers, s)
+		}
+	}
+	return servers
+}
+
+func aggregateSale(servers map[string]*Server, s Sale) {
+	namePart, idPart := splitCharacter(s.Character)
+	if idPart == "" {
+		idPart = namePart
+	}
+
+	srv := servers[s.Server]
+	if srv == nil {
+		srv = &Server{Name: s.Server, Characters: make(map[string]*Character)}
+		servers[s.Server] = srv
+	}
+
+	ch := srv.Characters[idPart]
+	if ch == nil {
+		ch = &Character{ID: idPart, Name: namePart, FirstSeen: s.Time, LastSeen: s.Time, Items: make(map[string]*ItemStats), Days: make(map[string]bool)}
+		srv.Characters[idPart] = ch
+	} else if s.Time.After(ch.LastSeen) {
+		ch.Name = namePart
+		ch.LastSeen = s.Time
+	}
+	if s.Time.Before(ch.FirstSeen) {
+		ch.FirstSeen = s.Time
+	}
+
+	ch.Sales++
+	if s.Owner != "" {
+		ch.Foreign += s.Price
+	}
+	ch.Days[s.Time.Format("2006-01-02")] = true
+
+	stats := ch.Items[s.Item]
+	if stats == nil {
+		stats = &ItemStats{}
+		ch.Items[s.Item] = stats
+	}
+	stats.Count += s.Quantity
+	stats.Sum += s.Price
+	stats.Fees += s.Fee
+	if s.Quality != "" {
+		if stats.Qualities == nil {
+			stats.Qualities = make(map[string]*ItemStats)
+		}
+		q := stats.Qualities[s.Quality]
+		if q == nil {
+			q = &ItemStats{}
+			stats.Qualities[s.Quality] = q
+		}
+		q.Count += s.Quantity
+		q.Sum += s.Price
+		q.Fees += s.Fee
+	}
+}
+
+func (d *ItemStats) average() float64 {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.Sum.Float() / float64(d.Count)
+}
+
+func (ch *Character) Revenue() Money {
+	var sum Money
+	for _, d := range ch.Items {
+		sum += d.Sum
+	}
+	return sum
+}
+
+func (ch *Character) Fees() Money {
+	var sum Money
+	for _, d := range ch.Items {
+		sum += d.Fees
+	}
+	return sum
+}
+
+func sortedServerKeys(m map[string]*Server, lang string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sortNames(keys, lang)
+	return keys
+}
+
+func sortedCharIDs(srv *Server, lang string) []string {
+	keys := make([]string, 0, len(srv.Characters))
+	for id := range srv.Characters {
+		keys = append(keys, id)
+	}
+	sort.Strings(keys)
+	sortByName(keys, lang, func(id string) string { return srv.Characters[id].Name })
+	return keys
+}
+
+func splitCharacter(full string) (name, id string) {
+	if i := strings.LastIndex(full, "#"); i != -1 {
+		name = strings.TrimSpace(full[:i])
+		id = strings.TrimSpace(full[i+1:])
+	} else {
+		name = strings.TrimSpace(full)
+	}
+	return
+}
+
+func (s Sale) unitPrice() float64 {
+	return s.Price.Float() / float64(s.Quantity)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	aliasPriceRatio    = 3.0
+	renameMaxGap       = 14 * 24 * time.Hour
+	renameMaxPriceDiff = 0.15
+	renameMinSales     = 3
+)
+
+type priceDistribution struct {
+	Source string
+	Sales  int
+	Min    float64
+	Median float64
+	Max    float64
+}
+
+func aliasDistributions(sales []Sale) map[string][]priceDistribution {
+	prices := make(map[string]map[string][]float64)
+	for _, s := range sales {
+		if s.Quantity <= 0 {
+			continue
+		}
+		raw := s.RawItem
+		if raw == "" {
+			raw = s.Item
+		}
+		if prices[s.Item] == nil {
+			prices[s.Item] = make(map[string][]float64)
+		}
+		prices[s.Item][raw] = append(prices[s.Item][raw], s.unitPrice())
+	}
+
+	res := make(map[string][]priceDistribution)
+	for item, bySource := range prices {
+		if len(bySource) < 2 {
+			continue
+		}
+		for src, list := range bySource {
+			sort.Float64s(list)
+			res[item] = append(res[item], priceDistribution{
+				Source: src,
+				Sales:  len(list),
+				Min:    list[0],
+				Median: list[len(list)/2],
+				Max:    list[len(list)-1],
+			})
+		}
+		sort.Slice(res[item], func(i, j int) bool { return res[item][i].Source < res[item][j].Source })
+	}
+	return res
+}
+
+func printAliasCheck(sales []Sale, lang string) {
+	dists := aliasDistributions(sales)
+	if len(dists) == 0 {
+		return
+	}
+	items := make([]string, 0, len(dists))
+	for item := range dists {
+		items = append(items, item)
+	}
+	sortNames(items, lang)
+
+	fmt.Fprintln(out, "\nПроверка синонимов (цена за штуку по исходным названиям):")
+	for _, item := range items {
+		list := dists[item]
+		fmt.Fprintf(out, "  %s:\n", item)
+		w := newTable()
+		fmt.Fprintln(w, "    Исходное название\tПродаж\tМин.\tМедиана\tМакс.")
+		lo, hi := list[0].Median, list[0].Median
+		for _, d := range list {
+			fmt.Fprintf(w, "    %s\t%d\t$%.2f\t$%.2f\t$%.2f\n", d.Source, d.Sales, d.Min, d.Median, d.Max)
+			if d.Median < lo {
+				lo = d.Median
+			}
+			if d.Median > hi {
+				hi = d.Median
+			}
+		}
+		w.Flush()
+		if lo > 0 && hi/lo > aliasPriceRatio {
+			fmt.Fprintf(out, "    ! медианные цены различаются в %.1f раза — возможно, синоним объединяет разные предметы\n", hi/lo)
+		}
+	}
+}
+
+type itemSpan struct {
+	Item   string
+	First  time.Time
+	Last   time.Time
+	Sales  int
+	Median float64
+}
+
+type renameSuggestion struct {
+	Old itemSpan
+	New itemSpan
+}
+
+func itemSpans(sales []Sale) []itemSpan {
+	spans := make(map[string]*itemSpan)
+	prices := make(map[string][]float64)
+	for _, s := range sales {
+		if s.Quantity <= 0 {
+			continue
+		}
+		sp := spans[s.Item]
+		if sp == nil {
+			sp = &itemSpan{Item: s.Item, First: s.Time, Last: s.Time}
+			spans[s.Item] = sp
+		}
+		if s.Time.Before(sp.First) {
+			sp.First = s.Time
+		}
+		if s.Time.After(sp.Last) {
+			sp.Last = s.Time
+		}
+		sp.Sales++
+		prices[s.Item] = append(prices[s.Item], s.unitPrice())
+	}
+	res := make([]itemSpan, 0, len(spans))
+	for item, sp := range spans {
+		list := prices[item]
+		sort.Float64s(list)
+		sp.Median = list[len(list)/2]
+		res = append(res, *sp)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].First.Before(res[j].First) })
+	return res
+}
+
+func suggestRenames(sales []Sale) []renameSuggestion {
+	spans := itemSpans(sales)
+	used := make(map[string]bool)
+	var res []renameSuggestion
+	for _, old := range spans {
+		if old.Sales < renameMinSales {
+			continue
+		}
+		best := -1
+		for i, cand := range spans {
+			gap := cand.First.Sub(old.Last)
+			if cand.Item == old.Item || used[cand.Item] || cand.Sales < renameMinSales || gap <= 0 || gap > renameMaxGap {
+				continue
+			}
+			hi := max(old.Median, cand.Median)
+			if hi <= 0 || (hi-min(old.Median, cand.Median))/hi > renameMaxPriceDiff {
+				continue
+			}
+			if best < 0 || gap < spans[best].First.Sub(old.Last) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			used[spans[best].Item] = true
+			res = append(res, renameSuggestion{Old: old, New: spans[best]})
+		}
+	}
+	return res
+}
+
+func printRenameSuggestions(sales []Sale, lang string) {
+	suggestions := suggestRenames(sales)
+	if len(suggestions) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "\nВозможные переименования (продажи одного предмета прекратились, когда начались продажи другого по близкой цене):")
+	w := newTable()
+	fmt.Fprintln(w, "  Было\tПоследняя продажа\tМедиана\tСтало\tПервая продажа\tМедиана")
+	for _, r := range suggestions {
+		fmt.Fprintf(w, "  %s\t%s\t$%.2f\t%s\t%s\t$%.2f\n", r.Old.Item, formatDate(r.Old.Last, lang), r.Old.Median, r.New.Item, formatDate(r.New.First, lang), r.New.Median)
+	}
+	w.Flush()
+	fmt.Fprintln(out, "  Если это один предмет, объедините названия:")
+	for _, r := range suggestions {
+		fmt.Fprintf(out, "    market config add-alias %q %q\n", r.Old.Item, r.New.Item)
+	}
+}
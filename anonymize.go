@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+func anonymizeSales(sales []Sale, salt string) []Sale {
+	res := make([]Sale, len(sales))
+	for i, s := range sales {
+		s.Character = anonymousCharacter(salt, s.Server, s.Character)
+		if s.Counterparty != "" {
+			s.Counterparty = anonymousCharacter(salt, s.Server, s.Counterparty)
+		}
+		res[i] = s
+	}
+	return res
+}
+
+func anonymizePurchases(purchases []Purchase, salt string) []Purchase {
+	res := make([]Purchase, len(purchases))
+	for i, p := range purchases {
+		p.Character = anonymousCharacter(salt, p.Server, p.Character)
+		res[i] = p
+	}
+	return res
+}
+
+func anonymousCharacter(salt, server, character string) string {
+	name, id := splitCharacter(character)
+	if id == "" {
+		id = name
+	}
+	h := pseudonym(salt, server+"/"+id)
+	return fmt.Sprintf("Персонаж-%s #%s", h[:6], h[6:14])
+}
+
+func pseudonym(salt, value string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func anonymizeSalt(cfg *Config, st *appState) (string, error) {
+	if cfg.AnonymizeSalt != "" {
+		return cfg.AnonymizeSalt, nil
+	}
+	if st.AnonymizeSalt == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		st.AnonymizeSalt = hex.EncodeToString(b)
+	}
+	return st.AnonymizeSalt, nil
+}
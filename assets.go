@@ -0,0 +1,6 @@
+package main
+
+import "embed"
+
+//go:embed assets
+var assets embed.FS
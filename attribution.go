@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const ownOwner = "свои"
+
+type AttributionRule struct {
+	Owner     string   `json:"owner"`
+	Server    string   `json:"server,omitempty"`
+	Character string   `json:"character,omitempty"`
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	Items     []string `json:"items,omitempty"`
+
+	from, to time.Time
+	items    map[string]bool
+}
+
+func (r *AttributionRule) validate(cfg *Config) error {
+	if r.Owner == "" {
+		return errors.New("не указан owner")
+	}
+	if r.From != "" {
+		t, err := time.ParseInLocation("2006-01-02", r.From, time.Local)
+		if err != nil {
+			return fmt.Errorf("дата from %q не в формате 2006-01-02", r.From)
+		}
+		r.from = t
+	}
+	if r.To != "" {
+		t, err := time.ParseInLocation("2006-01-02", r.To, time.Local)
+		if err != nil {
+			return fmt.Errorf("дата to %q не в формате 2006-01-02", r.To)
+		}
+		r.to = t.AddDate(0, 0, 1)
+	}
+	if len(r.Items) > 0 {
+		r.items = make(map[string]bool, len(r.Items))
+		for _, it := range r.Items {
+			r.items[cfg.canonicalItem(it)] = true
+		}
+	}
+	return nil
+}
+
+func (r *AttributionRule) matches(s Sale) bool {
+	if r.Server != "" && r.Server != s.Server {
+		return false
+	}
+	if r.Character != "" {
+		if _, id := splitCharacter(s.Character); id != r.Character {
+			return false
+		}
+	}
+	if !r.from.IsZero() && s.Time.Before(r.from) {
+		return false
+	}
+	if !r.to.IsZero() && !s.Time.Before(r.to) {
+		return false
+	}
+	return r.items == nil || r.items[s.Item]
+}
+
+func attributeSales(cfg *Config, sales []Sale) {
+	for i := range sales {
+		attributeSale(cfg, &sales[i])
+	}
+}
+
+func attributeSale(cfg *Config, s *Sale) {
+	s.Owner = ""
+	for j := range cfg.Attribution {
+		if cfg.Attribution[j].matches(*s) {
+			s.Owner = cfg.Attribution[j].Owner
+			break
+		}
+	}
+}
+
+func printOwnershipTotals(cfg *Config, sales []Sale) {
+	if len(cfg.Attribution) == 0 {
+		return
+	}
+	byOwner := make(map[string]*ItemStats)
+	for _, s := range sales {
+		owner := s.Owner
+		if owner == "" {
+			owner = ownOwner
+		}
+		st := byOwner[owner]
+		if st == nil {
+			st = &ItemStats{}
+			byOwner[owner] = st
+		}
+		st.Count++
+		st.Sum += s.Price
+	}
+	owners := make([]string, 0, len(byOwner))
+	for o := range byOwner {
+		owners = append(owners, o)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		if (owners[i] == ownOwner) != (owners[j] == ownOwner) {
+			return owners[i] == ownOwner
+		}
+		return owners[i] < owners[j]
+	})
+
+	fmt.Fprintln(out, "\nВыручка с учётом владельцев товара:")
+	w := newTable()
+	fmt.Fprintln(w, "    Владелец\tПродаж\tВыручка")
+	for _, o := range owners {
+		fmt.Fprintf(w, "    %s\t%d\t$%.2f\n", o, byOwner[o].Count, byOwner[o].Sum)
+	}
+	w.Flush()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	backupManifestFile = "backup.json"
+	backupSalesDBEntry = "sales_db.sqlite"
+)
+
+var backupFiles = []string{
+	configPath,
+	stateFile,
+	correctionsFile,
+	liveMessagesFile,
+	salesLedgerFile,
+	ingestLogFile,
+	parseCacheFile,
+	exportCacheFile,
+	marketPricesCacheFile,
+	salesMonthlyFile,
+	purgeLogFile,
+	boltStoreFile,
+}
+
+type backupManifest struct {
+	Created time.Time `json:"created"`
+	Machine string    `json:"machine,omitempty"`
+	Files   []string  `json:"files"`
+	SalesDB string    `json:"sales_db,omitempty"`
+}
+
+func cmdBackup(args []string) error {
+	fs := newFlagSet("backup")
+	outPath := fs.String("out", "", "путь к архиву (по умолчанию market-backup-ДАТА-ВРЕМЯ.zip)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("использование: market backup [--out ФАЙЛ]")
+	}
+
+	cfg, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	path := *outPath
+	if path == "" {
+		path = "market-backup-" + now.Format("2006-01-02-1504") + ".zip"
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	man, err := writeBackup(tmp, cfg, now)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Резервная копия %s: файлов %d, %s\n", path, len(man.Files), formatBytes(info.Size()))
+	for _, name := range man.Files {
+		fmt.Fprintf(out, " - %s\n", name)
+	}
+	if isPostgresDSN(cfg.SalesDB) {
+		fmt.Fprintf(out, "База продаж %s хранится на сервере PostgreSQL и в копию не входит\n", salesDBName(cfg.SalesDB))
+	}
+	return nil
+}
+
+func writeBackup(w io.Writer, cfg *Config, now time.Time) (*backupManifest, error) {
+	zw := zip.NewWriter(w)
+	man := &backupManifest{Created: now, Machine: machineName()}
+
+	add := func(name string, modTime time.Time, write func(io.Writer) error) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+		if err != nil {
+			return err
+		}
+		if err := write(fw); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		man.Files = append(man.Files, name)
+		return nil
+	}
+
+	for _, name := range backupFiles {
+		info, err := os.Stat(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		write := func(w io.Writer) error { return copyFileTo(w, name) }
+		if name == boltStoreFile {
+			write = snapshotBolt
+		}
+		if err := add(name, info.ModTime(), write); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.SalesDB != "" && !isPostgresDSN(cfg.SalesDB) {
+		if info, err := os.Stat(cfg.SalesDB); err == nil {
+			if err := add(backupSalesDBEntry, info.ModTime(), func(w io.Writer) error { return snapshotSalesDB(w, cfg.SalesDB) }); err != nil {
+				return nil, err
+			}
+			man.SalesDB = cfg.SalesDB
+		}
+	}
+	if len(man.Files) == 0 {
+		zw.Close()
+		return nil, errors.New("в текущей папке нет данных market для резервной копии")
+	}
+
+	fw, err := zw.Create(backupManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(man); err != nil {
+		return nil, err
+	}
+	return man, zw.Close()
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func snapshotBolt(w io.Writer) error {
+	db, err := boltStorage{path: boltStoreFile}.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+func snapshotSalesDB(w io.Writer, path string) error {
+	db, err := openSalesDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	dir, err := os.MkdirTemp("", "market-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	snap := filepath.Join(dir, backupSalesDBEntry)
+	if _, err := db.Exec("VACUUM INTO ?", snap); err != nil {
+		return err
+	}
+	return copyFileTo(w, snap)
+}
+
+func cmdRestore(args []string) error {
+	fs := newFlagSet("restore")
+	yes := fs.Bool("yes", false, "не спрашивать подтверждение перед перезаписью")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("использование: market restore [--yes] АРХИВ")
+	}
+	archive := fs.Arg(0)
+
+	if conn, err := net.DialTimeout("unix", daemonSocket, daemonDialTimeout); err == nil {
+		conn.Close()
+		return withKind(ErrStoreLocked, errors.New("в этой папке запущен демон — остановите его перед восстановлением"))
+	}
+
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть %s: %w", archive, err)
+	}
+	defer zr.Close()
+
+	var man backupManifest
+	entries := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		switch {
+		case f.Name == backupManifestFile:
+			if err := readZipJSON(f, &man); err != nil {
+				return fmt.Errorf("%s: %s: %w", archive, backupManifestFile, err)
+			}
+		case f.Name == backupSalesDBEntry || slices.Contains(backupFiles, f.Name):
+			entries[f.Name] = f
+		default:
+			fmt.Fprintf(out, "Пропущен неизвестный файл %s\n", f.Name)
+		}
+	}
+	if man.Created.IsZero() || len(entries) == 0 {
+		return fmt.Errorf("%s не похож на резервную копию market", archive)
+	}
+
+	salesDB := ""
+	if f, ok := entries[backupSalesDBEntry]; ok {
+		var cfg Config
+		if cf, ok := entries[configPath]; ok {
+			if err := readZipJSON(cf, &cfg); err != nil {
+				return fmt.Errorf("%s: %s: %w", archive, configPath, err)
+			}
+		}
+		salesDB = cfg.SalesDB
+		if salesDB == "" || isPostgresDSN(salesDB) {
+			salesDB = man.SalesDB
+		}
+		if salesDB == "" {
+			delete(entries, f.Name)
+		}
+	}
+	target := func(name string) string {
+		if name == backupSalesDBEntry {
+			return salesDB
+		}
+		return name
+	}
+
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	fmt.Fprintf(out, "Резервная копия от %s", man.Created.Local().Format("2006-01-02 15:04"))
+	if man.Machine != "" {
+		fmt.Fprintf(out, " (компьютер %s)", man.Machine)
+	}
+	fmt.Fprintln(out)
+	var existing []string
+	for _, name := range names {
+		if fileExists(target(name)) {
+			existing = append(existing, target(name))
+		}
+	}
+	if len(existing) > 0 && !*yes {
+		fmt.Fprintln(out, "Будут перезаписаны:")
+		for _, p := range existing {
+			fmt.Fprintf(out, " - %s\n", p)
+		}
+		if !confirm(fmt.Sprintf("Заменить %d файлов данными из копии?", len(existing))) {
+			fmt.Fprintln(out, "Отменено")
+			return nil
+		}
+	}
+
+	for _, name := range names {
+		path := target(name)
+		if err := restoreZipFile(entries[name], path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if name == backupSalesDBEntry {
+			for _, suffix := range []string{"-wal", "-shm"} {
+				if err := os.Remove(path + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+			}
+		}
+		fmt.Fprintf(out, " + %s\n", path)
+	}
+	fmt.Fprintf(out, "Восстановлено файлов: %d. Проверьте base_dir и site_dir в %s — на новом компьютере пути могут отличаться.\n", len(names), configPath)
+	return nil
+}
+
+func readZipJSON(f *zip.File, v any) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(rc).Decode(v)
+}
+
+func restoreZipFile(f *zip.File, path string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const defaultTopBuyers = 10
+
+type buyerStats struct {
+	Name     string
+	Count    int
+	Quantity int
+	Spent    Money
+}
+
+func topBuyers(sales []Sale) map[string][]*buyerStats {
+	byServer := make(map[string]map[string]*buyerStats)
+	for _, s := range sales {
+		if s.Counterparty == "" {
+			continue
+		}
+		buyers := byServer[s.Server]
+		if buyers == nil {
+			buyers = make(map[string]*buyerStats)
+			byServer[s.Server] = buyers
+		}
+		b := buyers[s.Counterparty]
+		if b == nil {
+			b = &buyerStats{Name: s.Counterparty}
+			buyers[s.Counterparty] = b
+		}
+		b.Count++
+		b.Quantity += s.Quantity
+		b.Spent += s.Price
+	}
+
+	res := make(map[string][]*buyerStats, len(byServer))
+	for srv, buyers := range byServer {
+		rows := make([]*buyerStats, 0, len(buyers))
+		for _, b := range buyers {
+			rows = append(rows, b)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].Count != rows[j].Count {
+				return rows[i].Count > rows[j].Count
+			}
+			if rows[i].Spent != rows[j].Spent {
+				return rows[i].Spent > rows[j].Spent
+			}
+			return rows[i].Name < rows[j].Name
+		})
+		res[srv] = rows
+	}
+	return res
+}
+
+func printTopBuyers(cfg *Config, sales []Sale) {
+	byServer := topBuyers(sales)
+	if len(byServer) == 0 {
+		return
+	}
+	limit := cfg.TopN
+	if limit <= 0 {
+		limit = defaultTopBuyers
+	}
+	servers := make([]string, 0, len(byServer))
+	for srv := range byServer {
+		servers = append(servers, srv)
+	}
+	sortNames(servers, cfg.Language)
+	for _, srv := range servers {
+		fmt.Fprintf(out, "\nПостоянные покупатели, сервер %s:\n", srv)
+		shown, hidden := topRows(byServer[srv], limit)
+		w := newTable()
+		fmt.Fprintln(w, "#\tПокупатель\tПокупок\tШтук\tПотрачено")
+		for i, b := range shown {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t$%.2f\n", i+1, b.Name, b.Count, b.Quantity, b.Spent)
+		}
+		w.Flush()
+		printMoreRows(hidden, cfg)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var ErrTimeout = errors.New("превышено время ожидания")
+
+var ioTimeout time.Duration
+
+func withReportTimeout(ctx context.Context, cfg *Config) (context.Context, context.CancelFunc) {
+	if cfg.reportTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	cause := withKind(ErrTimeout, fmt.Errorf("работа не уложилась в report_timeout (%s) и остановлена", cfg.ReportTimeout))
+	return context.WithTimeoutCause(ctx, cfg.reportTimeout, cause)
+}
+
+func runContext(cfg *Config) (context.Context, context.CancelFunc) {
+	ctx, stop := deferShutdown()
+	ctx, cancel := withReportTimeout(ctx, cfg)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+func ctxErr(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrTimeout) {
+		return cause
+	}
+	return errInterrupted
+}
+
+func failCode(ctx context.Context, err error, code int) (int, error) {
+	if cerr := ctxErr(ctx); cerr != nil {
+		return exitCode(cerr), cerr
+	}
+	if errors.Is(err, ErrTimeout) {
+		return exitTimeout, err
+	}
+	return code, err
+}
+
+func guardIO[T any](ctx context.Context, what string, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := ctxErr(ctx); err != nil {
+		return zero, err
+	}
+	if ctx.Done() == nil && ioTimeout <= 0 {
+		return fn()
+	}
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v, err}
+	}()
+	var expired <-chan time.Time
+	if ioTimeout > 0 {
+		t := time.NewTimer(ioTimeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-ctx.Done():
+		return zero, ctxErr(ctx)
+	case <-expired:
+		return zero, withKind(ErrTimeout, fmt.Errorf("%s: нет ответа за %s (io_timeout)", what, ioTimeout))
+	}
+}
+
+type ctxReader struct {
+	ctx  context.Context
+	r    io.Reader
+	name string
+	buf  []byte
+}
+
+func newCtxReader(ctx context.Context, r io.Reader, name string) io.Reader {
+	if ctx.Done() == nil && ioTimeout <= 0 {
+		return r
+	}
+	return &ctxReader{ctx: ctx, r: r, name: name}
+}
+
+func (cr *ctxReader) Read(p []byte) (int, error) {
+	if cap(cr.buf) < len(p) {
+		cr.buf = make([]byte, len(p))
+	}
+	buf := cr.buf[:len(p)]
+	n, err := guardIO(cr.ctx, cr.name, func() (int, error) { return cr.r.Read(buf) })
+	if errors.Is(err, ErrTimeout) || errors.Is(err, errInterrupted) {
+		cr.r, cr.buf = failedReader{err}, nil
+		return 0, err
+	}
+	return copy(p, buf[:n]), err
+}
+
+type failedReader struct{ err error }
+
+func (f failedReader) Read([]byte) (int, error) { return 0, f.err }
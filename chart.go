@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth  = 60
+	chartHeight = 12
+)
+
+type pricePoint struct {
+	time  time.Time
+	price float64
+}
+
+type chartView struct {
+	points   []pricePoint
+	min, max time.Time
+	from, to time.Time
+	cursor   int
+}
+
+func (v *chartView) columnRange(col int) (time.Time, time.Time) {
+	step := v.to.Sub(v.from) / chartWidth
+	start := v.from.Add(time.Duration(col) * step)
+	return start, start.Add(step)
+}
+
+func (v *chartView) columns() ([chartWidth]float64, [chartWidth]int) {
+	var sums [chartWidth]float64
+	var counts [chartWidth]int
+	span := v.to.Sub(v.from)
+	for _, p := range v.points {
+		if p.time.Before(v.from) || !p.time.Before(v.to) {
+			continue
+		}
+		col := int(float64(p.time.Sub(v.from)) / float64(span) * chartWidth)
+		col = min(max(col, 0), chartWidth-1)
+		sums[col] += p.price
+		counts[col]++
+	}
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= float64(counts[i])
+		}
+	}
+	return sums, counts
+}
+
+func (v *chartView) zoom(factor float64) {
+	center, _ := v.columnRange(v.cursor)
+	span := time.Duration(float64(v.to.Sub(v.from)) * factor)
+	full := v.max.Sub(v.min)
+	if span >= full {
+		v.from, v.to = v.min, v.max
+		return
+	}
+	span = max(span, chartWidth*time.Minute)
+	v.from = center.Add(-span / 2)
+	v.to = v.from.Add(span)
+	v.pan(0)
+}
+
+func (v *chartView) pan(fraction float64) {
+	span := v.to.Sub(v.from)
+	shift := time.Duration(float64(span) * fraction)
+	v.from, v.to = v.from.Add(shift), v.to.Add(shift)
+	if v.from.Before(v.min) {
+		v.from, v.to = v.min, v.min.Add(span)
+	}
+	if v.to.After(v.max) {
+		v.from, v.to = v.max.Add(-span), v.max
+	}
+}
+
+func (v *chartView) render(item string, lang string) {
+	avg, counts := v.columns()
+	lo, hi := 0.0, 0.0
+	first := true
+	for i, c := range counts {
+		if c == 0 {
+			continue
+		}
+		if first || avg[i] < lo {
+			lo = avg[i]
+		}
+		if first || avg[i] > hi {
+			hi = avg[i]
+		}
+		first = false
+	}
+	if hi == lo {
+		hi, lo = hi+1, max(lo-1, 0)
+	}
+
+	fmt.Fprintf(out, "\nИстория цены за штуку: %s\n", item)
+	for row := chartHeight - 1; row >= 0; row-- {
+		label := ""
+		switch row {
+		case chartHeight - 1:
+			label = fmt.Sprintf("$%.0f", hi)
+		case 0:
+			label = fmt.Sprintf("$%.0f", lo)
+		case chartHeight / 2:
+			label = fmt.Sprintf("$%.0f", (hi+lo)/2)
+		}
+		var line strings.Builder
+		for col := range chartWidth {
+			level := -1
+			if counts[col] > 0 {
+				level = int((avg[col] - lo) / (hi - lo) * (chartHeight - 1))
+			}
+			switch {
+			case level == row && col == v.cursor:
+				line.WriteByte('@')
+			case level == row:
+				line.WriteByte('*')
+			case col == v.cursor:
+				line.WriteByte('|')
+			default:
+				line.WriteByte(' ')
+			}
+		}
+		fmt.Fprintf(out, "%12s |%s\n", label, line.String())
+	}
+	fmt.Fprintf(out, "%12s +%s\n", "", strings.Repeat("-", chartWidth))
+	left, right := formatDate(v.from, lang), formatDate(v.to, lang)
+	fmt.Fprintf(out, "%12s  %s%*s\n", "", left, chartWidth-len([]rune(left)), right)
+
+	start, end := v.columnRange(v.cursor)
+	if counts[v.cursor] == 0 {
+		fmt.Fprintf(out, "Курсор: %s — %s, продаж нет\n", formatDateTime(start, lang), formatDateTime(end, lang))
+	} else {
+		fmt.Fprintf(out, "Курсор: %s — %s, средняя цена $%.2f (продаж: %d)\n", formatDateTime(start, lang), formatDateTime(end, lang), avg[v.cursor], counts[v.cursor])
+	}
+}
+
+func priceChart(cfg *Config, sales []Sale) {
+	byItem := make(map[string][]pricePoint)
+	for _, s := range sales {
+		if s.Quantity > 0 {
+			byItem[s.Item] = append(byItem[s.Item], pricePoint{s.Time, s.unitPrice()})
+		}
+	}
+	if len(byItem) == 0 {
+		fmt.Fprintln(out, "Нет данных о продажах")
+		return
+	}
+	items := make([]string, 0, len(byItem))
+	for it := range byItem {
+		items = append(items, it)
+	}
+	sortNames(items, cfg.Language)
+
+	fmt.Fprintln(out, "\nПредметы:")
+	for i, it := range items {
+		fmt.Fprintf(out, "  %d. %s\n", i+1, it)
+	}
+	fmt.Fprint(out, "Номер предмета: ")
+	line, ok := readLine()
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(line)
+	if err != nil || n < 1 || n > len(items) {
+		fmt.Fprintln(out, "Нет предмета с таким номером")
+		return
+	}
+	item := items[n-1]
+	points := byItem[item]
+	sort.Slice(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+
+	v := &chartView{points: points, min: points[0].time, max: points[len(points)-1].time.Add(time.Minute), cursor: chartWidth - 1}
+	v.from, v.to = v.min, v.max
+	for {
+		v.render(item, cfg.Language)
+		fmt.Fprintln(out, "«+»/«-» — приблизить/отдалить, «<»/«>» — сдвинуть, «[»/«]» — курсор, «0» — весь период, пустая строка — назад")
+		fmt.Fprint(out, "> ")
+		cmd, ok := readLine()
+		if !ok || cmd == "" {
+			return
+		}
+		for _, c := range cmd {
+			switch c {
+			case '+':
+				v.zoom(0.5)
+			case '-':
+				v.zoom(2)
+			case '<':
+				v.pan(-0.25)
+			case '>':
+				v.pan(0.25)
+			case '[':
+				v.cursor = max(v.cursor-1, 0)
+			case ']':
+				v.cursor = min(v.cursor+1, chartWidth-1)
+			case '0':
+				v.from, v.to = v.min, v.max
+			}
+		}
+	}
+}
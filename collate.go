@@ -0,0 +1,23 @@
+package main
+
+import (
+	"sort"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+func newCollator(lang string) *collate.Collator {
+	return collate.New(language.Make(normalizeLanguage(lang)))
+}
+
+func sortNames(names []string, lang string) {
+	newCollator(lang).SortStrings(names)
+}
+
+func sortByName[T any](rows []T, lang string, name func(T) string) {
+	c := newCollator(lang)
+	sort.SliceStable(rows, func(i, j int) bool {
+		return c.CompareString(name(rows[i]), name(rows[j])) < 0
+	})
+}
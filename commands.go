@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var commands = map[string]func(args []string) error{
+	"purge":      cmdPurge,
+	"config":     cmdConfig,
+	"trends":     cmdTrends,
+	"verify":     cmdVerify,
+	"recompute":  cmdRecompute,
+	"publish":    cmdPublish,
+	"item":       cmdItem,
+	"sale":       cmdSale,
+	"paydays":    cmdPaydays,
+	"live":       cmdLive,
+	"account":    cmdAccount,
+	"elasticity": cmdElasticity,
+	"store":      cmdStore,
+	"report":     cmdReport,
+	"daemon":     cmdDaemon,
+	"ocr":        cmdOCR,
+	"character":  cmdCharacter,
+	"backup":     cmdBackup,
+	"restore":    cmdRestore,
+	"prune":      cmdPrune,
+}
+
+func expandCommandAlias(args []string) ([]string, error) {
+	if _, ok := commands[args[0]]; ok || strings.HasPrefix(args[0], "-") {
+		return args, nil
+	}
+	cfg, err := readConfig(configPath)
+	if err != nil {
+		return args, nil
+	}
+	line, ok := cfg.CommandAliases[args[0]]
+	if !ok {
+		return args, nil
+	}
+	expanded, err := splitCommandLine(line)
+	if err != nil {
+		return nil, fmt.Errorf("команда %s: %w", args[0], err)
+	}
+	return append(expanded, args[1:]...), nil
+}
+
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("незакрытая кавычка")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+func loadValidConfig() (*Config, error) {
+	cfg, err := loadOrCreateConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Transliterate {
+		enableTranslit()
+	}
+	cfg.tzOverride = timezoneOverride
+	warnings, err := validateConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка в config.json: %w", err)
+	}
+	cfg.applyTimezone()
+	ioTimeout = cfg.ioTimeout
+	if storage, err = newStorage(cfg.Storage); err != nil {
+		return nil, err
+	}
+	for _, w := range warnings {
+		fmt.Fprintln(out, "Предупреждение:", w)
+	}
+	return cfg, nil
+}
+
+func loadLatestSales(ctx context.Context, cfg *Config) ([]Sale, error) {
+	var sales []Sale
+	err := eachLatestSale(ctx, cfg, func(s Sale) error {
+		sales = append(sales, s)
+		return nil
+	})
+	return sales, err
+}
+
+func eachLatestSale(ctx context.Context, cfg *Config, emit func(Sale) error) error {
+	corrections, err := loadCorrectionSet()
+	if err != nil {
+		return err
+	}
+	return eachExportSale(ctx, cfg, func(s Sale) error {
+		if s, ok := corrections.apply(s, cfg); ok {
+			return emit(s)
+		}
+		return nil
+	})
+}
+
+// eachHistorySale emits the exported sales and the stored ones missing from
+// the exports in time order. They pass through a spool, so with
+// memory_limit_mb the history is read from the ledger and the database row
+// by row and kept on disk rather than in memory.
+func eachHistorySale(ctx context.Context, cfg *Config, emit func(Sale) error) error {
+	corrections, err := loadCorrectionSet()
+	if err != nil {
+		return err
+	}
+	history := newSaleSpool(cfg.MemoryLimitMB)
+	defer history.Close()
+	seq := make(keySequence)
+	err = eachExportSale(ctx, cfg, func(s Sale) error {
+		return history.AddKeyed(seq.next(contentKey(s), 0), s)
+	})
+	if errors.Is(err, ErrExportNotFound) && (cfg.SalesDB != "" || fileExists(salesLedgerFile)) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	err = eachStoredSale(ctx, cfg, time.Time{}, func(key string, s Sale) error {
+		s.Item = cfg.canonicalItem(s.Item)
+		return history.AddKeyed(key, s)
+	})
+	if err != nil {
+		return err
+	}
+	return history.Each(func(s Sale) error {
+		if s, ok := corrections.apply(s, cfg); ok {
+			return emit(s)
+		}
+		return nil
+	})
+}
+
+func eachExportSale(ctx context.Context, cfg *Config, emit func(Sale) error) error {
+	exports, err := listExports(ctx, cfg.BaseDir, 0)
+	if err != nil {
+		return err
+	}
+	if cfg.MergeExports {
+		parsed, _, err := parseAllExports(ctx, exports, cfg)
+		if err != nil {
+			return err
+		}
+		if _, err := mergeLiveTrades(cfg, parsed); err != nil {
+			return err
+		}
+		for _, s := range parsed.Sales {
+			if err := emit(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	parsed, err := parseExport(ctx, exports[0].Path, cfg)
+	if err != nil {
+		return err
+	}
+	if err := cfg.verifyChat(exports[0].Path, parsed.ChatName); err != nil {
+		return err
+	}
+	seen := make(map[string]int)
+	for _, s := range parsed.Sales {
+		if cfg.hasLiveSources() {
+			seen[contentKey(s)]++
+		}
+		if err := emit(s); err != nil {
+			return err
+		}
+	}
+	if cfg.hasLiveSources() {
+		_, err = addLiveTrades(cfg, parsed, seen, emit)
+	}
+	return err
+}
+
+func confirm(question string) bool {
+	fmt.Fprintf(out, "%s (да/нет): ", question)
+	answer, ok := readLine()
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(answer) {
+	case "да", "д", "yes", "y":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func printServerComparison(servers map[string]*Server, p period, cfg *Config) {
+	names := sortedServerKeys(servers, cfg.Language)
+	if len(names) == 0 {
+		return
+	}
+	type srvTotals struct {
+		revenue Money
+		sales   int
+		chars   int
+		days    map[string]bool
+		items   map[string]*ItemStats
+	}
+	totals := make([]srvTotals, len(names))
+	for i, name := range names {
+		t := srvTotals{chars: len(servers[name].Characters), days: make(map[string]bool), items: make(map[string]*ItemStats)}
+		for _, ch := range servers[name].Characters {
+			t.revenue += ch.Revenue()
+			t.sales += ch.Sales
+			for d := range ch.Days {
+				t.days[d] = true
+			}
+			for item, d := range ch.Items {
+				agg := t.items[item]
+				if agg == nil {
+					agg = &ItemStats{}
+					t.items[item] = agg
+				}
+				agg.Count += d.Count
+				agg.Sum += d.Sum
+			}
+		}
+		totals[i] = t
+	}
+
+	fmt.Fprintf(out, "\nСравнение серверов (%s):\n", p.name)
+	w := newTable()
+	fmt.Fprintf(w, "Показатель\t%s\n", strings.Join(names, "\t"))
+	row := func(label string, cell func(t srvTotals) string) {
+		fmt.Fprint(w, label)
+		for _, t := range totals {
+			fmt.Fprint(w, "\t", cell(t))
+		}
+		fmt.Fprintln(w)
+	}
+	row("Выручка", func(t srvTotals) string { return fmt.Sprintf("$%.2f", t.revenue) })
+	row("Продаж", func(t srvTotals) string { return fmt.Sprint(t.sales) })
+	row("Персонажей", func(t srvTotals) string { return fmt.Sprint(t.chars) })
+	row("Активных дней", func(t srvTotals) string { return fmt.Sprint(len(t.days)) })
+	row("Выручка в активный день", func(t srvTotals) string {
+		if len(t.days) == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("$%.2f", t.revenue.Float()/float64(len(t.days)))
+	})
+	for _, item := range cfg.selected {
+		row("Ср. цена: "+item, func(t srvTotals) string {
+			d := t.items[item]
+			if d == nil || d.Count == 0 {
+				return "-"
+			}
+			return fmt.Sprintf("$%.2f", d.average())
+		})
+	}
+	w.Flush()
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type Config struct {
+	BaseDir         string              `json:"base_dir"`
+	Selected        []string            `json:"selected"`
+	Aliases         map[string]string   `json:"aliases,omitempty"`
+	Language        string              `json:"language,omitempty"`
+	Transliterate   bool                `json:"transliterate,omitempty"`
+	Hooks           []Hook              `json:"hooks,omitempty"`
+	AnonymizeSalt   string              `json:"anonymize_salt,omitempty"`
+	SiteDir         string              `json:"site_dir,omitempty"`
+	SitePassword    string              `json:"site_password,omitempty"`
+	Limits          *Limits             `json:"limits,omitempty"`
+	Timezone        string              `json:"timezone,omitempty"`
+	ServerTimezones map[string]string   `json:"server_timezones,omitempty"`
+	GuildPool       *GuildPool          `json:"guild_pool,omitempty"`
+	Stock           *StockConfig        `json:"stock,omitempty"`
+	Notify          []NotifyChannel     `json:"notify,omitempty"`
+	Basket          map[string]float64  `json:"basket,omitempty"`
+	ChatName        string              `json:"chat_name,omitempty"`
+	ChatCheck       string              `json:"chat_check,omitempty"`
+	ScoreWeights    *ScoreWeights       `json:"score_weights,omitempty"`
+	QualitySuffixes []string            `json:"quality_suffixes,omitempty"`
+	PriceSource     *PriceSource        `json:"price_source,omitempty"`
+	MemoryLimitMB   int                 `json:"memory_limit_mb,omitempty"`
+	Attribution     []AttributionRule   `json:"attribution,omitempty"`
+	Publish         *PublishTarget      `json:"publish,omitempty"`
+	Forecast        bool                `json:"forecast,omitempty"`
+	MergeExports    bool                `json:"merge_exports,omitempty"`
+	PaydayMinutes   int                 `json:"payday_minutes,omitempty"`
+	ParseWorkers    int                 `json:"parse_workers,omitempty"`
+	TopN            int                 `json:"top_n,omitempty"`
+	Live            *LiveSource         `json:"live,omitempty"`
+	Account         *AccountSource      `json:"account,omitempty"`
+	OCRCommand      string              `json:"ocr_command,omitempty"`
+	Dashboards      []Dashboard         `json:"dashboards,omitempty"`
+	Profile         *Profile            `json:"profile,omitempty"`
+	Categories      map[string][]string `json:"categories,omitempty"`
+	Goals           []Goal              `json:"goals,omitempty"`
+	CommandAliases  map[string]string   `json:"command_aliases,omitempty"`
+	Periods         []PeriodDef         `json:"periods,omitempty"`
+	SalesDB         string              `json:"sales_db,omitempty"`
+	SalesLedger     bool                `json:"sales_ledger,omitempty"`
+	Retired         []string            `json:"retired,omitempty"`
+	StaleExportDays int                 `json:"stale_export_days,omitempty"`
+	Storage         string              `json:"storage,omitempty"`
+	DiscordUsers    map[string]string   `json:"discord_users,omitempty"`
+	Retention       *Retention          `json:"retention,omitempty"`
+	ReportTimeout   string              `json:"report_timeout,omitempty"`
+	IOTimeout       string              `json:"io_timeout,omitempty"`
+
+	itemAliases     map[string]string
+	aliasSources    map[string]string
+	itemNames       map[string]string
+	itemSpellings   *sync.Map
+	qualityRes      []*regexp.Regexp
+	location        *time.Location
+	serverLocations map[string]*time.Location
+	notifiers       []routedNotifier
+	publisher       publisher
+	tradeFormat     *tradeFormat
+	tradeFormats    []*tradeFormat
+	messageParsers  parserSet
+	itemCategories  map[string]string
+	periods         []period
+	retired         map[string]bool
+	discordUsers    map[string]string
+	reportTimeout   time.Duration
+	ioTimeout       time.Duration
+	tzOverride      string
+	selected        []string
+	basket          map[string]float64
+}
+
+type Limits struct {
+	MinPrice    Money `json:"min_price,omitempty"`
+	MaxPrice    Money `json:"max_price,omitempty"`
+	MinQuantity int   `json:"min_quantity,omitempty"`
+	MaxQuantity int   `json:"max_quantity,omitempty"`
+}
+
+var defaultLimits = Limits{MinPrice: dollar, MaxPrice: 10_000_000 * dollar, MinQuantity: 1, MaxQuantity: 10_000}
+
+var defaultAliases = map[string]string{
+	"Улучшенный эпинефрин": "Адреналин",
+}
+
+var defaultQualitySuffixes = []string{`\s*\((б/у|новое|новый|сломано|сломан)\)$`, `\s*(\+\d+)$`}
+
+func readConfig(path string) (*Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+func loadOrCreateConfig(path string) (*Config, error) {
+	cfg, err := readConfig(path)
+	if err != nil {
+		cfg = &Config{}
+	}
+	if cfg.BaseDir != "" && len(cfg.Selected) > 0 {
+		return cfg, nil
+	}
+
+	if cfg.BaseDir == "" {
+		def, found := defaultExportDir()
+		if def != "" {
+			fmt.Fprintf(out, "Папка загрузок Telegram Desktop: %s (экспортов ChatExport_*: %d)\n", def, found)
+			fmt.Fprintf(out, "Введите путь к каталогу ChatExport_* [%s]: ", def)
+		} else {
+			fmt.Fprint(out, "Введите путь к каталогу ChatExport_*: ")
+		}
+		flushOut()
+		baseDir, _ := stdin.ReadString('\n')
+		cfg.BaseDir = strings.TrimSpace(baseDir)
+		if cfg.BaseDir == "" {
+			cfg.BaseDir = def
+		}
+	}
+
+	if len(cfg.Selected) == 0 {
+		fmt.Fprintln(out, "Введите названия предметов (пустая строка для завершения):")
+		flushOut()
+		for {
+			line, _ := stdin.ReadString('\n')
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			cfg.Selected = append(cfg.Selected, line)
+		}
+	}
+
+	_ = saveConfig(path, cfg)
+	return cfg, nil
+}
+
+func saveConfig(path string, cfg *Config) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func (cfg *Config) aliasItem(item string) string {
+	item = normalizeItemName(item)
+	if to, ok := cfg.itemAliases[item]; ok {
+		return to
+	}
+	if to, ok := cfg.itemAliases[cfg.aliasSources[itemKey(item)]]; ok {
+		return to
+	}
+	return item
+}
+
+func (cfg *Config) canonicalItem(item string) string {
+	item = cfg.aliasItem(item)
+	key := itemKey(item)
+	if name, ok := cfg.itemNames[key]; ok {
+		return name
+	}
+	if cfg.itemSpellings == nil {
+		return item
+	}
+	name, _ := cfg.itemSpellings.LoadOrStore(key, item)
+	return name.(string)
+}
+
+func (cfg *Config) splitQuality(item string) (base, quality string) {
+	for _, re := range cfg.qualityRes {
+		m := re.FindStringSubmatchIndex(item)
+		if m == nil || m[0] == 0 {
+			continue
+		}
+		quality = item[m[0]:m[1]]
+		if len(m) > 2 && m[2] >= 0 {
+			quality = item[m[2]:m[3]]
+		}
+		return strings.TrimSpace(item[:m[0]]), strings.TrimSpace(quality)
+	}
+	return item, ""
+}
+
+func (cfg *Config) hasLiveSources() bool {
+	return cfg.Live != nil || cfg.Account != nil || fileExists(liveMessagesFile)
+}
+
+func (cfg *Config) limits() Limits {
+	l := defaultLimits
+	if cfg.Limits == nil {
+		return l
+	}
+	if cfg.Limits.MinPrice != 0 {
+		l.MinPrice = cfg.Limits.MinPrice
+	}
+	if cfg.Limits.MaxPrice != 0 {
+		l.MaxPrice = cfg.Limits.MaxPrice
+	}
+	if cfg.Limits.MinQuantity != 0 {
+		l.MinQuantity = cfg.Limits.MinQuantity
+	}
+	if cfg.Limits.MaxQuantity != 0 {
+		l.MaxQuantity = cfg.Limits.MaxQuantity
+	}
+	return l
+}
+
+func (l Limits) check(price Money, qty int) string {
+	switch {
+	case price < l.MinPrice || price > l.MaxPrice:
+		return fmt.Sprintf("цена $%.2f вне допустимого диапазона $%.2f–$%.2f", price, l.MinPrice, l.MaxPrice)
+	case qty < l.MinQuantity || qty > l.MaxQuantity:
+		return fmt.Sprintf("количество %d вне допустимого диапазона %d–%d", qty, l.MinQuantity, l.MaxQuantity)
+	}
+	return ""
+}
+
+var (
+	machineLocation  = time.Local
+	timezoneOverride string
+)
+
+func (cfg *Config) timezone() string {
+	if cfg.tzOverride != "" {
+		return cfg.tzOverride
+	}
+	return cfg.Timezone
+}
+
+func (cfg *Config) applyTimezone() {
+	loc := machineLocation
+	if cfg.location != nil {
+		loc = cfg.location
+	}
+	if time.Local != loc {
+		time.Local = loc
+	}
+}
+
+func (cfg *Config) serverLocation(server string) *time.Location {
+	if loc, ok := cfg.serverLocations[server]; ok {
+		return loc
+	}
+	return time.Local
+}
+
+func (cfg *Config) verifyChat(dir, chatName string) error {
+	if cfg.ChatName == "" {
+		return nil
+	}
+	if strings.EqualFold(strings.TrimSpace(chatName), strings.TrimSpace(cfg.ChatName)) {
+		return nil
+	}
+	found := chatName
+	if found == "" {
+		found = "(название не найдено)"
+	}
+	msg := fmt.Sprintf("экспорт %s сделан из чата «%s», а ожидается «%s»", dir, found, cfg.ChatName)
+	if cfg.ChatCheck == "refuse" {
+		return errors.New(msg)
+	}
+	fmt.Fprintln(out, "Предупреждение:", msg)
+	return nil
+}
+
+func normalizeItemName(item string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(item)), " ")
+}
+
+func itemKey(item string) string {
+	return strings.ToLower(normalizeItemName(item))
+}
+
+func validateConfig(cfg *Config) (warnings []string, err error) {
+	aliases := make(map[string]string)
+	for from, to := range defaultAliases {
+		aliases[from] = to
+	}
+	for from, to := range cfg.Aliases {
+		from, to = normalizeItemName(from), normalizeItemName(to)
+		if from == to {
+			warnings = append(warnings, fmt.Sprintf("синоним «%s» указывает сам на себя и пропущен", from))
+			continue
+		}
+		aliases[from] = to
+	}
+
+	sources := make([]string, 0, len(aliases))
+	for from := range aliases {
+		sources = append(sources, from)
+	}
+	sort.Strings(sources)
+
+	byKey := make(map[string]string)
+	for _, from := range sources {
+		if prev, ok := byKey[itemKey(from)]; ok && aliases[prev] != aliases[from] {
+			return nil, fmt.Errorf("противоречивые синонимы: «%s» → «%s», но «%s» → «%s»", prev, aliases[prev], from, aliases[from])
+		}
+		byKey[itemKey(from)] = from
+	}
+
+	for _, from := range sources {
+		to := aliases[from]
+		seen := map[string]bool{from: true}
+		for next, ok := aliases[to]; ok; next, ok = aliases[to] {
+			if seen[to] {
+				return nil, fmt.Errorf("синонимы образуют цикл через «%s»", from)
+			}
+			seen[to] = true
+			to = next
+		}
+		if to != aliases[from] {
+			warnings = append(warnings, fmt.Sprintf("цепочка синонимов: «%s» → «%s» сведено к «%s»", from, aliases[from], to))
+		}
+		aliases[from] = to
+	}
+	cfg.itemAliases = aliases
+	cfg.aliasSources = byKey
+	cfg.itemNames = make(map[string]string)
+	for _, from := range sources {
+		if key := itemKey(aliases[from]); cfg.itemNames[key] == "" {
+			cfg.itemNames[key] = aliases[from]
+		}
+	}
+	for _, item := range cfg.Selected {
+		if item = cfg.aliasItem(item); cfg.itemNames[itemKey(item)] == "" {
+			cfg.itemNames[itemKey(item)] = item
+		}
+	}
+	cfg.itemSpellings = &sync.Map{}
+
+	cfg.location = nil
+	if tz := cfg.timezone(); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("неизвестный часовой пояс %q: %w", tz, err)
+		}
+		cfg.location = loc
+	}
+	cfg.serverLocations = make(map[string]*time.Location)
+	for srv, name := range cfg.ServerTimezones {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("неизвестный часовой пояс %q для сервера %s: %w", name, srv, err)
+		}
+		cfg.serverLocations[srv] = loc
+	}
+	for name, line := range cfg.CommandAliases {
+		if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("command_aliases: недопустимое имя команды %q", name)
+		}
+		if args, err := splitCommandLine(line); err != nil {
+			return nil, fmt.Errorf("command_aliases: %s: %w", name, err)
+		} else if len(args) == 0 {
+			return nil, fmt.Errorf("command_aliases: %s: пустая команда", name)
+		}
+	}
+	if cfg.GuildPool != nil {
+		if err := cfg.GuildPool.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.periods, err = buildPeriods(cfg.Periods); err != nil {
+		return nil, err
+	}
+	cfg.retired = make(map[string]bool, len(cfg.Retired))
+	for _, r := range cfg.Retired {
+		if id := retiredID(r); id != "" {
+			cfg.retired[id] = true
+		}
+	}
+	if cfg.discordUsers, err = buildDiscordUsers(cfg.DiscordUsers); err != nil {
+		return nil, err
+	}
+	if cfg.Retention != nil {
+		if err := cfg.Retention.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ReportTimeout != "" {
+		if cfg.reportTimeout, err = parseWindow(cfg.ReportTimeout); err != nil {
+			return nil, fmt.Errorf("report_timeout: %w", err)
+		}
+	}
+	if cfg.IOTimeout != "" {
+		if cfg.ioTimeout, err = parseWindow(cfg.IOTimeout); err != nil {
+			return nil, fmt.Errorf("io_timeout: %w", err)
+		}
+	}
+	if err := validateCategories(cfg); err != nil {
+		return nil, err
+	}
+	for i := range cfg.Goals {
+		if err := cfg.Goals[i].validate(cfg); err != nil {
+			return nil, fmt.Errorf("цель #%d: %w", i+1, err)
+		}
+	}
+	if cfg.Stock != nil {
+		if err := cfg.Stock.validate(cfg); err != nil {
+			return nil, err
+		}
+	}
+	switch cfg.ChatCheck {
+	case "", "warn", "refuse":
+	default:
+		return nil, fmt.Errorf("chat_check: ожидается warn или refuse, получено %q", cfg.ChatCheck)
+	}
+
+	suffixes := cfg.QualitySuffixes
+	if suffixes == nil {
+		suffixes = defaultQualitySuffixes
+	}
+	cfg.qualityRes = nil
+	for _, expr := range suffixes {
+		if !strings.HasSuffix(expr, "$") {
+			expr += "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("quality_suffixes: неверное выражение %q: %w", expr, err)
+		}
+		cfg.qualityRes = append(cfg.qualityRes, re)
+	}
+
+	for i := range cfg.Attribution {
+		if err := cfg.Attribution[i].validate(cfg); err != nil {
+			return nil, fmt.Errorf("правило владельца #%d: %w", i+1, err)
+		}
+	}
+	if cfg.Live != nil && cfg.Live.BotToken == "" {
+		return nil, errors.New("live: не указан bot_token")
+	}
+	for i := range cfg.Dashboards {
+		if err := cfg.Dashboards[i].validate(cfg); err != nil {
+			return nil, fmt.Errorf("дашборд #%d: %w", i+1, err)
+		}
+	}
+	if cfg.Profile != nil {
+		formats, err := compileMessageFormats(*cfg.Profile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.tradeFormat, cfg.tradeFormats = formats[0], formats
+	}
+	if cfg.Account != nil {
+		if err := cfg.Account.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.PaydayMinutes < 0 {
+		return nil, errors.New("payday_minutes не может быть отрицательным")
+	}
+	if cfg.ParseWorkers < 0 {
+		return nil, errors.New("parse_workers не может быть отрицательным")
+	}
+	if cfg.TopN < 0 {
+		return nil, errors.New("top_n не может быть отрицательным")
+	}
+	if _, err := newStorage(cfg.Storage); err != nil {
+		return nil, err
+	}
+	if cfg.StaleExportDays < 0 {
+		return nil, errors.New("stale_export_days не может быть отрицательным")
+	}
+	if cfg.MemoryLimitMB < 0 {
+		return nil, errors.New("memory_limit_mb не может быть отрицательным")
+	}
+	if cfg.PriceSource != nil && cfg.PriceSource.URL == "" {
+		return nil, errors.New("price_source: не указан url")
+	}
+
+	if w := cfg.ScoreWeights; w != nil && (w.Revenue < 0 || w.Velocity < 0 || w.Diversity < 0 || w.Consistency < 0) {
+		return nil, errors.New("score_weights: веса не могут быть отрицательными")
+	}
+
+	basket := make(map[string]float64, len(cfg.Basket))
+	for item, w := range cfg.Basket {
+		if w <= 0 {
+			return nil, fmt.Errorf("вес предмета «%s» в корзине должен быть положительным", item)
+		}
+		basket[cfg.canonicalItem(strings.TrimSpace(item))] += w
+	}
+	cfg.basket = basket
+	if cfg.notifiers, err = buildNotifiers(cfg.Notify); err != nil {
+		return nil, err
+	}
+	if cfg.publisher, err = buildPublisher(cfg.Publish); err != nil {
+		return nil, err
+	}
+	if cfg.Publish != nil && cfg.Publish.Type == "sftp" {
+		warnings = append(warnings, `publish: тип "sftp" выгружает отчёт через scp — укажите "type": "scp"`)
+	}
+
+	selected, selWarnings := cfg.normalizeSelected(cfg.Selected)
+	cfg.selected = selected
+	warnings = append(warnings, selWarnings...)
+	cfg.messageParsers = buildParsers(cfg)
+	return warnings, nil
+}
+
+func (cfg *Config) normalizeSelected(items []string) (selected, warnings []string) {
+	seen := make(map[string]string)
+	for _, item := range items {
+		item = normalizeItemName(item)
+		if to := cfg.aliasItem(item); itemKey(to) != itemKey(item) {
+			warnings = append(warnings, fmt.Sprintf("«%s» — синоним «%s» и учитывается вместе с ним", item, to))
+			item = to
+		}
+		name := cfg.canonicalItem(item)
+		prev, dup := seen[itemKey(name)]
+		switch {
+		case dup && prev == item:
+			warnings = append(warnings, fmt.Sprintf("«%s» указан в selected несколько раз, дубликат убран", item))
+			continue
+		case dup:
+			warnings = append(warnings, fmt.Sprintf("«%s» и «%s» отличаются только регистром или пробелами и учитываются как один предмет", prev, item))
+			continue
+		}
+		seen[itemKey(name)] = item
+		selected = append(selected, name)
+	}
+	return selected, warnings
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const configUsage = `Использование:
+  market config show
+  market config add-item <название>
+  market config remove-item <название>
+  market config add-alias <старое название> <основное>
+  market config remove-alias <старое название>
+  market config add-command <имя> "<команда и флаги>"
+  market config remove-command <имя>
+  market config add-discord <ID персонажа> <пользователь Discord>
+  market config remove-discord <ID персонажа>
+  market config set <ключ> <значение>
+
+Ключи для set: base_dir, language, site_dir, chat_name, chat_check, transliterate, anonymize_salt, timezone`
+
+func cmdConfig(args []string) error {
+	if len(args) == 0 {
+		return errors.New(configUsage)
+	}
+	cfg, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "show":
+		data, err := json.MarshalIndent(cfg, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(data))
+		return nil
+	case "add-item":
+		if len(args) != 2 {
+			return errors.New(configUsage)
+		}
+		item := normalizeItemName(args[1])
+		if slices.ContainsFunc(cfg.Selected, func(s string) bool { return itemKey(s) == itemKey(item) }) {
+			fmt.Fprintf(out, "«%s» уже отслеживается\n", item)
+			return nil
+		}
+		cfg.Selected = append(cfg.Selected, item)
+	case "remove-item":
+		if len(args) != 2 {
+			return errors.New(configUsage)
+		}
+		i := slices.IndexFunc(cfg.Selected, func(s string) bool { return itemKey(s) == itemKey(args[1]) })
+		if i < 0 {
+			return fmt.Errorf("«%s» нет в списке selected", args[1])
+		}
+		cfg.Selected = slices.Delete(cfg.Selected, i, i+1)
+	case "add-alias":
+		if len(args) != 3 {
+			return errors.New(configUsage)
+		}
+		if cfg.Aliases == nil {
+			cfg.Aliases = make(map[string]string)
+		}
+		cfg.Aliases[strings.TrimSpace(args[1])] = strings.TrimSpace(args[2])
+	case "remove-alias":
+		if len(args) != 2 {
+			return errors.New(configUsage)
+		}
+		if _, ok := cfg.Aliases[args[1]]; !ok {
+			return fmt.Errorf("синонима «%s» нет", args[1])
+		}
+		delete(cfg.Aliases, args[1])
+	case "add-command":
+		if len(args) != 3 {
+			return errors.New(configUsage)
+		}
+		if cfg.CommandAliases == nil {
+			cfg.CommandAliases = make(map[string]string)
+		}
+		cfg.CommandAliases[strings.TrimSpace(args[1])] = strings.TrimSpace(args[2])
+	case "remove-command":
+		if len(args) != 2 {
+			return errors.New(configUsage)
+		}
+		if _, ok := cfg.CommandAliases[args[1]]; !ok {
+			return fmt.Errorf("команды «%s» нет", args[1])
+		}
+		delete(cfg.CommandAliases, args[1])
+	case "add-discord":
+		if len(args) != 3 || retiredID(args[1]) == "" {
+			return errors.New(configUsage)
+		}
+		if cfg.DiscordUsers == nil {
+			cfg.DiscordUsers = make(map[string]string)
+		}
+		cfg.DiscordUsers[retiredID(args[1])] = strings.TrimPrefix(strings.TrimSpace(args[2]), "@")
+	case "remove-discord":
+		if len(args) != 2 {
+			return errors.New(configUsage)
+		}
+		id := retiredID(args[1])
+		n := len(cfg.DiscordUsers)
+		maps.DeleteFunc(cfg.DiscordUsers, func(k, _ string) bool { return retiredID(k) == id })
+		if len(cfg.DiscordUsers) == n {
+			return fmt.Errorf("персонаж #%s не связан с Discord", id)
+		}
+	case "set":
+		if len(args) != 3 {
+			return errors.New(configUsage)
+		}
+		if err := setConfigValue(cfg, args[1], args[2]); err != nil {
+			return err
+		}
+	default:
+		return errors.New(configUsage)
+	}
+
+	check, err := cloneConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := validateConfig(check); err != nil {
+		return fmt.Errorf("изменение не сохранено: %w", err)
+	}
+	if err := saveConfig(configPath, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Настройки сохранены")
+	return nil
+}
+
+func setConfigValue(cfg *Config, key, value string) error {
+	switch key {
+	case "base_dir":
+		if st, err := os.Stat(value); err != nil || !st.IsDir() {
+			return fmt.Errorf("папка %s не найдена", value)
+		}
+		cfg.BaseDir = value
+	case "language":
+		if _, ok := monthNames[value]; !ok {
+			return fmt.Errorf("неподдерживаемый язык %q", value)
+		}
+		cfg.Language = value
+	case "site_dir":
+		cfg.SiteDir = value
+	case "chat_name":
+		cfg.ChatName = value
+	case "chat_check":
+		cfg.ChatCheck = value
+	case "anonymize_salt":
+		cfg.AnonymizeSalt = value
+	case "timezone":
+		cfg.Timezone = value
+	case "transliterate":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("transliterate: ожидается true или false")
+		}
+		cfg.Transliterate = b
+	default:
+		return fmt.Errorf("неизвестный ключ %q\n\n%s", key, configUsage)
+	}
+	return nil
+}
+
+func cloneConfig(cfg *Config) (*Config, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
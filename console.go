@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	stdout io.Writer = os.Stdout
+	out              = stdout
+	stdin            = bufio.NewReader(os.Stdin)
+)
+
+var translitTable = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+	'─': "-", '━': "-", '│': "|", '┃': "|", '┌': "+", '┐': "+", '└': "+", '┘': "+",
+	'├': "+", '┤': "+", '┬': "+", '┴': "+", '┼': "+", '═': "=", '║': "|",
+	'▲': "^", '▼': "v", '…': "...", '—': "-", '–': "-", '«': "\"", '»': "\"", '№': "N",
+	'\u00a0': " ", '\u2011': "-",
+}
+
+func transliterate(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if t, ok := translitTable[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		if t, ok := translitTable[unicode.ToLower(r)]; ok {
+			if t != "" {
+				b.WriteString(strings.ToUpper(t[:1]) + t[1:])
+			}
+			continue
+		}
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+type translitWriter struct {
+	w       io.Writer
+	pending []byte
+}
+
+func (t *translitWriter) Write(p []byte) (int, error) {
+	buf := append(t.pending, p...)
+	n := len(buf)
+	if i := lastRuneStart(buf); i >= 0 && !utf8.FullRune(buf[i:]) {
+		n = i
+	}
+	t.pending = append([]byte(nil), buf[n:]...)
+	if _, err := io.WriteString(t.w, transliterate(string(buf[:n]))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t *translitWriter) Flush() error {
+	if len(t.pending) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(t.w, transliterate(string(t.pending)))
+	t.pending = nil
+	return err
+}
+
+func lastRuneStart(b []byte) int {
+	i := len(b) - 1
+	for i > 0 && i > len(b)-utf8.UTFMax && !utf8.RuneStart(b[i]) {
+		i--
+	}
+	return i
+}
+
+var useTranslit bool
+
+func initConsole(translit bool) {
+	if err := setupConsole(); err != nil {
+		translit = true
+	}
+	if translit {
+		enableTranslit()
+	}
+}
+
+func enableTranslit() {
+	if useTranslit {
+		return
+	}
+	useTranslit = true
+	out = &translitWriter{w: stdout}
+}
+
+func captureOutput(w io.Writer) (restore func()) {
+	prevOut, prevStdout, prevTranslit := out, stdout, useTranslit
+	out, stdout, useTranslit = w, w, false
+	return func() {
+		out, stdout, useTranslit = prevOut, prevStdout, prevTranslit
+	}
+}
+
+func flushOut() {
+	if tr, ok := out.(*translitWriter); ok {
+		tr.Flush()
+	}
+}
+
+type tableWriter struct {
+	io.Writer
+	tw *tabwriter.Writer
+}
+
+func newTable() *tableWriter {
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	if useTranslit {
+		return &tableWriter{Writer: &translitWriter{w: tw}, tw: tw}
+	}
+	return &tableWriter{Writer: tw, tw: tw}
+}
+
+func (t *tableWriter) Flush() error {
+	if tr, ok := t.Writer.(*translitWriter); ok {
+		if err := tr.Flush(); err != nil {
+			return err
+		}
+	}
+	return t.tw.Flush()
+}
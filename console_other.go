@@ -0,0 +1,7 @@
+//go:build !windows
+
+package main
+
+func setupConsole() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Detroit $600 000", "Detroit $600 000"},
+		{"адреналин", "adrenalin"},
+		{"Щука Ёж", "Shchuka Yozh"},
+		{"Подъезд", "Podezd"},
+		{"«Жук» — №5…", "\"Zhuk\" - N5..."},
+		{"HK MP5‑SD x2", "HK MP5-SD x2"},
+		{"├─┤", "+-+"},
+		{"▲ 5% ▼", "^ 5% v"},
+		{"日本", "??"},
+	}
+	for _, tt := range tests {
+		if got := transliterate(tt.in); got != tt.want {
+			t.Errorf("transliterate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTranslitWriterSplitRune(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		split int
+		want  string
+	}{
+		{"cyrillic", "Цена", 1, "Tsena"},
+		{"after ascii", "x: ж", 4, "x: zh"},
+		{"three bytes", "a—b", 2, "a-b"},
+		{"three bytes late", "a—b", 3, "a-b"},
+		{"whole runes", "да", 2, "da"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &translitWriter{w: &buf}
+			p := []byte(tt.text)
+			for _, part := range [][]byte{p[:tt.split], p[tt.split:]} {
+				n, err := w.Write(part)
+				if err != nil || n != len(part) {
+					t.Fatalf("Write(%q) = %d, %v", part, n, err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTranslitWriterFlushIncomplete(t *testing.T) {
+	var buf bytes.Buffer
+	w := &translitWriter{w: &buf}
+	w.Write([]byte("ok \xd0"))
+	if got := buf.String(); got != "ok " {
+		t.Fatalf("before Flush: got %q, want %q", got, "ok ")
+	}
+	w.Flush()
+	if got := buf.String(); got != "ok ?" {
+		t.Errorf("after Flush: got %q, want %q", got, "ok ?")
+	}
+}
+
+func TestNewTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		translit bool
+		rows     [][]string
+		want     string
+	}{
+		{
+			name: "ascii",
+			rows: [][]string{{"Item", "Qty", "Price"}, {"HK", "5", "$600 000"}, {"Adrenaline", "12", "$9 000"}},
+			want: "Item        Qty  Price\n" +
+				"HK          5    $600 000\n" +
+				"Adrenaline  12   $9 000\n",
+		},
+		{
+			name: "cyrillic widths",
+			rows: [][]string{{"Предмет", "Кол-во"}, {"Адреналин", "3"}, {"Эпинефрин улучшенный", "10"}},
+			want: "Предмет               Кол-во\n" +
+				"Адреналин             3\n" +
+				"Эпинефрин улучшенный  10\n",
+		},
+		{
+			name:     "transliterated",
+			translit: true,
+			rows:     [][]string{{"Предмет", "Кол-во"}, {"Щит", "3"}, {"Ёж", "10"}},
+			want: "Predmet  Kol-vo\n" +
+				"Shchit   3\n" +
+				"Yozh     10\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			restore := captureOutput(&buf)
+			defer restore()
+			useTranslit = tt.translit
+
+			tw := newTable()
+			for _, row := range tt.rows {
+				for i, cell := range row {
+					if i > 0 {
+						fmt.Fprint(tw, "\t")
+					}
+					fmt.Fprint(tw, cell)
+				}
+				fmt.Fprintln(tw)
+			}
+			if err := tw.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "syscall"
+
+const cpUTF8 = 65001
+
+var (
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procSetConsoleOutputCP = kernel32.NewProc("SetConsoleOutputCP")
+	procSetConsoleCP       = kernel32.NewProc("SetConsoleCP")
+)
+
+func setupConsole() error {
+	if r, _, err := procSetConsoleOutputCP.Call(cpUTF8); r == 0 {
+		return err
+	}
+	procSetConsoleCP.Call(cpUTF8)
+	return nil
+}
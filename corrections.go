@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const correctionsFile = "corrections.jsonl"
+
+const saleUsage = `Использование:
+  market sale show <ID>
+  market sale edit <ID> [--price N] [--quantity N] [--item название] [--reason текст]
+  market sale void <ID> [--reason текст]
+
+ID — номер сообщения Telegram (колонка ID в --recent).`
+
+type correction struct {
+	Time     time.Time `json:"time"`
+	MsgID    int64     `json:"msg_id"`
+	Action   string    `json:"action"`
+	Price    *Money    `json:"price,omitempty"`
+	Quantity *int      `json:"quantity,omitempty"`
+	Item     string    `json:"item,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+func loadCorrections() ([]correction, error) {
+	f, err := os.Open(correctionsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var res []correction
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		var c correction
+		if err := json.Unmarshal(sc.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("%s, строка %d: %w", correctionsFile, line, err)
+		}
+		res = append(res, c)
+	}
+	return res, sc.Err()
+}
+
+func appendCorrection(c correction) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(correctionsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type correctionSet map[int64][]correction
+
+func loadCorrectionSet() (correctionSet, error) {
+	corrections, err := loadCorrections()
+	if err != nil {
+		return nil, err
+	}
+	set := make(correctionSet)
+	for _, c := range corrections {
+		set[c.MsgID] = append(set[c.MsgID], c)
+	}
+	return set, nil
+}
+
+func (c correction) apply(s Sale, cfg *Config) (Sale, bool) {
+	if c.Action == "void" {
+		return s, false
+	}
+	if c.Price != nil {
+		s.Price = *c.Price
+	}
+	if c.Quantity != nil {
+		s.Quantity = *c.Quantity
+	}
+	if c.Item != "" {
+		s.RawItem = c.Item
+		s.Item = cfg.canonicalItem(c.Item)
+	}
+	return s, true
+}
+
+func (set correctionSet) apply(s Sale, cfg *Config) (Sale, bool) {
+	if s.MsgID == 0 {
+		return s, true
+	}
+	keep := true
+	for _, c := range set[s.MsgID] {
+		s, keep = c.apply(s, cfg)
+		if !keep {
+			break
+		}
+	}
+	return s, keep
+}
+
+func applyCorrections(sales []Sale, cfg *Config) ([]Sale, int, error) {
+	set, err := loadCorrectionSet()
+	if err != nil || len(set) == 0 {
+		return sales, 0, err
+	}
+	res := sales[:0]
+	changed := 0
+	for _, s := range sales {
+		if len(set[s.MsgID]) > 0 {
+			changed++
+		}
+		if s, ok := set.apply(s, cfg); ok {
+			res = append(res, s)
+		}
+	}
+	return res, changed, nil
+}
+
+func cmdSale(args []string) error {
+	if len(args) < 2 {
+		return errors.New(saleUsage)
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(args[1], "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return fmt.Errorf("неверный ID %q\n\n%s", args[1], saleUsage)
+	}
+
+	fs := newFlagSet("sale " + args[0])
+	price := fs.Float64("price", -1, "исправленная цена продажи")
+	quantity := fs.Int("quantity", -1, "исправленное количество")
+	item := fs.String("item", "", "исправленное название предмета")
+	reason := fs.String("reason", "", "причина исправления")
+	if err := fs.Parse(args[2:]); err != nil {
+		return err
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	var original *Sale
+	ctx, stop := runContext(cfg)
+	defer stop()
+	err = eachExportSale(ctx, cfg, func(s Sale) error {
+		if s.MsgID == id {
+			original = &s
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "show":
+		if original == nil {
+			return fmt.Errorf("продажа с ID %d не найдена", id)
+		}
+		return showSale(*original, cfg)
+	case "edit", "void":
+	default:
+		return errors.New(saleUsage)
+	}
+	if original == nil {
+		return fmt.Errorf("продажа с ID %d не найдена в экспорте", id)
+	}
+
+	c := correction{Time: time.Now(), MsgID: id, Action: args[0], Reason: *reason}
+	if args[0] == "edit" {
+		if *price >= 0 {
+			p := moneyFromFloat(*price)
+			c.Price = &p
+		}
+		if *quantity == 0 {
+			return errors.New("количество должно быть положительным; чтобы убрать продажу, используйте sale void")
+		}
+		if *quantity > 0 {
+			c.Quantity = quantity
+		}
+		c.Item = strings.TrimSpace(*item)
+		if c.Price == nil && c.Quantity == nil && c.Item == "" {
+			return errors.New("укажите хотя бы одно из --price, --quantity, --item")
+		}
+	}
+	if err := appendCorrection(c); err != nil {
+		return fmt.Errorf("не удалось записать %s: %w", correctionsFile, err)
+	}
+	fmt.Fprintf(out, "Исправление записано в %s.\n", correctionsFile)
+	return showSale(*original, cfg)
+}
+
+func showSale(original Sale, cfg *Config) error {
+	corrections, err := loadCorrections()
+	if err != nil {
+		return err
+	}
+	printSale := func(title string, s Sale) {
+		fmt.Fprintf(out, "%s: %s, %s, %s — %s × %d, $%.2f\n", title, formatDateTime(s.Time, cfg.Language), s.Server, s.Character, s.Item, s.Quantity, s.Price)
+	}
+	printSale("Из экспорта", original)
+
+	cur, alive := original, true
+	for _, c := range corrections {
+		if c.MsgID != original.MsgID {
+			continue
+		}
+		if alive {
+			cur, alive = c.apply(cur, cfg)
+		}
+		desc := "исправлено"
+		if c.Action == "void" {
+			desc = "аннулировано"
+		}
+		if c.Reason != "" {
+			desc += " (" + c.Reason + ")"
+		}
+		fmt.Fprintf(out, "  %s: %s\n", formatDateTime(c.Time, cfg.Language), desc)
+	}
+	if !alive {
+		fmt.Fprintln(out, "Сейчас: аннулировано, в отчётах не учитывается")
+		return nil
+	}
+	printSale("Сейчас", cur)
+	return nil
+}
+
+type correctionsPurge struct {
+	ctx context.Context
+	cfg *Config
+}
+
+func (correctionsPurge) Name() string { return correctionsFile }
+
+// Purge drops corrections of the purged sales. Corrections only carry the
+// message ID, so the sales are looked up in the exports and stored history;
+// purge runs this target before the ones that delete that history.
+func (p correctionsPurge) Purge(f purgeFilter, dryRun bool) (int, error) {
+	corrections, err := loadCorrections()
+	if err != nil || len(corrections) == 0 {
+		return 0, err
+	}
+	purged := make(map[int64]bool)
+	mark := func(s Sale) {
+		if _, id := splitCharacter(s.Character); s.MsgID != 0 && f.matches(id, s.Time) {
+			purged[s.MsgID] = true
+		}
+	}
+	exports, err := listExports(p.ctx, p.cfg.BaseDir, 0)
+	switch {
+	case err == nil:
+		parsed, _, err := parseAllExports(p.ctx, exports, p.cfg)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := mergeLiveTrades(p.cfg, parsed); err != nil {
+			return 0, err
+		}
+		for _, s := range parsed.Sales {
+			mark(s)
+		}
+	case !errors.Is(err, ErrExportNotFound):
+		return 0, err
+	}
+	_, stored, err := storedSales(p.ctx, p.cfg, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range stored {
+		mark(s)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	n := 0
+	for _, c := range corrections {
+		if purged[c.MsgID] {
+			n++
+			continue
+		}
+		if err := enc.Encode(c); err != nil {
+			return 0, err
+		}
+	}
+	if dryRun || n == 0 {
+		return n, nil
+	}
+	return n, writeFileAtomic(correctionsFile, buf.Bytes(), 0o644)
+}
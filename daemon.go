@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	daemonSocket      = "market.sock"
+	daemonDialTimeout = 300 * time.Millisecond
+)
+
+var daemonCommands = map[string]func(args []string) error{
+	"report":     cmdReport,
+	"trends":     cmdTrends,
+	"item":       cmdItem,
+	"paydays":    cmdPaydays,
+	"elasticity": cmdElasticity,
+	"ocr":        cmdOCR,
+}
+
+var flagErrorHandling = flag.ExitOnError
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flagErrorHandling)
+	if flagErrorHandling == flag.ContinueOnError {
+		fs.SetOutput(out)
+	}
+	return fs
+}
+
+type daemonRequest struct {
+	Args  []string `json:"args"`
+	Stdin []byte   `json:"stdin,omitempty"`
+}
+
+type daemonResponse struct {
+	Output string `json:"output"`
+	Error  string `json:"error,omitempty"`
+	Code   string `json:"code,omitempty"`
+}
+
+type daemon struct {
+	mu  sync.Mutex
+	rf  runFlags
+	ctx context.Context
+}
+
+func (d *daemon) locked(fn func() error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return fn()
+}
+
+func cmdDaemon(args []string) error {
+	fs := newFlagSet("daemon")
+	reportFlags := defineReportFlags(fs)
+	every := fs.Duration("report-every", 0, "дополнительно перестраивать отчёт с этим интервалом, например 1h (0 — только при изменении экспорта)")
+	noWatch := fs.Bool("no-watch", false, "не следить за base_dir")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	rf, err := reportFlags()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	ln, err := listenDaemon()
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	flagErrorHandling = flag.ContinueOnError
+	fmt.Fprintf(out, "Демон запущен: запросы принимаются через %s (Ctrl+C — выход)\n", daemonSocket)
+	flushOut()
+
+	ctx, stop := deferShutdown()
+	defer stop()
+	d := &daemon{rf: rf, ctx: ctx}
+	go d.serve(ln)
+	if cfg.Live != nil {
+		go func() {
+			if err := pollLive(ctx, cfg, false, d.locked); err != nil {
+				log.Printf("живой поток бота остановлен: %v", err)
+			}
+		}()
+	}
+	if *every > 0 {
+		go func() {
+			t := time.NewTicker(*every)
+			defer t.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-t.C:
+					d.refresh()
+				}
+			}
+		}()
+	}
+
+	base := cfg.BaseDir
+	if rf.arg != "" {
+		base = rf.arg
+	}
+	if *noWatch {
+		d.refresh()
+		<-ctx.Done()
+	} else {
+		warmExports = newParsedExports()
+		err = watchExportDir(ctx, base, d.locked, d.refresh, func() {
+			warmExports.reset()
+			d.refresh()
+		})
+	}
+	d.mu.Lock()
+	return err
+}
+
+func listenDaemon() (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", daemonSocket, daemonDialTimeout); err == nil {
+		conn.Close()
+		return nil, withKind(ErrStoreLocked, fmt.Errorf("демон уже запущен в этой папке (%s)", daemonSocket))
+	}
+	if err := os.Remove(daemonSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", daemonSocket)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть %s: %w", daemonSocket, err)
+	}
+	if err := os.Chmod(daemonSocket, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("не удалось ограничить доступ к %s: %w", daemonSocket, err)
+	}
+	return ln, nil
+}
+
+func (d *daemon) refresh() {
+	_ = d.locked(func() error {
+		restore := captureOutput(io.Discard)
+		run, err := d.rebuild()
+		restore()
+		stamp := time.Now().Format("15:04:05")
+		if err != nil && !errors.Is(err, errInterrupted) {
+			fmt.Fprintf(out, "%s ошибка обновления: %v\n", stamp, err)
+		} else if run != nil {
+			fmt.Fprintf(out, "%s данные обновлены: продаж %d, новых %d\n", stamp, len(run.sales), run.newSales)
+		}
+		flushOut()
+		return nil
+	})
+}
+
+func (d *daemon) rebuild() (*reportRun, error) {
+	timezoneOverride = d.rf.tz
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withReportTimeout(d.ctx, cfg)
+	defer cancel()
+	run, _, err := generateReport(ctx, cfg, d.rf)
+	return run, err
+}
+
+func (d *daemon) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *daemon) handle(conn net.Conn) {
+	defer conn.Close()
+	var req daemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	var resp daemonResponse
+	var cmd func(args []string) error
+	if len(req.Args) > 0 {
+		cmd = daemonCommands[req.Args[0]]
+	}
+	if cmd == nil {
+		resp.Error = "демон не выполняет эту команду"
+	} else {
+		var buf bytes.Buffer
+		err := d.locked(func() error {
+			restore := captureOutput(&buf)
+			defer restore()
+			prevStdin := stdin
+			stdin = bufio.NewReader(bytes.NewReader(req.Stdin))
+			defer func() { stdin = prevStdin }()
+			err := cmd(req.Args[1:])
+			flushOut()
+			return err
+		})
+		resp.Output = buf.String()
+		if err != nil {
+			resp.Error = err.Error()
+			resp.Code = errorCode(err)
+		}
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+func forwardToDaemon(args []string) (bool, error) {
+	conn, err := net.DialTimeout("unix", daemonSocket, daemonDialTimeout)
+	if err != nil {
+		return false, nil
+	}
+	defer conn.Close()
+	req := daemonRequest{Args: args}
+	if slices.Contains(args[1:], "-") {
+		if req.Stdin, err = io.ReadAll(stdin); err != nil {
+			return true, err
+		}
+		stdin = bufio.NewReader(bytes.NewReader(req.Stdin))
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return false, nil
+	}
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return true, fmt.Errorf("демон не ответил: %w", err)
+	}
+	io.WriteString(out, resp.Output)
+	if resp.Error != "" {
+		return true, errorFromCode(resp.Code, resp.Error)
+	}
+	return true, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Dashboard struct {
+	Name       string   `json:"name"`
+	Tags       []string `json:"tags,omitempty"`
+	Servers    []string `json:"servers,omitempty"`
+	Characters []string `json:"characters,omitempty"`
+	Widgets    []Widget `json:"widgets"`
+}
+
+type Widget struct {
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	Period   string `json:"period,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+	Item     string `json:"item,omitempty"`
+	Category string `json:"category,omitempty"`
+	Target   Money  `json:"target,omitempty"`
+	Quantity int    `json:"quantity,omitempty"`
+
+	period period
+}
+
+type siteDashboard struct {
+	Name    string       `json:"name"`
+	Anchor  string       `json:"anchor"`
+	Tags    []string     `json:"tags,omitempty"`
+	Widgets []siteWidget `json:"widgets"`
+}
+
+type siteWidget struct {
+	Type     string          `json:"type"`
+	Title    string          `json:"title"`
+	Rows     []siteWidgetRow `json:"rows,omitempty"`
+	Value    float64         `json:"value,omitempty"`
+	Target   float64         `json:"target,omitempty"`
+	Progress float64         `json:"progress,omitempty"`
+	Quantity bool            `json:"quantity,omitempty"`
+}
+
+type siteWidgetRow struct {
+	Label string  `json:"label"`
+	Count int     `json:"count,omitempty"`
+	Value float64 `json:"value"`
+	Bar   float64 `json:"bar"`
+}
+
+const defaultWidgetLimit = 5
+
+var widgetBuilders = map[string]func(w Widget, sales []Sale, cfg *Config, now time.Time) siteWidget{
+	"top_items":     buildTopItemsWidget,
+	"revenue_chart": buildRevenueChartWidget,
+	"leaderboard":   buildLeaderboardWidget,
+	"goal":          buildGoalWidget,
+}
+
+func (d *Dashboard) validate(cfg *Config) error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("не указано name")
+	}
+	if len(d.Widgets) == 0 {
+		return fmt.Errorf("«%s»: нет виджетов", d.Name)
+	}
+	for i := range d.Widgets {
+		w := &d.Widgets[i]
+		if _, ok := widgetBuilders[w.Type]; !ok {
+			return fmt.Errorf("«%s», виджет #%d: неизвестный тип %q (допустимо: top_items, revenue_chart, leaderboard, goal)", d.Name, i+1, w.Type)
+		}
+		spec := w.Period
+		if spec == "" {
+			spec = "month"
+		}
+		ps, err := cfg.parsePeriods(spec)
+		if err != nil {
+			return fmt.Errorf("«%s», виджет #%d: %w", d.Name, i+1, err)
+		}
+		w.period = ps[0]
+		if w.Type == "goal" && w.Target <= 0 && w.Quantity <= 0 {
+			return fmt.Errorf("«%s», виджет #%d: для goal нужна положительная цель target или quantity", d.Name, i+1)
+		}
+		if w.Category != "" {
+			if _, ok := cfg.Categories[w.Category]; !ok {
+				return fmt.Errorf("«%s», виджет #%d: категория «%s» не описана в categories", d.Name, i+1, w.Category)
+			}
+		}
+		if w.Item != "" {
+			w.Item = cfg.canonicalItem(strings.TrimSpace(w.Item))
+		}
+		if w.Limit <= 0 {
+			w.Limit = defaultWidgetLimit
+		}
+	}
+	return nil
+}
+
+func (d *Dashboard) matches(s Sale) bool {
+	if len(d.Servers) > 0 && !slices.Contains(d.Servers, s.Server) {
+		return false
+	}
+	if len(d.Characters) == 0 {
+		return true
+	}
+	name, _ := splitCharacter(s.Character)
+	for _, c := range d.Characters {
+		if strings.EqualFold(c, s.Character) || strings.EqualFold(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func buildDashboards(sales []Sale, cfg *Config, now time.Time) []siteDashboard {
+	var res []siteDashboard
+	for i := range cfg.Dashboards {
+		d := &cfg.Dashboards[i]
+		var own []Sale
+		for _, s := range sales {
+			if d.matches(s) {
+				own = append(own, s)
+			}
+		}
+		sd := siteDashboard{Name: d.Name, Anchor: "dashboard-" + strings.Join(strings.Fields(strings.ToLower(d.Name)), "-"), Tags: d.Tags}
+		for _, w := range d.Widgets {
+			sw := widgetBuilders[w.Type](w, own, cfg, now)
+			if w.Title != "" {
+				sw.Title = w.Title
+			}
+			sw.Type = w.Type
+			sd.Widgets = append(sd.Widgets, sw)
+		}
+		res = append(res, sd)
+	}
+	return res
+}
+
+func widgetSales(w Widget, sales []Sale, now time.Time) []Sale {
+	var res []Sale
+	for _, s := range sales {
+		if s.Time.After(now) || !w.period.contains(s.Time, now) || w.Item != "" && s.Item != w.Item {
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+func rankedRows(sums map[string]*ItemStats, limit int) []siteWidgetRow {
+	rows := make([]siteWidgetRow, 0, len(sums))
+	for label, st := range sums {
+		rows = append(rows, siteWidgetRow{Label: label, Count: st.Count, Value: st.Sum.Float()})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Value != rows[j].Value {
+			return rows[i].Value > rows[j].Value
+		}
+		return rows[i].Label < rows[j].Label
+	})
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	scaleBars(rows)
+	return rows
+}
+
+func scaleBars(rows []siteWidgetRow) {
+	var top float64
+	for _, r := range rows {
+		top = max(top, r.Value)
+	}
+	for i := range rows {
+		if top > 0 {
+			rows[i].Bar = rows[i].Value / top * 100
+		}
+	}
+}
+
+func buildTopItemsWidget(w Widget, sales []Sale, cfg *Config, now time.Time) siteWidget {
+	sums := make(map[string]*ItemStats)
+	for _, s := range widgetSales(w, sales, now) {
+		st := sums[s.Item]
+		if st == nil {
+			st = &ItemStats{}
+			sums[s.Item] = st
+		}
+		st.Count += s.Quantity
+		st.Sum += s.Price
+	}
+	return siteWidget{Title: fmt.Sprintf("Топ-%d предметов (%s)", w.Limit, w.period.name), Rows: rankedRows(sums, w.Limit)}
+}
+
+func buildLeaderboardWidget(w Widget, sales []Sale, cfg *Config, now time.Time) siteWidget {
+	sums := make(map[string]*ItemStats)
+	for _, s := range widgetSales(w, sales, now) {
+		key := s.Server + " / " + s.Character
+		st := sums[key]
+		if st == nil {
+			st = &ItemStats{}
+			sums[key] = st
+		}
+		st.Count++
+		st.Sum += s.Price
+	}
+	return siteWidget{Title: fmt.Sprintf("Рейтинг персонажей (%s)", w.period.name), Rows: rankedRows(sums, w.Limit)}
+}
+
+func buildRevenueChartWidget(w Widget, sales []Sale, cfg *Config, now time.Time) siteWidget {
+	byDay := make(map[string]Money)
+	var first time.Time
+	for _, s := range widgetSales(w, sales, now) {
+		byDay[s.Time.Format("2006-01-02")] += s.Price
+		if first.IsZero() || s.Time.Before(first) {
+			first = s.Time
+		}
+	}
+	title := "Выручка по дням (" + w.period.name + ")"
+	if w.Item != "" {
+		title = "Выручка по дням: " + w.Item + " (" + w.period.name + ")"
+	}
+	sw := siteWidget{Title: title}
+	if first.IsZero() {
+		return sw
+	}
+	start := w.period.start(now)
+	if start.IsZero() {
+		start = first
+	}
+	for d := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, now.Location()); !d.After(now); d = d.AddDate(0, 0, 1) {
+		sw.Rows = append(sw.Rows, siteWidgetRow{Label: formatDate(d, cfg.Language), Value: byDay[d.Format("2006-01-02")].Float()})
+	}
+	scaleBars(sw.Rows)
+	return sw
+}
+
+func buildGoalWidget(w Widget, sales []Sale, cfg *Config, now time.Time) siteWidget {
+	g := Goal{Item: w.Item, Category: w.Category, Revenue: w.Target, Quantity: w.Quantity, period: w.period}
+	if g.Quantity > 0 {
+		g.Revenue = 0
+	}
+	p := g.progress(cfg, sales, now)
+	title := "Цель: " + g.label() + " (" + w.period.name + ")"
+	if g.Quantity == 0 && g.Item == "" && g.Category == "" {
+		title = "Цель по выручке (" + w.period.name + ")"
+	}
+	return siteWidget{Title: title, Value: p.Done, Target: p.Target, Progress: p.Percent, Quantity: g.Quantity > 0}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+type demoItem struct {
+	name  string
+	raw   []string
+	price float64
+}
+
+var demoItems = []demoItem{
+	{"Адреналин", []string{"Адреналин", "Улучшенный эпинефрин"}, 48_000},
+	{"HK MP5‑SD", []string{"HK MP5‑SD"}, 260_000},
+	{"Фиолетовая карточка", []string{"Фиолетовая карточка"}, 95_000},
+	{"Бронежилет 6Б43", []string{"Бронежилет 6Б43"}, 410_000},
+}
+
+var demoServers = []struct {
+	name  string
+	chars []string
+}{
+	{"Atlanta", []string{"Северный Ветер #104211", "Тихая Гавань #118734"}},
+	{"Detroit", []string{"Северный Ветер #220519", "Ржавый Якорь #231006", "Полночь #240090"}},
+}
+
+func demoConfig(now time.Time) (*Config, error) {
+	cfg := &Config{
+		BaseDir:         "(демо)",
+		Selected:        []string{"Адреналин", "HK MP5‑SD", "Фиолетовая карточка"},
+		ServerTimezones: map[string]string{"Atlanta": "America/New_York", "Detroit": "America/Detroit"},
+		Basket:          map[string]float64{"Адреналин": 3, "HK MP5‑SD": 1},
+		GuildPool:       &GuildPool{Percent: 5},
+		Stock: &StockConfig{Items: []StockEntry{
+			{Item: "Адреналин", Quantity: 60, Date: now.AddDate(0, 0, -10).Format("2006-01-02")},
+		}},
+	}
+	if _, err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func demoSales(now time.Time) []Sale {
+	rng := rand.New(rand.NewPCG(489, 2026))
+	var sales []Sale
+	start := now.AddDate(0, 0, -60)
+	for _, srv := range demoServers {
+		for ci, char := range srv.chars {
+			activity := 0.35 + 0.15*float64(ci)
+			for day := 0; day < 60; day++ {
+				if rng.Float64() > activity {
+					continue
+				}
+				for range 1 + rng.IntN(3) {
+					it := demoItems[rng.IntN(len(demoItems))]
+					qty := 1
+					if it.price < 100_000 {
+						qty = 1 + rng.IntN(5)
+					}
+					trend := 1 + 0.004*float64(day)
+					price := math.Round(it.price*trend*(0.85+0.3*rng.Float64())/100) * 100
+					sales = append(sales, Sale{
+						Time:      start.AddDate(0, 0, day).Add(time.Duration(rng.IntN(24*60)) * time.Minute),
+						Server:    srv.name,
+						Character: char,
+						Item:      it.name,
+						RawItem:   it.raw[rng.IntN(len(it.raw))],
+						Quantity:  qty,
+						Price:     moneyFromFloat(price * float64(qty)),
+					})
+				}
+			}
+		}
+	}
+	return salesAsOf(sales, now)
+}
+
+func runDemo(rf runFlags) error {
+	now := time.Now()
+	cfg, err := demoConfig(now)
+	if err != nil {
+		return err
+	}
+	if err := rf.resolvePeriods(cfg); err != nil {
+		return err
+	}
+	opts, recent, siteDir := rf.opts, rf.recent, rf.siteDir
+	sales := demoSales(now)
+	if opts.leaderboard == nil {
+		opts.leaderboard = &allPeriods[2]
+	}
+	if opts.compare == nil {
+		opts.compare = &allPeriods[2]
+	}
+	if recent <= 0 {
+		recent = 5
+	}
+
+	fmt.Fprintln(out, "Демонстрационный режим: данные сгенерированы, настройки и состояние не используются.")
+	if err := printReport(sales, nil, nil, nil, cfg, opts, now); err != nil {
+		return err
+	}
+	printRecentSales(sales, cfg, recent)
+	printPriceIndex(cfg, sales)
+	printAliasCheck(sales, cfg.Language)
+	printStockReminders(cfg, sales, now)
+	if siteDir != "" {
+		if err := writeSite(siteDir, sales, nil, cfg, now); err != nil {
+			return fmt.Errorf("не удалось сохранить сайт: %w", err)
+		}
+		fmt.Fprintf(out, "\nСтатический отчёт сохранён в %s\n", siteDir)
+	}
+	return nil
+}
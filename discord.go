@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const eventTopSeller = "top_seller"
+
+func buildDiscordUsers(users map[string]string) (map[string]string, error) {
+	res := make(map[string]string, len(users))
+	for character, user := range users {
+		id := retiredID(character)
+		user = strings.TrimPrefix(strings.TrimSpace(user), "@")
+		if id == "" || user == "" {
+			return nil, fmt.Errorf("discord_users: нужна пара «ID персонажа»: «пользователь Discord», получено %q: %q", character, user)
+		}
+		res[id] = user
+	}
+	return res, nil
+}
+
+func isDiscordUserID(user string) bool {
+	if len(user) < 17 {
+		return false
+	}
+	for _, r := range user {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func discordMention(user string) string {
+	if isDiscordUserID(user) {
+		return "<@" + user + ">"
+	}
+	return "@" + user
+}
+
+func (cfg *Config) discordUser(character string) string {
+	if len(cfg.discordUsers) == 0 {
+		return ""
+	}
+	if _, id := splitCharacter(character); id != "" {
+		return cfg.discordUsers[id]
+	}
+	return cfg.discordUsers[retiredID(character)]
+}
+
+func emitTopSeller(cfg *Config, st *appState, sales []Sale, now time.Time) {
+	today := calendarStart("day", now)
+	day := today.AddDate(0, 0, -1).Format("2006-01-02")
+	if st.TopSellerDay == day {
+		return
+	}
+	st.TopSellerDay = day
+	if st.fresh {
+		return
+	}
+	yesterday := period{name: "yesterday", since: today.AddDate(0, 0, -1), until: today}
+	servers := aggregateSales(sales, now, yesterday)
+	var best *Character
+	bestServer := ""
+	for _, srvName := range sortedServerKeys(servers, cfg.Language) {
+		for _, id := range sortedCharIDs(servers[srvName], cfg.Language) {
+			ch := servers[srvName].Characters[id]
+			if best == nil || ch.Revenue() > best.Revenue() {
+				best, bestServer = ch, srvName
+			}
+		}
+	}
+	if best == nil {
+		return
+	}
+	emit(cfg, hookEvent{Name: eventTopSeller, Time: now, Data: map[string]string{
+		"date":      day,
+		"server":    bestServer,
+		"character": best.Name,
+		"id":        best.ID,
+		"revenue":   fmt.Sprintf("%.2f", best.Revenue()),
+		"sales":     fmt.Sprint(best.Sales),
+	}})
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type priceBand struct {
+	From, To float64
+	Sales    int
+	Units    int
+	Revenue  Money
+	Days     map[string]bool
+}
+
+func (b *priceBand) unitsPerDay() float64 {
+	if len(b.Days) == 0 {
+		return 0
+	}
+	return float64(b.Units) / float64(len(b.Days))
+}
+
+func (b *priceBand) mid() float64 { return (b.From + b.To) / 2 }
+
+func priceBands(sales []Sale, item string, n int) []*priceBand {
+	var units []float64
+	var picked []Sale
+	for _, s := range sales {
+		if s.Item == item && s.Quantity > 0 {
+			picked = append(picked, s)
+			units = append(units, s.unitPrice())
+		}
+	}
+	if len(picked) == 0 {
+		return nil
+	}
+	lo, hi := units[0], units[0]
+	for _, u := range units {
+		lo, hi = math.Min(lo, u), math.Max(hi, u)
+	}
+	if hi == lo {
+		n = 1
+	}
+	width := (hi - lo) / float64(n)
+	bands := make([]*priceBand, n)
+	for i := range bands {
+		bands[i] = &priceBand{From: lo + width*float64(i), To: lo + width*float64(i+1), Days: make(map[string]bool)}
+	}
+	bands[n-1].To = hi
+	for i, s := range picked {
+		k := n - 1
+		if width > 0 {
+			k = min(int((units[i]-lo)/width), n-1)
+		}
+		b := bands[k]
+		b.Sales++
+		b.Units += s.Quantity
+		b.Revenue += s.Price
+		b.Days[s.Time.Format("2006-01-02")] = true
+	}
+	return bands
+}
+
+func cmdElasticity(args []string) error {
+	fs := newFlagSet("elasticity")
+	bandsN := fs.Int("bands", 5, "число ценовых диапазонов")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *bandsN < 1 {
+		return errors.New("--bands должно быть не меньше 1")
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	ctx, stop := runContext(cfg)
+	defer stop()
+	sales, err := loadLatestSales(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	items := cfg.selected
+	if name := strings.TrimSpace(strings.Join(fs.Args(), " ")); name != "" {
+		item, ok := findSoldItem(cfg, sales, name)
+		if !ok {
+			return fmt.Errorf("продаж предмета «%s» не найдено", name)
+		}
+		items = []string{item}
+	}
+	for _, item := range items {
+		bands := priceBands(sales, item, *bandsN)
+		if bands == nil {
+			fmt.Fprintf(out, "\n%s: продаж нет\n", item)
+			continue
+		}
+		printElasticity(item, bands)
+	}
+	return nil
+}
+
+func printElasticity(item string, bands []*priceBand) {
+	fmt.Fprintf(out, "\nЦеновые диапазоны: %s\n", item)
+	w := newTable()
+	fmt.Fprintln(w, "Цена за штуку\tПродаж\tШтук\tДней\tШтук в день\tВыручка\tЭластичность")
+	var peak, best *priceBand
+	var prev *priceBand
+	for _, b := range bands {
+		elasticity := "-"
+		if b.Sales > 0 {
+			if prev != nil && prev.unitsPerDay() > 0 {
+				dq := (b.unitsPerDay() - prev.unitsPerDay()) / ((b.unitsPerDay() + prev.unitsPerDay()) / 2)
+				dp := (b.mid() - prev.mid()) / ((b.mid() + prev.mid()) / 2)
+				if dp != 0 {
+					elasticity = fmt.Sprintf("%.2f", dq/dp)
+				}
+			}
+			prev = b
+			if peak == nil || b.unitsPerDay() > peak.unitsPerDay() {
+				peak = b
+			}
+			if best == nil || b.Revenue > best.Revenue {
+				best = b
+			}
+		}
+		fmt.Fprintf(w, "$%.2f–$%.2f\t%d\t%d\t%d\t%.1f\t$%.2f\t%s\n", b.From, b.To, b.Sales, b.Units, len(b.Days), b.unitsPerDay(), b.Revenue, elasticity)
+	}
+	w.Flush()
+
+	if len(bands) == 1 {
+		fmt.Fprintln(out, "    Цена не менялась — оценить спрос при других ценах нельзя")
+		return
+	}
+	fmt.Fprintf(out, "    Наибольший спрос: $%.2f–$%.2f (%.1f шт. в день)\n", peak.From, peak.To, peak.unitsPerDay())
+	fmt.Fprintf(out, "    Наибольшая выручка: $%.2f–$%.2f ($%.2f)\n", best.From, best.To, best.Revenue)
+	for _, b := range bands {
+		if b.From > peak.From && b.Sales > 0 && b.unitsPerDay() < peak.unitsPerDay()/2 {
+			fmt.Fprintf(out, "    Спрос падает вдвое и сильнее начиная с $%.2f за штуку\n", b.From)
+			return
+		}
+	}
+	fmt.Fprintln(out, "    Заметного падения спроса с ростом цены не видно")
+}
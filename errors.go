@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+)
+
+var (
+	ErrExportNotFound   = errors.New("экспорт не найден")
+	ErrMalformedMessage = errors.New("сообщение бота не по формату")
+	ErrStoreLocked      = errors.New("данные заняты другим процессом")
+)
+
+var errorKinds = []struct {
+	err  error
+	code string
+	exit int
+}{
+	{ErrExportNotFound, "export_not_found", exitExportMissing},
+	{ErrMalformedMessage, "malformed_message", exitParseWarnings},
+	{ErrStoreLocked, "store_locked", exitStoreLocked},
+	{ErrTimeout, "timeout", exitTimeout},
+	{errInterrupted, "interrupted", exitInterrupted},
+}
+
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+func withKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+func errorCode(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.code
+		}
+	}
+	return ""
+}
+
+func exitCode(err error) int {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.exit
+		}
+	}
+	return 1
+}
+
+func errorFromCode(code, msg string) error {
+	for _, k := range errorKinds {
+		if k.code == code {
+			return withKind(k.err, errors.New(msg))
+		}
+	}
+	return errors.New(msg)
+}
+
+func fatal(err error) {
+	log.Print(err)
+	flushOut()
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const exportCacheFile = "exports_cache.json"
+
+var exportRe = regexp.MustCompile(`^ChatExport_(\d{4}-\d{2}-\d{2})(?: \((\d+)\))?(?i:\.zip)?$`)
+
+type exportInfo struct {
+	Path    string    `json:"path"`
+	Date    time.Time `json:"date"`
+	Variant int       `json:"variant"`
+}
+
+type exportCache struct {
+	BaseDir string       `json:"base_dir"`
+	ModTime time.Time    `json:"mod_time"`
+	Exports []exportInfo `json:"exports"`
+}
+
+func listExports(ctx context.Context, base string, max int) ([]exportInfo, error) {
+	st, err := guardIO(ctx, base, func() (os.FileInfo, error) { return os.Stat(base) })
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, withKind(ErrExportNotFound, fmt.Errorf("не удалось открыть %s: %w", base, err))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть %s: %w", base, err)
+	}
+	if cached, ok := loadExportCache(base, st.ModTime()); ok {
+		return limitExports(cached, max, base)
+	}
+
+	entries, err := guardIO(ctx, base, func() ([]os.DirEntry, error) { return os.ReadDir(base) })
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть %s: %w", base, err)
+	}
+	var exports []exportInfo
+	for _, e := range entries {
+		m := exportRe.FindStringSubmatch(e.Name())
+		if len(m) == 0 {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", m[1])
+		if err != nil {
+			continue
+		}
+		p := filepath.Join(base, e.Name())
+		if !e.IsDir() {
+			if !hasZipExt(p) && e.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			ok, err := guardIO(ctx, p, func() (bool, error) {
+				info, err := os.Stat(p)
+				return err == nil && (info.IsDir() || hasZipExt(p)), nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		v := 0
+		if m[2] != "" {
+			v, _ = strconv.Atoi(m[2])
+		}
+		exports = append(exports, exportInfo{Path: p, Date: d, Variant: v})
+	}
+	sortExports(exports)
+	saveExportCache(exportCache{BaseDir: base, ModTime: st.ModTime(), Exports: exports})
+	return limitExports(exports, max, base)
+}
+
+func limitExports(exports []exportInfo, max int, base string) ([]exportInfo, error) {
+	if len(exports) == 0 {
+		return nil, withKind(ErrExportNotFound, fmt.Errorf("не найдено ни одной папки ChatExport_* в %s", base))
+	}
+	if max > 0 && len(exports) > max {
+		exports = exports[:max]
+	}
+	return exports, nil
+}
+
+func sortExports(exports []exportInfo) {
+	sort.Slice(exports, func(i, j int) bool {
+		if !exports[i].Date.Equal(exports[j].Date) {
+			return exports[i].Date.After(exports[j].Date)
+		}
+		if exports[i].Variant != exports[j].Variant {
+			return exports[i].Variant > exports[j].Variant
+		}
+		return !hasZipExt(exports[i].Path) && hasZipExt(exports[j].Path)
+	})
+}
+
+func loadExportCache(base string, modTime time.Time) ([]exportInfo, bool) {
+	data, err := storage.Get(exportCacheFile)
+	if err != nil {
+		return nil, false
+	}
+	var c exportCache
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, false
+	}
+	if c.BaseDir != base || !c.ModTime.Equal(modTime) {
+		return nil, false
+	}
+	return c.Exports, true
+}
+
+func saveExportCache(c exportCache) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = storage.Put(exportCacheFile, data)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	forecastWindowDays = 7
+	forecastKeepDays   = 90
+)
+
+type forecastEntry struct {
+	Date      string  `json:"date"`
+	Item      string  `json:"item"`
+	Predicted float64 `json:"predicted"`
+}
+
+func dailyItemRevenue(sales []Sale) map[string]map[string]Money {
+	res := make(map[string]map[string]Money)
+	for _, s := range sales {
+		day := s.Time.In(time.Local).Format("2006-01-02")
+		if res[day] == nil {
+			res[day] = make(map[string]Money)
+		}
+		res[day][s.Item] += s.Price
+	}
+	return res
+}
+
+func forecastRevenue(daily map[string]map[string]Money, item string, now time.Time) float64 {
+	var sum Money
+	for i := 1; i <= forecastWindowDays; i++ {
+		sum += daily[now.AddDate(0, 0, -i).Format("2006-01-02")][item]
+	}
+	return sum.Float() / forecastWindowDays
+}
+
+func printForecast(cfg *Config, st *appState, sales []Sale, now time.Time) {
+	if !cfg.Forecast {
+		return
+	}
+	daily := dailyItemRevenue(sales)
+	today := now.Format("2006-01-02")
+	tomorrow := now.AddDate(0, 0, 1).Format("2006-01-02")
+	oldest := now.AddDate(0, 0, -forecastKeepDays).Format("2006-01-02")
+
+	type accuracy struct {
+		sum   float64
+		count int
+	}
+	scores := make(map[string]*accuracy)
+	kept := st.Forecasts[:0]
+	for _, f := range st.Forecasts {
+		if f.Date < oldest || f.Date == tomorrow {
+			continue
+		}
+		kept = append(kept, f)
+		actual := daily[f.Date][f.Item].Float()
+		if f.Date >= today || actual == 0 {
+			continue
+		}
+		a := scores[f.Item]
+		if a == nil {
+			a = &accuracy{}
+			scores[f.Item] = a
+		}
+		a.sum += math.Abs(actual-f.Predicted) / actual
+		a.count++
+	}
+	st.Forecasts = kept
+
+	fmt.Fprintf(out, "\nПрогноз выручки на завтра (среднее за %d дн.) и его точность:\n", forecastWindowDays)
+	w := newTable()
+	fmt.Fprintln(w, "    Предмет\tПрогноз\tПроверено дней\tСредняя ошибка (MAPE)")
+	for _, item := range cfg.selected {
+		predicted := forecastRevenue(daily, item, now)
+		st.Forecasts = append(st.Forecasts, forecastEntry{Date: tomorrow, Item: item, Predicted: predicted})
+		mape := "-"
+		checked := 0
+		if a := scores[item]; a != nil {
+			checked = a.count
+			mape = fmt.Sprintf("%.1f%%", a.sum/float64(a.count)*100)
+		}
+		fmt.Fprintf(w, "    %s\t$%.2f\t%d\t%s\n", item, predicted, checked, mape)
+	}
+	w.Flush()
+}
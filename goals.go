@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Goal struct {
+	Title     string  `json:"title,omitempty"`
+	Period    string  `json:"period,omitempty"`
+	Item      string  `json:"item,omitempty"`
+	Category  string  `json:"category,omitempty"`
+	Character string  `json:"character,omitempty"`
+	Revenue   Money   `json:"revenue,omitempty"`
+	Quantity  int     `json:"quantity,omitempty"`
+	Weight    float64 `json:"weight,omitempty"`
+
+	period period
+}
+
+type goalProgress struct {
+	Goal    *Goal
+	Done    float64
+	Target  float64
+	Percent float64
+}
+
+func validateCategories(cfg *Config) error {
+	cfg.itemCategories = make(map[string]string)
+	for name, items := range cfg.Categories {
+		if strings.TrimSpace(name) == "" {
+			return errors.New("categories: пустое название категории")
+		}
+		for _, it := range items {
+			it = cfg.canonicalItem(strings.TrimSpace(it))
+			if prev, ok := cfg.itemCategories[it]; ok && prev != name {
+				return fmt.Errorf("categories: «%s» входит и в «%s», и в «%s»", it, prev, name)
+			}
+			cfg.itemCategories[it] = name
+		}
+	}
+	return nil
+}
+
+func (g *Goal) validate(cfg *Config) error {
+	spec := g.Period
+	if spec == "" {
+		spec = "week"
+	}
+	ps, err := cfg.parsePeriods(spec)
+	if err != nil {
+		return err
+	}
+	g.period = ps[0]
+	switch {
+	case g.Revenue < 0 || g.Quantity < 0 || g.Weight < 0:
+		return errors.New("revenue, quantity и weight не могут быть отрицательными")
+	case g.Revenue > 0 && g.Quantity > 0:
+		return errors.New("укажите что-то одно: revenue или quantity")
+	case g.Revenue == 0 && g.Quantity == 0:
+		return errors.New("не указана цель: revenue или quantity")
+	case g.Item != "" && g.Category != "":
+		return errors.New("укажите что-то одно: item или category")
+	}
+	g.Character = strings.TrimSpace(g.Character)
+	if g.Item != "" {
+		g.Item = cfg.canonicalItem(strings.TrimSpace(g.Item))
+	}
+	if g.Category != "" {
+		if _, ok := cfg.Categories[g.Category]; !ok {
+			return fmt.Errorf("категория «%s» не описана в categories", g.Category)
+		}
+	}
+	return nil
+}
+
+func (g *Goal) weight() float64 {
+	if g.Weight > 0 {
+		return g.Weight
+	}
+	return 1
+}
+
+func (g *Goal) label() string {
+	if g.Title != "" {
+		return g.Title
+	}
+	subject := "Все предметы"
+	switch {
+	case g.Item != "":
+		subject = g.Item
+	case g.Category != "":
+		subject = "Категория «" + g.Category + "»"
+	}
+	if g.Character != "" {
+		subject = g.Character + ": " + subject
+	}
+	if g.Quantity > 0 {
+		return subject + ", шт."
+	}
+	return subject + ", выручка"
+}
+
+func (g *Goal) progress(cfg *Config, sales []Sale, now time.Time) goalProgress {
+	p := goalProgress{Goal: g, Target: g.Revenue.Float()}
+	if g.Quantity > 0 {
+		p.Target = float64(g.Quantity)
+	}
+	var revenue Money
+	for _, s := range sales {
+		if s.Time.After(now) || !g.period.contains(s.Time, now) {
+			continue
+		}
+		if g.Item != "" && s.Item != g.Item || g.Category != "" && cfg.itemCategories[s.Item] != g.Category || !g.matchesCharacter(s.Character) {
+			continue
+		}
+		if g.Quantity > 0 {
+			p.Done += float64(s.Quantity)
+		} else {
+			revenue += s.Price
+		}
+	}
+	if g.Quantity == 0 {
+		p.Done = revenue.Float()
+	}
+	p.Percent = min(p.Done/p.Target*100, 100)
+	return p
+}
+
+func (g *Goal) matchesCharacter(character string) bool {
+	if g.Character == "" {
+		return true
+	}
+	_, id := splitCharacter(character)
+	return character == g.Character || id == strings.TrimPrefix(g.Character, "#")
+}
+
+func (g *Goal) key() string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%d", g.Item, g.Category, g.Character, g.period.name, g.Revenue, g.Quantity)
+}
+
+func (p goalProgress) values() (done, target string) {
+	if p.Goal.Quantity > 0 {
+		return fmt.Sprintf("%.0f", p.Done), fmt.Sprintf("%.0f", p.Target)
+	}
+	return fmt.Sprintf("$%.2f", p.Done), fmt.Sprintf("$%.2f", p.Target)
+}
+
+func emitGoalEvents(cfg *Config, st *appState, sales []Sale, now time.Time) {
+	var reached []string
+	for i := range cfg.Goals {
+		g := &cfg.Goals[i]
+		p := g.progress(cfg, sales, now)
+		if p.Percent < 100 {
+			continue
+		}
+		key := g.key()
+		reached = append(reached, key)
+		if st.fresh || slices.Contains(st.ReachedGoals, key) {
+			continue
+		}
+		done, target := p.values()
+		_, id := splitCharacter(g.Character)
+		if id == "" {
+			id = retiredID(g.Character)
+		}
+		emit(cfg, hookEvent{Name: eventGoalReached, Time: now, Data: map[string]string{
+			"goal":      g.label(),
+			"period":    g.period.name,
+			"character": g.Character,
+			"id":        id,
+			"done":      done,
+			"target":    target,
+		}})
+	}
+	st.ReachedGoals = reached
+}
+
+func notifyGoals(cfg *Config) {
+	if len(cfg.Goals) == 0 {
+		return
+	}
+	ctx, stop := runContext(cfg)
+	defer stop()
+	sales, err := loadLatestSales(ctx, cfg)
+	if err != nil {
+		log.Printf("не удалось проверить цели: %v", err)
+		return
+	}
+	st, err := loadState()
+	if err != nil {
+		log.Printf("не удалось проверить цели: %v", err)
+		return
+	}
+	emitGoalEvents(cfg, st, sales, time.Now())
+	if err := st.save(); err != nil {
+		log.Printf("не удалось сохранить %s: %v", stateFile, err)
+	}
+}
+
+func weightedGoalProgress(progress []goalProgress) float64 {
+	var sum, weights float64
+	for _, p := range progress {
+		sum += p.Percent * p.Goal.weight()
+		weights += p.Goal.weight()
+	}
+	if weights == 0 {
+		return 0
+	}
+	return sum / weights
+}
+
+func printGoals(cfg *Config, sales []Sale, now time.Time) {
+	if len(cfg.Goals) == 0 {
+		return
+	}
+	progress := make([]goalProgress, len(cfg.Goals))
+	for i := range cfg.Goals {
+		progress[i] = cfg.Goals[i].progress(cfg, sales, now)
+	}
+	fmt.Fprintln(out, "\nЦели:")
+	w := newTable()
+	fmt.Fprintln(w, "Цель\tПериод\tСделано\tНужно\tПрогресс\tВес")
+	for _, p := range progress {
+		done, target := p.values()
+		mark := ""
+		if p.Percent >= 100 {
+			mark = " ✓"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.0f%%%s\t%g\n", p.Goal.label(), p.Goal.period.name, done, target, p.Percent, mark, p.Goal.weight())
+	}
+	w.Flush()
+	if len(progress) > 1 {
+		fmt.Fprintf(out, "    Общий прогресс с учётом весов: %.0f%%\n", weightedGoalProgress(progress))
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type GuildPool struct {
+	Percent       float64             `json:"percent,omitempty"`
+	Contributions []GuildContribution `json:"contributions,omitempty"`
+}
+
+type GuildContribution struct {
+	Server    string `json:"server"`
+	Character string `json:"character"`
+	Amount    Money  `json:"amount"`
+	Date      string `json:"date"`
+
+	time time.Time
+}
+
+func (g *GuildPool) validate() error {
+	for i := range g.Contributions {
+		c := &g.Contributions[i]
+		t, err := time.ParseInLocation("2006-01-02", c.Date, time.Local)
+		if err != nil {
+			return fmt.Errorf("взнос в казну #%d: дата %q не в формате 2006-01-02", i+1, c.Date)
+		}
+		c.time = t
+	}
+	return nil
+}
+
+func (g *GuildPool) contribution(server string, ch *Character, now time.Time, p period) Money {
+	if g == nil {
+		return 0
+	}
+	sum := moneyFromFloat(ch.Revenue().Float() * g.Percent / 100)
+	for _, c := range g.Contributions {
+		if c.Server != server || c.Character != ch.ID {
+			continue
+		}
+		if !p.contains(c.time, now) {
+			continue
+		}
+		sum += c.Amount
+	}
+	return sum
+}
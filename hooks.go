@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	eventIngestFinished    = "ingest_finished"
+	eventDailyRevenueAbove = "daily_revenue_above"
+	eventNewItemSeen       = "new_item_seen"
+	eventGoalReached       = "goal_reached"
+)
+
+type Hook struct {
+	Event     string `json:"event"`
+	Command   string `json:"command"`
+	Threshold Money  `json:"threshold,omitempty"`
+}
+
+type hookEvent struct {
+	Name string            `json:"event"`
+	Time time.Time         `json:"time"`
+	Data map[string]string `json:"data"`
+}
+
+func emitEvents(cfg *Config, st *appState, sales []Sale, now time.Time) {
+	known := make(map[string]bool, len(st.KnownItems))
+	for _, it := range st.KnownItems {
+		known[it] = true
+	}
+	var newItems []string
+	for _, s := range sales {
+		if !known[s.Item] {
+			known[s.Item] = true
+			newItems = append(newItems, s.Item)
+		}
+	}
+	sort.Strings(newItems)
+	if !st.fresh {
+		for _, it := range newItems {
+			emit(cfg, hookEvent{Name: eventNewItemSeen, Time: now, Data: map[string]string{"item": it}})
+		}
+	}
+	st.KnownItems = append(st.KnownItems, newItems...)
+
+	today := now.Format("2006-01-02")
+	servers := aggregateSales(sales, now, calendarPeriods[0])
+	for _, threshold := range revenueThresholds(cfg) {
+		for _, srvName := range sortedServerKeys(servers, cfg.Language) {
+			for _, id := range sortedCharIDs(servers[srvName], cfg.Language) {
+				ch := servers[srvName].Characters[id]
+				revenue := ch.Revenue()
+				key := fmt.Sprintf("%s/%s/%.0f", srvName, id, threshold)
+				if revenue <= threshold || st.RevenueAlerts[key] == today {
+					continue
+				}
+				st.RevenueAlerts[key] = today
+				emit(cfg, hookEvent{Name: eventDailyRevenueAbove, Time: now, Data: map[string]string{
+					"server":    srvName,
+					"character": ch.Name,
+					"id":        ch.ID,
+					"revenue":   fmt.Sprintf("%.2f", revenue),
+					"threshold": fmt.Sprintf("%.2f", threshold),
+				}})
+			}
+		}
+	}
+
+	emitGoalEvents(cfg, st, sales, now)
+	emitTopSeller(cfg, st, sales, now)
+
+	emit(cfg, hookEvent{Name: eventIngestFinished, Time: now, Data: map[string]string{
+		"sales":     fmt.Sprint(len(sales)),
+		"new_items": joinTop(newItems, cfg),
+	}})
+}
+
+func joinTop(items []string, cfg *Config) string {
+	shown, hidden := topRows(items, cfg.TopN)
+	res := strings.Join(shown, ", ")
+	if hidden > 0 {
+		res += " " + moreRowsNote(hidden, cfg)
+	}
+	return res
+}
+
+func revenueThresholds(cfg *Config) []Money {
+	var res []Money
+	for _, h := range cfg.Hooks {
+		if h.Event == eventDailyRevenueAbove && !slices.Contains(res, h.Threshold) {
+			res = append(res, h.Threshold)
+		}
+	}
+	for _, ch := range cfg.Notify {
+		if ch.Threshold > 0 && (len(ch.Events) == 0 || slices.Contains(ch.Events, eventDailyRevenueAbove)) && !slices.Contains(res, ch.Threshold) {
+			res = append(res, ch.Threshold)
+		}
+	}
+	return res
+}
+
+func fireHooks(hooks []Hook, ev hookEvent) {
+	for _, h := range hooks {
+		if h.Event != ev.Name {
+			continue
+		}
+		if ev.Name == eventDailyRevenueAbove && ev.Data["threshold"] != fmt.Sprintf("%.2f", h.Threshold) {
+			continue
+		}
+		runHook(h, ev)
+	}
+}
+
+func runHook(h Hook, ev hookEvent) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", h.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", h.Command)
+	}
+	cmd.Env = append(os.Environ(), "MARKET_EVENT="+ev.Name)
+	for k, v := range ev.Data {
+		cmd.Env = append(cmd.Env, "MARKET_"+strings.ToUpper(k)+"="+v)
+	}
+	payload, _ := json.Marshal(ev)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		log.Printf("хук %q (%s): %v", h.Command, ev.Name, err)
+	}
+}
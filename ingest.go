@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const ingestLogFile = "ingest.log"
+
+type ingestSummary struct {
+	NewSales   int
+	Duplicates int
+	Failures   int
+	Unparsed   int
+	Undated    int
+	Periods    []period
+	Added      map[string]Money
+}
+
+func summarizeIngest(parsed *parseResult, sales []Sale, since time.Time, duplicates int, periods []period, now time.Time) ingestSummary {
+	sum := ingestSummary{Duplicates: duplicates, Failures: len(parsed.Anomalies), Unparsed: len(parsed.Unparsed), Undated: len(parsed.Undated), Periods: periods, Added: make(map[string]Money)}
+	for _, s := range sales {
+		if !s.Time.After(since) {
+			continue
+		}
+		sum.NewSales++
+		for _, p := range periods {
+			if p.contains(s.Time, now) {
+				sum.Added[p.name] += s.Price
+			}
+		}
+	}
+	return sum
+}
+
+func (s ingestSummary) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "новых продаж %d, повторов пропущено %d, ошибок разбора %d, неразобранных %d", s.NewSales, s.Duplicates, s.Failures, s.Unparsed)
+	if s.Undated > 0 {
+		fmt.Fprintf(&b, ", пропущено из-за неизвестного формата даты %d", s.Undated)
+	}
+	if s.NewSales > 0 {
+		changes := make([]string, len(s.Periods))
+		for i, p := range s.Periods {
+			changes[i] = fmt.Sprintf("%s +$%.2f", p.name, s.Added[p.name])
+		}
+		fmt.Fprintf(&b, "; итоги периодов: %s", strings.Join(changes, ", "))
+	}
+	return b.String()
+}
+
+func recordIngest(s ingestSummary, now time.Time) error {
+	fmt.Fprintf(out, "\nИтог загрузки: %s\n", s)
+	f, err := os.OpenFile(ingestLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err == nil {
+		_, err = fmt.Fprintf(f, "%s %s\n", now.Format(time.RFC3339), s)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось записать %s: %w", ingestLogFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type itemLifecycle struct {
+	First, Last    time.Time
+	Count, Sales   int
+	Revenue        Money
+	GapFrom, GapTo time.Time
+	BestDay        string
+	BestDayRevenue Money
+	ByCharacter    map[string]*ItemStats
+	PrunedUntil    time.Time
+}
+
+func lifecycleCharacter(character, server string) string {
+	name, id := splitCharacter(character)
+	return fmt.Sprintf("%s #%s (%s)", name, id, server)
+}
+
+func (lc *itemLifecycle) addCharacter(character, server string, count int, sum Money) {
+	key := lifecycleCharacter(character, server)
+	st := lc.ByCharacter[key]
+	if st == nil {
+		st = &ItemStats{}
+		lc.ByCharacter[key] = st
+	}
+	st.Count += count
+	st.Sum += sum
+}
+
+func buildItemLifecycle(sales []Sale, item string) *itemLifecycle {
+	var lc *itemLifecycle
+	days := make(map[string]Money)
+	var prev time.Time
+	for _, s := range sales {
+		if s.Item != item {
+			continue
+		}
+		if lc == nil {
+			lc = &itemLifecycle{First: s.Time, ByCharacter: make(map[string]*ItemStats)}
+		} else if s.Time.Sub(prev) > lc.GapTo.Sub(lc.GapFrom) {
+			lc.GapFrom, lc.GapTo = prev, s.Time
+		}
+		prev = s.Time
+		lc.Last = s.Time
+		lc.Count += s.Quantity
+		lc.Sales++
+		lc.Revenue += s.Price
+		days[s.Time.Format("2006-01-02")] += s.Price
+		lc.addCharacter(s.Character, s.Server, s.Quantity, s.Price)
+	}
+	if lc == nil {
+		return nil
+	}
+	for day, rev := range days {
+		if rev > lc.BestDayRevenue || rev == lc.BestDayRevenue && day < lc.BestDay {
+			lc.BestDay, lc.BestDayRevenue = day, rev
+		}
+	}
+	return lc
+}
+
+func addPrunedTotals(lc *itemLifecycle, sales []Sale, snap *monthlySnapshot, item string, cfg *Config) *itemLifecycle {
+	covered := make(map[string]bool)
+	for _, s := range sales {
+		if s.Item == item {
+			covered[s.Time.Format("2006-01")] = true
+		}
+	}
+	for _, t := range snap.Totals {
+		if covered[t.Month] || cfg.canonicalItem(t.Item) != item {
+			continue
+		}
+		month, err := time.ParseInLocation("2006-01", t.Month, time.Local)
+		if err != nil {
+			continue
+		}
+		if lc == nil {
+			lc = &itemLifecycle{First: month, Last: month, ByCharacter: make(map[string]*ItemStats)}
+		}
+		if month.Before(lc.First) {
+			lc.First = month
+		}
+		if month.After(lc.Last) {
+			lc.Last = month
+		}
+		if end := month.AddDate(0, 1, 0); end.After(lc.PrunedUntil) {
+			lc.PrunedUntil = end
+		}
+		lc.Sales += t.Sales
+		lc.Count += t.Quantity
+		lc.Revenue += t.Revenue
+		lc.addCharacter(t.Character, t.Server, t.Quantity, t.Revenue)
+	}
+	return lc
+}
+
+func cmdItem(args []string) error {
+	name := strings.TrimSpace(strings.Join(args, " "))
+	if name == "" {
+		return errors.New("укажите название предмета: market item <название>")
+	}
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	ctx, stop := runContext(cfg)
+	defer stop()
+	var sales []Sale
+	err = eachHistorySale(ctx, cfg, func(s Sale) error {
+		sales = append(sales, s)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sortSalesByTime(sales)
+	snap, err := loadMonthlySnapshot()
+	if err != nil {
+		return err
+	}
+
+	item, ok := findSoldItem(cfg, sales, name)
+	if !ok {
+		pruned := make([]Sale, len(snap.Totals))
+		for i, t := range snap.Totals {
+			pruned[i] = Sale{Item: cfg.canonicalItem(t.Item)}
+		}
+		if item, ok = findSoldItem(cfg, pruned, name); !ok {
+			return fmt.Errorf("продаж предмета «%s» не найдено", name)
+		}
+	}
+	lc := addPrunedTotals(buildItemLifecycle(sales, item), sales, snap, item, cfg)
+	printItemLifecycle(item, lc, cfg, time.Now())
+	return nil
+}
+
+func findSoldItem(cfg *Config, sales []Sale, name string) (string, bool) {
+	item := cfg.canonicalItem(name)
+	found := ""
+	for _, s := range sales {
+		if s.Item == item {
+			return item, true
+		}
+		if found == "" && itemKey(s.Item) == itemKey(name) {
+			found = s.Item
+		}
+	}
+	return found, found != ""
+}
+
+func printItemLifecycle(item string, lc *itemLifecycle, cfg *Config, now time.Time) {
+	lang := cfg.Language
+	fmt.Fprintf(out, "Предмет: %s\n", item)
+	w := newTable()
+	if lc.First.Before(lc.PrunedUntil) {
+		fmt.Fprintf(w, "  Первая продажа\t%s\n", formatMonth(lc.First.Year(), lc.First.Month(), lang))
+	} else {
+		fmt.Fprintf(w, "  Первая продажа\t%s\n", formatDateTime(lc.First, lang))
+	}
+	fmt.Fprintf(w, "  Последняя продажа\t%s (%d дн. назад)\n", formatDateTime(lc.Last, lang), int(now.Sub(lc.Last).Hours()/24))
+	fmt.Fprintf(w, "  Продаж / штук\t%d / %d\n", lc.Sales, lc.Count)
+	fmt.Fprintf(w, "  Выручка за всё время\t$%.2f\n", lc.Revenue)
+	if lc.Count > 0 {
+		fmt.Fprintf(w, "  Средняя цена\t$%.2f\n", lc.Revenue.Float()/float64(lc.Count))
+	}
+	if !lc.GapFrom.IsZero() {
+		fmt.Fprintf(w, "  Самый долгий перерыв\t%.1f дн. (%s — %s)\n", lc.GapTo.Sub(lc.GapFrom).Hours()/24, formatDate(lc.GapFrom, lang), formatDate(lc.GapTo, lang))
+	}
+	if day, err := time.ParseInLocation("2006-01-02", lc.BestDay, time.Local); err == nil {
+		fmt.Fprintf(w, "  Лучший день\t%s, $%.2f\n", formatDate(day, lang), lc.BestDayRevenue)
+	}
+	if !lc.PrunedUntil.IsZero() {
+		fmt.Fprintf(w, "  До %s\tпо итогам месяцев из %s\n", formatDate(lc.PrunedUntil, lang), salesMonthlyFile)
+	}
+	w.Flush()
+
+	chars := make([]string, 0, len(lc.ByCharacter))
+	for c := range lc.ByCharacter {
+		chars = append(chars, c)
+	}
+	sort.Slice(chars, func(i, j int) bool { return lc.ByCharacter[chars[i]].Sum > lc.ByCharacter[chars[j]].Sum })
+	fmt.Fprintln(out, "\nПо персонажам:")
+	w = newTable()
+	fmt.Fprintln(w, "    Персонаж\tКол-во\tСумма продаж\tСредняя цена")
+	for _, c := range chars {
+		st := lc.ByCharacter[c]
+		fmt.Fprintf(w, "    %s\t%d\t$%.2f\t$%.2f\n", c, st.Count, st.Sum, st.average())
+	}
+	w.Flush()
+}
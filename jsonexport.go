@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+const jsonExportFile = "result.json"
+
+type jsonTextPart struct {
+	Text string `json:"text"`
+}
+
+type jsonMessage struct {
+	ID           int64           `json:"id"`
+	Type         string          `json:"type"`
+	Date         string          `json:"date"`
+	DateUnix     string          `json:"date_unixtime"`
+	Text         json.RawMessage `json:"text"`
+	TextEntities []jsonTextPart  `json:"text_entities"`
+}
+
+func fileExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && !st.IsDir()
+}
+
+func (m *jsonMessage) time() (time.Time, bool) {
+	if m.DateUnix != "" {
+		if sec, err := strconv.ParseInt(m.DateUnix, 10, 64); err == nil {
+			return time.Unix(sec, 0).In(time.Local), true
+		}
+	}
+	return exportLayout{}.parseTime(m.Date)
+}
+
+func (m *jsonMessage) appendText(buf *bytes.Buffer) {
+	if len(m.TextEntities) > 0 {
+		for _, e := range m.TextEntities {
+			buf.WriteString(e.Text)
+		}
+		return
+	}
+	var plain string
+	if json.Unmarshal(m.Text, &plain) == nil {
+		buf.WriteString(plain)
+		return
+	}
+	var parts []json.RawMessage
+	if json.Unmarshal(m.Text, &parts) != nil {
+		return
+	}
+	for _, p := range parts {
+		var part jsonTextPart
+		if json.Unmarshal(p, &plain) == nil {
+			buf.WriteString(plain)
+		} else if json.Unmarshal(p, &part) == nil {
+			buf.WriteString(part.Text)
+		}
+	}
+}
+
+func parseJSONExport(r io.Reader, path string, cfg *Config, res *parseResult, emit func(Sale) error) error {
+	var export struct {
+		Name     string        `json:"name"`
+		Messages []jsonMessage `json:"messages"`
+	}
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return fmt.Errorf("ошибка разбора %s: %w", path, err)
+	}
+	res.ChatName = export.Name
+	res.Layout = "tdesktop-json"
+
+	parsers := cfg.parsers()
+	buf := textBufPool.Get().(*bytes.Buffer)
+	defer textBufPool.Put(buf)
+	for i := range export.Messages {
+		m := &export.Messages[i]
+		if m.Type != "message" {
+			continue
+		}
+		buf.Reset()
+		m.appendText(buf)
+		text := buf.Bytes()
+		if !parsers.match(text) {
+			continue
+		}
+		msgTime, ok := m.time()
+		if !ok {
+			res.Undated = append(res.Undated, parseAnomaly{Text: string(text), Reason: m.Date})
+			continue
+		}
+		if err := res.addTrade(text, msgTime, m.ID, cfg, emit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
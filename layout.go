@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type exportLayout struct {
+	name        string
+	message     string
+	text        string
+	date        string
+	dateAttrs   []string
+	dateLayouts []string
+}
+
+var dateAttrs = []string{"title", "datetime", "data-date", "data-time"}
+
+var fallbackDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "02.01.2006 15:04:05", "02.01.2006 15:04"}
+
+var exportLayouts = []exportLayout{
+	{
+		name:        "tdesktop",
+		message:     "div.message",
+		text:        "div.text",
+		date:        "div.pull_right.date.details",
+		dateAttrs:   dateAttrs,
+		dateLayouts: []string{"02.01.2006 15:04:05"},
+	},
+	{
+		name:        "tdesktop-legacy",
+		message:     "div.message",
+		text:        "div.text",
+		date:        "div.date",
+		dateAttrs:   dateAttrs,
+		dateLayouts: []string{"02.01.2006 15:04:05", "02.01.2006 15:04"},
+	},
+	{
+		name:        "tdesktop-iso",
+		message:     "div.message",
+		text:        "div.text",
+		date:        "div.date",
+		dateAttrs:   dateAttrs,
+		dateLayouts: []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "01/02/2006 15:04:05"},
+	},
+}
+
+type simpleSelector struct {
+	tag     string
+	classes []string
+}
+
+func parseSelector(sel string) simpleSelector {
+	parts := strings.Split(sel, ".")
+	return simpleSelector{tag: parts[0], classes: parts[1:]}
+}
+
+func (s simpleSelector) matches(tag, class string) bool {
+	if s.tag != "" && s.tag != tag {
+		return false
+	}
+	for _, c := range s.classes {
+		if !hasClass(class, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasClass(classAttr, class string) bool {
+	for c := range strings.FieldsSeq(classAttr) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (l exportLayout) parseTime(title string) (time.Time, bool) {
+	ts, zone, hasZone := strings.Cut(title, " UTC")
+	loc := time.Local
+	if hasZone {
+		if z, ok := parseUTCOffset(zone); ok {
+			loc = z
+		}
+	}
+	sep := dateSeparator(ts)
+	for _, layouts := range [][]string{l.dateLayouts, fallbackDateLayouts} {
+		for _, layout := range layouts {
+			if ls := dateSeparator(layout); ls != sep && strings.IndexByte(".-/", ls) >= 0 && layout[0] != ls {
+				continue
+			}
+			if t, err := time.ParseInLocation(layout, ts, loc); err == nil {
+				return t.In(time.Local), true
+			}
+		}
+	}
+	if sec, err := strconv.ParseInt(strings.TrimSpace(title), 10, 64); err == nil && sec > 0 {
+		return time.Unix(sec, 0).In(time.Local), true
+	}
+	return time.Time{}, false
+}
+
+// dateSeparator returns the first non-digit byte of s. A layout that starts
+// with a numeric field followed by '.', '-' or '/' cannot match a date with a
+// different separator, and skipping it spares time.Parse building an error.
+func dateSeparator(s string) byte {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return s[i]
+		}
+	}
+	return 0
+}
+
+var utcOffsetZones sync.Map
+
+func parseUTCOffset(s string) (*time.Location, bool) {
+	s = strings.TrimSpace(s)
+	if loc, ok := utcOffsetZones.Load(s); ok {
+		return loc.(*time.Location), true
+	}
+	if s == "" {
+		return time.UTC, true
+	}
+	sign := 1
+	switch s[0] {
+	case '+':
+	case '-':
+		sign = -1
+	default:
+		return nil, false
+	}
+	hh, mm, _ := strings.Cut(s[1:], ":")
+	h, err := strconv.Atoi(hh)
+	if err != nil || h > 14 {
+		return nil, false
+	}
+	m := 0
+	if mm != "" {
+		if m, err = strconv.Atoi(mm); err != nil || m >= 60 {
+			return nil, false
+		}
+	}
+	loc, _ := utcOffsetZones.LoadOrStore(s, time.FixedZone("UTC"+s, sign*(h*3600+m*60)))
+	return loc.(*time.Location), true
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExportLayoutParseTime(t *testing.T) {
+	msk := time.FixedZone("", 3*3600)
+	tests := []struct {
+		layout int
+		title  string
+		want   time.Time
+	}{
+		{0, "05.01.2026 14:03:09 UTC+03:00", time.Date(2026, 1, 5, 14, 3, 9, 0, msk)},
+		{1, "05.01.2026 14:03 UTC+03:00", time.Date(2026, 1, 5, 14, 3, 0, 0, msk)},
+		{2, "2026-01-05 14:03:09 UTC+03:00", time.Date(2026, 1, 5, 14, 3, 9, 0, msk)},
+		{2, "01/05/2026 14:03:09 UTC+03:00", time.Date(2026, 1, 5, 14, 3, 9, 0, msk)},
+		{2, "05.01.2026 14:03:09 UTC+03:00", time.Date(2026, 1, 5, 14, 3, 9, 0, msk)},
+		{0, "2026-01-05T14:03:09+03:00", time.Date(2026, 1, 5, 14, 3, 9, 0, msk)},
+		{0, "1767611000", time.Unix(1767611000, 0)},
+	}
+	for _, tt := range tests {
+		got, ok := exportLayouts[tt.layout].parseTime(tt.title)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("%s.parseTime(%q) = %v, %v; want %v", exportLayouts[tt.layout].name, tt.title, got, ok, tt.want)
+		}
+	}
+	for _, title := range []string{"", "вчера", "05.01.2026"} {
+		if got, ok := exportLayouts[0].parseTime(title); ok {
+			t.Errorf("parseTime(%q) = %v, want failure", title, got)
+		}
+	}
+}
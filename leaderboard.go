@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+func printLeaderboard(servers map[string]*Server, p period, cfg *Config, now time.Time) {
+	for _, srvName := range sortedServerKeys(servers, cfg.Language) {
+		srv := servers[srvName]
+		chars := make([]*Character, 0, len(srv.Characters))
+		for _, ch := range srv.Characters {
+			chars = append(chars, ch)
+		}
+		sort.Slice(chars, func(i, j int) bool {
+			return chars[i].Revenue() > chars[j].Revenue()
+		})
+
+		scores := efficiencyScores(chars, cfg.scoreWeights())
+
+		fmt.Fprintf(out, "\nРейтинг персонажей (%s), сервер %s:\n", p.name, srvName)
+		w := newTable()
+		header := "#\tПерсонаж\tВыручка\tПродаж\tАктивных дней\tВ активный день\tЗа продажу\tОчки"
+		if cfg.GuildPool != nil {
+			header += "\tВзнос в казну"
+		}
+		if len(cfg.discordUsers) > 0 {
+			header += "\tDiscord"
+		}
+		fmt.Fprintln(w, header)
+		shown, hidden := topRows(chars, cfg.TopN)
+		for i, ch := range shown {
+			revenue := ch.Revenue()
+			perDay, perSale := 0.0, 0.0
+			if len(ch.Days) > 0 {
+				perDay = revenue.Float() / float64(len(ch.Days))
+			}
+			if ch.Sales > 0 {
+				perSale = revenue.Float() / float64(ch.Sales)
+			}
+			fmt.Fprintf(w, "%d\t%s #%s\t$%.2f\t%d\t%d\t$%.2f\t$%.2f\t%.0f", i+1, ch.Name, ch.ID, revenue, ch.Sales, len(ch.Days), perDay, perSale, scores[ch])
+			if cfg.GuildPool != nil {
+				fmt.Fprintf(w, "\t$%.2f", cfg.GuildPool.contribution(srvName, ch, now, p))
+			}
+			if len(cfg.discordUsers) > 0 {
+				user := cfg.discordUsers[ch.ID]
+				if user != "" {
+					user = "@" + user
+				}
+				fmt.Fprintf(w, "\t%s", user)
+			}
+			fmt.Fprintln(w)
+		}
+		w.Flush()
+		printMoreRows(hidden, cfg)
+	}
+}
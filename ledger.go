@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const salesLedgerFile = "sales.jsonl"
+
+type ledgerEntry struct {
+	Key          string    `json:"key"`
+	MsgID        int64     `json:"msg_id,omitempty"`
+	Time         time.Time `json:"time"`
+	Server       string    `json:"server"`
+	Character    string    `json:"character"`
+	Item         string    `json:"item"`
+	RawItem      string    `json:"raw_item,omitempty"`
+	Quality      string    `json:"quality,omitempty"`
+	Owner        string    `json:"owner,omitempty"`
+	Counterparty string    `json:"counterparty,omitempty"`
+	Quantity     int       `json:"quantity"`
+	Price        Money     `json:"price"`
+	Fee          Money     `json:"fee,omitempty"`
+}
+
+func newLedgerEntry(key string, s Sale) ledgerEntry {
+	e := ledgerEntry{Key: key, MsgID: s.MsgID, Time: s.Time, Server: s.Server, Character: s.Character, Item: s.Item, Quality: s.Quality,
+		Owner: s.Owner, Counterparty: s.Counterparty, Quantity: s.Quantity, Price: s.Price, Fee: s.Fee}
+	if s.RawItem != s.Item {
+		e.RawItem = s.RawItem
+	}
+	return e
+}
+
+func (e ledgerEntry) sale() Sale {
+	s := Sale{MsgID: e.MsgID, Time: e.Time.In(time.Local), Server: e.Server, Character: e.Character, Item: e.Item, RawItem: e.RawItem, Quality: e.Quality,
+		Owner: e.Owner, Counterparty: e.Counterparty, Quantity: e.Quantity, Price: e.Price, Fee: e.Fee}
+	if s.RawItem == "" {
+		s.RawItem = s.Item
+	}
+	return s
+}
+
+func storedSaleKeys(sales []Sale) []string {
+	seq := make(keySequence)
+	keys := make([]string, len(sales))
+	for i, s := range sales {
+		keys[i] = seq.next(contentKey(s), 0)
+	}
+	return keys
+}
+
+func loadLedger() ([]ledgerEntry, error) {
+	return loadLedgerFile(salesLedgerFile)
+}
+
+func loadLedgerFile(path string) ([]ledgerEntry, error) {
+	var res []ledgerEntry
+	err := eachLedgerEntry(path, func(e ledgerEntry) error {
+		res = append(res, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func eachLedgerEntry(path string, fn func(ledgerEntry) error) error {
+	return eachLedgerLine(path, func(line int, data []byte) error {
+		var e ledgerEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("%s, строка %d: %w", path, line, err)
+		}
+		return fn(e)
+	})
+}
+
+// eachLedgerLine calls fn with every non-empty line of the file; a missing
+// file has none.
+func eachLedgerLine(path string, fn func(line int, data []byte) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		if err := fn(line, sc.Bytes()); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+func appendLedger(sales []Sale) (int, error) {
+	entries, err := loadLedger()
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		seen[e.Key] = true
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	added := 0
+	for i, key := range storedSaleKeys(sales) {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err := enc.Encode(newLedgerEntry(key, sales[i])); err != nil {
+			return 0, err
+		}
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	f, err := os.OpenFile(salesLedgerFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return 0, err
+	}
+	return added, f.Close()
+}
+
+type ledgerPurge struct{ path string }
+
+func (p ledgerPurge) Name() string { return p.path }
+
+func (p ledgerPurge) Purge(f purgeFilter, dryRun bool) (int, error) {
+	entries, err := loadLedgerFile(p.path)
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	n := 0
+	for _, e := range entries {
+		_, id := splitCharacter(e.Character)
+		if f.matches(id, e.Time) {
+			n++
+			continue
+		}
+		if err := enc.Encode(e); err != nil {
+			return 0, err
+		}
+	}
+	if dryRun || n == 0 {
+		return n, nil
+	}
+	return n, writeFileAtomic(p.path, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type Listing struct {
+	MsgID     int64
+	Time      time.Time
+	Server    string
+	Character string
+	Item      string
+	Quantity  int
+	Price     Money
+}
+
+func (l Listing) key() string {
+	return tradeKey(l.MsgID, l.Time, l.Server, l.Character, l.Item, l.Quantity, l.Price)
+}
+
+func (l Listing) contentKey() string {
+	l.MsgID = 0
+	return l.key()
+}
+
+func newListingParser(cfg *Config, tf *tradeFormat) Parser {
+	return &tradeTextParser{trigger: tf.listingTrigger, re: tf.listingRe, labels: tf.listingLabels, currency: tf.currency, cfg: cfg, limits: cfg.limits()}
+}
+
+func newExpiredParser(cfg *Config, tf *tradeFormat) Parser {
+	return &tradeTextParser{trigger: tf.expiredTrigger, re: tf.expiredRe, labels: tf.expiredLabels, currency: tf.currency, optionalPrice: true, cfg: cfg, limits: cfg.limits()}
+}
+
+func addListing(res *parseResult, s Sale, _ func(Sale) error) error {
+	res.Listings = append(res.Listings, Listing{MsgID: s.MsgID, Time: s.Time, Server: s.Server, Character: s.Character, Item: s.Item, Quantity: s.Quantity, Price: s.Price})
+	return nil
+}
+
+func addExpired(res *parseResult, s Sale, _ func(Sale) error) error {
+	res.Expired = append(res.Expired, Listing{MsgID: s.MsgID, Time: s.Time, Server: s.Server, Character: s.Character, Item: s.Item, Quantity: s.Quantity, Price: s.Price})
+	return nil
+}
+
+func listingsAsOf(listings []Listing, asOf time.Time) []Listing {
+	var res []Listing
+	for _, l := range listings {
+		if !l.Time.After(asOf) {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func anonymizeListings(listings []Listing, salt string) []Listing {
+	res := make([]Listing, len(listings))
+	for i, l := range listings {
+		l.Character = anonymousCharacter(salt, l.Server, l.Character)
+		res[i] = l
+	}
+	return res
+}
+
+func lotKey(server, character, item string, qty int) string {
+	name, id := splitCharacter(character)
+	if id == "" {
+		id = name
+	}
+	return fmt.Sprintf("%s|%s|%s|%d", server, id, item, qty)
+}
+
+type lotStats struct {
+	Item    string
+	Sold    int
+	Matched int
+	Total   time.Duration
+	Expired int
+	Open    int
+}
+
+func (s *lotStats) average() time.Duration {
+	if s.Matched == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Matched)
+}
+
+func (s *lotStats) returnRate() float64 {
+	if s.Sold+s.Expired == 0 {
+		return 0
+	}
+	return float64(s.Expired) / float64(s.Sold+s.Expired) * 100
+}
+
+type lotEvent struct {
+	time    time.Time
+	key     string
+	item    string
+	expired bool
+}
+
+func matchListings(listings, expired []Listing, sales []Sale) map[string]*lotStats {
+	sorted := append([]Listing(nil), listings...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+	queues := make(map[string][]Listing)
+	stats := make(map[string]*lotStats)
+	for _, l := range sorted {
+		k := lotKey(l.Server, l.Character, l.Item, l.Quantity)
+		queues[k] = append(queues[k], l)
+		stats[l.Item] = &lotStats{Item: l.Item}
+	}
+
+	events := make([]lotEvent, 0, len(sales)+len(expired))
+	for _, l := range expired {
+		events = append(events, lotEvent{time: l.Time, key: lotKey(l.Server, l.Character, l.Item, l.Quantity), item: l.Item, expired: true})
+		if stats[l.Item] == nil {
+			stats[l.Item] = &lotStats{Item: l.Item}
+		}
+	}
+	for _, s := range sales {
+		events = append(events, lotEvent{time: s.Time, key: lotKey(s.Server, s.Character, s.Item, s.Quantity), item: s.Item})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].time.Before(events[j].time) })
+
+	for _, ev := range events {
+		st := stats[ev.item]
+		if st == nil {
+			continue
+		}
+		if ev.expired {
+			st.Expired++
+		} else {
+			st.Sold++
+		}
+		q := queues[ev.key]
+		if len(q) == 0 || q[0].Time.After(ev.time) {
+			continue
+		}
+		if !ev.expired {
+			st.Matched++
+			st.Total += ev.time.Sub(q[0].Time)
+		}
+		queues[ev.key] = q[1:]
+	}
+	for _, q := range queues {
+		for _, l := range q {
+			stats[l.Item].Open++
+		}
+	}
+	return stats
+}
+
+func formatWait(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, hours, mins := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d д %d ч", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%d ч %d мин", hours, mins)
+	}
+	return fmt.Sprintf("%d мин", mins)
+}
+
+func printLotStats(cfg *Config, listings, expired []Listing, sales []Sale) {
+	if len(listings) == 0 && len(expired) == 0 {
+		return
+	}
+	stats := matchListings(listings, expired, sales)
+	rows := make([]*lotStats, 0, len(stats))
+	for _, st := range stats {
+		rows = append(rows, st)
+	}
+	sortByName(rows, cfg.Language, func(r *lotStats) string { return r.Item })
+	sort.SliceStable(rows, func(i, j int) bool {
+		if (rows[i].Matched > 0) != (rows[j].Matched > 0) {
+			return rows[i].Matched > 0
+		}
+		return rows[i].average() < rows[j].average()
+	})
+
+	fmt.Fprintln(out, "\nЛоты на рынке (от выставления до продажи или возврата):")
+	shown, hidden := topRows(rows, cfg.TopN)
+	w := newTable()
+	fmt.Fprintln(w, "Предмет\tПродано\tНе продано\tВозвраты\tСреднее время до продажи\tЕщё на рынке")
+	for _, st := range shown {
+		avg := "-"
+		if st.Matched > 0 {
+			avg = formatWait(st.average())
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.0f%%\t%s\t%d\n", st.Item, st.Sold, st.Expired, st.returnRate(), avg, st.Open)
+	}
+	w.Flush()
+	printMoreRows(hidden, cfg)
+}
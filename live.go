@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	liveMessagesFile = "live_messages.jsonl"
+	livePollSeconds  = 10
+)
+
+type LiveSource struct {
+	BotToken string `json:"bot_token"`
+	ChatID   int64  `json:"chat_id,omitempty"`
+}
+
+type liveMessage struct {
+	ID   int64     `json:"id"`
+	Chat int64     `json:"chat"`
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+type botMessage struct {
+	MessageID   int64  `json:"message_id"`
+	Date        int64  `json:"date"`
+	ForwardDate int64  `json:"forward_date"`
+	Text        string `json:"text"`
+	Caption     string `json:"caption"`
+	Chat        struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+type botUpdate struct {
+	UpdateID    int64       `json:"update_id"`
+	Message     *botMessage `json:"message"`
+	ChannelPost *botMessage `json:"channel_post"`
+}
+
+func (ls *LiveSource) getUpdates(ctx context.Context, offset int64, timeout int) ([]botUpdate, error) {
+	q := url.Values{
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"timeout":         {strconv.Itoa(timeout)},
+		"allowed_updates": {`["message","channel_post"]`},
+	}
+	u := "https://api.telegram.org/bot" + url.PathEscape(ls.BotToken) + "/getUpdates?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = fmt.Errorf("Telegram: %w", uerr.Err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		OK          bool        `json:"ok"`
+		Description string      `json:"description"`
+		Result      []botUpdate `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("HTTP %s: %w", resp.Status, err)
+	}
+	if !body.OK {
+		return nil, fmt.Errorf("Telegram: %s", body.Description)
+	}
+	return body.Result, nil
+}
+
+func (ls *LiveSource) accept(m *botMessage, parsers parserSet) (liveMessage, bool) {
+	if m == nil || ls.ChatID != 0 && m.Chat.ID != ls.ChatID {
+		return liveMessage{}, false
+	}
+	text := m.Text
+	if text == "" {
+		text = m.Caption
+	}
+	if !parsers.match([]byte(text)) {
+		return liveMessage{}, false
+	}
+	date := m.Date
+	if m.ForwardDate != 0 {
+		date = m.ForwardDate
+	}
+	return liveMessage{ID: m.MessageID, Chat: m.Chat.ID, Time: time.Unix(date, 0), Text: text}, true
+}
+
+func appendLiveMessages(msgs []liveMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, m := range msgs {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	return appendStoreFile(liveMessagesFile, buf.Bytes())
+}
+
+func loadLiveMessages() ([]liveMessage, error) {
+	data, err := readStoreFile(liveMessagesFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res []liveMessage
+	seen := make(map[[2]int64]bool)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var m liveMessage
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("%s, строка %d: %w", liveMessagesFile, line, err)
+		}
+		if key := [2]int64{m.Chat, m.ID}; !seen[key] {
+			seen[key] = true
+			res = append(res, m)
+		}
+	}
+	return res, sc.Err()
+}
+
+type liveMessagesPurge struct{ cfg *Config }
+
+func (liveMessagesPurge) Name() string { return liveMessagesFile }
+
+func (p liveMessagesPurge) Purge(f purgeFilter, dryRun bool) (int, error) {
+	msgs, err := loadLiveMessages()
+	if err != nil || len(msgs) == 0 {
+		return 0, err
+	}
+	parsers := p.cfg.parsers()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	n := 0
+	for _, m := range msgs {
+		if _, id := splitCharacter(liveMessageCharacter(parsers, m)); f.matches(id, m.Time) {
+			n++
+			continue
+		}
+		if err := enc.Encode(m); err != nil {
+			return 0, err
+		}
+	}
+	if dryRun || n == 0 {
+		return n, nil
+	}
+	compressed, _, err := isCompressedStore(liveMessagesFile)
+	if err != nil {
+		return 0, err
+	}
+	data := buf.Bytes()
+	if compressed {
+		data = compressStore(data)
+	}
+	return n, writeFileAtomic(liveMessagesFile, data, 0o644)
+}
+
+func liveMessageCharacter(parsers parserSet, m liveMessage) string {
+	text := []byte(m.Text)
+	for _, p := range parsers {
+		if !p.Match(text) {
+			continue
+		}
+		s, err := p.Parse(text, m.Time)
+		if err != nil {
+			return ""
+		}
+		return s.Character
+	}
+	return ""
+}
+
+func contentKey(s Sale) string {
+	return tradeKey(0, s.Time, s.Server, s.Character, s.RawItem, s.Quantity, s.Price)
+}
+
+func addLiveTrades(cfg *Config, res *parseResult, seen map[string]int, emit func(Sale) error) (int, error) {
+	msgs, err := loadLiveMessages()
+	if err != nil || len(msgs) == 0 {
+		return 0, err
+	}
+	purged, err := loadPurgeLog()
+	if err != nil {
+		return 0, err
+	}
+	seenPurchases := make(map[string]int, len(res.Purchases))
+	for _, p := range res.Purchases {
+		seenPurchases[tradeKey(0, p.Time, p.Server, p.Character, p.Item, p.Quantity, p.Price)]++
+	}
+	seenTrades := make(map[string]int, len(res.Trades))
+	for _, t := range res.Trades {
+		seenTrades[t.contentKey()]++
+	}
+	seenListings := make(map[string]int, len(res.Listings))
+	for _, l := range res.Listings {
+		seenListings[l.contentKey()]++
+	}
+	seenExpired := make(map[string]int, len(res.Expired))
+	for _, l := range res.Expired {
+		seenExpired[l.contentKey()]++
+	}
+	live := &parseResult{}
+	added := 0
+	for _, m := range msgs {
+		err := live.addTrade([]byte(m.Text), m.Time, m.ID, cfg, func(s Sale) error {
+			if purged.covers(s.Character, s.Time) {
+				return nil
+			}
+			s.Item = cfg.canonicalItem(s.Item)
+			if k := contentKey(s); seen[k] > 0 {
+				seen[k]--
+				return nil
+			}
+			added++
+			return emit(s)
+		})
+		if err != nil {
+			return added, err
+		}
+	}
+	for _, p := range live.Purchases {
+		if purged.covers(p.Character, p.Time) {
+			continue
+		}
+		p.Item = cfg.canonicalItem(p.Item)
+		if k := tradeKey(0, p.Time, p.Server, p.Character, p.Item, p.Quantity, p.Price); seenPurchases[k] > 0 {
+			seenPurchases[k]--
+		} else {
+			res.Purchases = append(res.Purchases, p)
+		}
+	}
+	for _, t := range live.Trades {
+		if purged.covers(t.Character, t.Time) {
+			continue
+		}
+		t.Item = cfg.canonicalItem(t.Item)
+		if k := t.contentKey(); seenTrades[k] > 0 {
+			seenTrades[k]--
+		} else {
+			res.Trades = append(res.Trades, t)
+		}
+	}
+	for _, l := range live.Listings {
+		if purged.covers(l.Character, l.Time) {
+			continue
+		}
+		l.Item = cfg.canonicalItem(l.Item)
+		if k := l.contentKey(); seenListings[k] > 0 {
+			seenListings[k]--
+		} else {
+			res.Listings = append(res.Listings, l)
+		}
+	}
+	for _, l := range live.Expired {
+		if purged.covers(l.Character, l.Time) {
+			continue
+		}
+		l.Item = cfg.canonicalItem(l.Item)
+		if k := l.contentKey(); seenExpired[k] > 0 {
+			seenExpired[k]--
+		} else {
+			res.Expired = append(res.Expired, l)
+		}
+	}
+	res.Anomalies = append(res.Anomalies, live.Anomalies...)
+	res.Unparsed = append(res.Unparsed, live.Unparsed...)
+	return added, nil
+}
+
+func mergeLiveTrades(cfg *Config, res *parseResult) (int, error) {
+	if !cfg.hasLiveSources() {
+		return 0, nil
+	}
+	seen := make(map[string]int, len(res.Sales))
+	for _, s := range res.Sales {
+		seen[contentKey(s)]++
+	}
+	added, err := addLiveTrades(cfg, res, seen, func(s Sale) error {
+		res.Sales = append(res.Sales, s)
+		return nil
+	})
+	if added > 0 {
+		sortSalesByTime(res.Sales)
+	}
+	return added, err
+}
+
+func cmdLive(args []string) error {
+	fs := newFlagSet("live")
+	once := fs.Bool("once", false, "забрать накопившиеся сообщения и выйти")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.Live == nil {
+		return errors.New("в config.json не настроен раздел live (bot_token)")
+	}
+	ctx, stop := deferShutdown()
+	defer stop()
+
+	if !*once {
+		fmt.Fprintln(out, "Ожидание новых сообщений от бота (Ctrl+C — выход)...")
+		flushOut()
+	}
+	return pollLive(ctx, cfg, *once, func(fn func() error) error { return fn() })
+}
+
+func pollLive(ctx context.Context, cfg *Config, once bool, locked func(fn func() error) error) error {
+	parsers := cfg.parsers()
+	var offset int64
+	err := locked(func() error {
+		st, err := loadState()
+		if err != nil {
+			return err
+		}
+		offset = st.LiveOffset
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		timeout := livePollSeconds
+		if once {
+			timeout = 0
+		}
+		updates, err := cfg.Live.getUpdates(ctx, offset, timeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if once {
+				return err
+			}
+			_ = locked(func() error {
+				fmt.Fprintln(out, "Предупреждение:", err)
+				flushOut()
+				return nil
+			})
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(livePollSeconds * time.Second):
+			}
+			continue
+		}
+
+		var msgs []liveMessage
+		for _, u := range updates {
+			for _, m := range []*botMessage{u.Message, u.ChannelPost} {
+				if lm, ok := cfg.Live.accept(m, parsers); ok {
+					msgs = append(msgs, lm)
+				}
+			}
+		}
+		err = locked(func() error {
+			if err := appendLiveMessages(msgs); err != nil {
+				return fmt.Errorf("не удалось записать %s: %w", liveMessagesFile, err)
+			}
+			if len(updates) > 0 {
+				offset = updates[len(updates)-1].UpdateID + 1
+				st, err := loadState()
+				if err != nil {
+					return err
+				}
+				st.LiveOffset = offset
+				if err := st.save(); err != nil {
+					return err
+				}
+			}
+			printLiveMessages(msgs, cfg)
+			if len(msgs) > 0 {
+				notifyGoals(cfg)
+			}
+			if once {
+				fmt.Fprintf(out, "Получено сообщений о сделках: %d\n", len(msgs))
+			}
+			return nil
+		})
+		if err != nil || once {
+			return err
+		}
+	}
+}
+
+func printLiveMessages(msgs []liveMessage, cfg *Config) {
+	res := &parseResult{}
+	for _, m := range msgs {
+		_ = res.addTrade([]byte(m.Text), m.Time, m.ID, cfg, func(s Sale) error {
+			fmt.Fprintf(out, "%s  %s, %s — %s × %d, $%.2f\n", formatDateTime(s.Time, cfg.Language), s.Server, s.Character, s.Item, s.Quantity, s.Price)
+			return nil
+		})
+	}
+	for _, p := range res.Purchases {
+		fmt.Fprintf(out, "%s  %s, %s — покупка %s × %d, $%.2f\n", formatDateTime(p.Time, cfg.Language), p.Server, p.Character, p.Item, p.Quantity, p.Price)
+	}
+	for _, t := range res.Trades {
+		kind := "передача"
+		if t.Incoming {
+			kind = "получение"
+		}
+		fmt.Fprintf(out, "%s  %s, %s — %s %s × %d (%s), $%.2f\n", formatDateTime(t.Time, cfg.Language), t.Server, t.Character, kind, t.Item, t.Quantity, t.Counterparty, t.Price)
+	}
+	for _, l := range res.Listings {
+		fmt.Fprintf(out, "%s  %s, %s — выставлен %s × %d, $%.2f\n", formatDateTime(l.Time, cfg.Language), l.Server, l.Character, l.Item, l.Quantity, l.Price)
+	}
+	for _, l := range res.Expired {
+		fmt.Fprintf(out, "%s  %s, %s — не продан %s × %d\n", formatDateTime(l.Time, cfg.Language), l.Server, l.Character, l.Item, l.Quantity)
+	}
+	for _, a := range res.Anomalies {
+		fmt.Fprintln(out, "Предупреждение:", a.Reason+":", strings.Join(strings.Fields(a.Text), " "))
+	}
+	flushOut()
+}
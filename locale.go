@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+var monthNames = map[string][12]string{
+	"ru": {"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"},
+	"en": {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+}
+
+var standaloneMonthNames = [12]string{"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь", "Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"}
+
+func normalizeLanguage(lang string) string {
+	if _, ok := monthNames[lang]; ok {
+		return lang
+	}
+	return "ru"
+}
+
+func formatDate(t time.Time, lang string) string {
+	lang = normalizeLanguage(lang)
+	month := monthNames[lang][t.Month()-1]
+	if lang == "en" {
+		return fmt.Sprintf("%s %d, %d", month, t.Day(), t.Year())
+	}
+	return fmt.Sprintf("%d %s %d", t.Day(), month, t.Year())
+}
+
+func formatDateTime(t time.Time, lang string) string {
+	if normalizeLanguage(lang) == "en" {
+		return formatDate(t, lang) + " " + t.Format("3:04 PM")
+	}
+	return formatDate(t, lang) + ", " + t.Format("15:04")
+}
+
+func formatMonth(year int, month time.Month, lang string) string {
+	if normalizeLanguage(lang) == "en" {
+		return fmt.Sprintf("%s %d", monthNames["en"][month-1], year)
+	}
+	return fmt.Sprintf("%s %d", standaloneMonthNames[month-1], year)
+}
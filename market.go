@@ -1,46 +1,61 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
-	"regexp"
-	"sort"
-	"strconv"
-	"strings"
-	"text/tabwriter"
 	"time"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
-type Config struct {
-	BaseDir  string   `json:"base_dir"`
-	Selected []string `json:"selected"`
+type Sale struct {
+	MsgID        int64
+	Time         time.Time
+	Server       string
+	Character    string
+	Item         string
+	RawItem      string
+	Quality      string
+	Owner        string
+	Counterparty string
+	Quantity     int
+	Price        Money
+	Fee          Money
 }
 
-type Sale struct {
+type Purchase struct {
+	MsgID     int64
 	Time      time.Time
 	Server    string
 	Character string
 	Item      string
 	Quantity  int
-	Price     float64
+	Price     Money
 }
 
 type ItemStats struct {
-	Count int
-	Sum   float64
+	Count     int
+	Sum       Money
+	Fees      Money
+	Qualities map[string]*ItemStats
 }
 
 type Character struct {
-	ID       string
-	Name     string
-	LastSeen time.Time
-	Items    map[string]*ItemStats
+	ID            string
+	Name          string
+	FirstSeen     time.Time
+	LastSeen      time.Time
+	Items         map[string]*ItemStats
+	Sales         int
+	Days          map[string]bool
+	Foreign       Money
+	Spent         Money
+	Bought        map[string]*ItemStats
+	Trades        int
+	TradeReceived Money
+	TradePaid     Money
 }
 
 type Server struct {
@@ -48,288 +63,509 @@ type Server struct {
 	Characters map[string]*Character
 }
 
-var (
-	exportRe = regexp.MustCompile(`^ChatExport_(\d{4}-\d{2}-\d{2})(?: \((\d+)\))?$`)
-	saleRe   = regexp.MustCompile(`(?s)Сервер:\s*(.+?)\s*Персонаж:\s*(.+?)\s*(?:Название|Предмет):\s*(.+?)\s*(?:Кол-во|Количество):\s*([0-9]+)\s*Цена продажи:\s*\$([0-9\s,]+)`) // nolint:lll
+const configPath = "config.json"
+
+const (
+	exitOK            = 0
+	exitNoNewSales    = 2
+	exitParseWarnings = 3
+	exitExportMissing = 4
+	exitStoreLocked   = 5
+	exitTimeout       = 6
+	exitInterrupted   = 130
 )
 
 func main() {
-	cfg, err := loadOrCreateConfig("config.json")
-	if err != nil {
-		log.Fatal(err)
+	if len(os.Args) > 1 {
+		args, err := expandCommandAlias(os.Args[1:])
+		if err != nil {
+			fatal(err)
+		}
+		os.Args = append(os.Args[:1], args...)
 	}
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			initConsole(false)
+			if _, ok := daemonCommands[os.Args[1]]; ok {
+				if forwarded, err := forwardToDaemon(os.Args[1:]); forwarded {
+					if err != nil {
+						fatal(err)
+					}
+					flushOut()
+					return
+				}
+			}
+			if err := cmd(os.Args[2:]); err != nil {
+				fatal(err)
+			}
+			flushOut()
+			return
+		}
+	}
+
+	reportFlags := defineReportFlags(flag.CommandLine)
+	translit := flag.Bool("translit", false, "выводить текст латиницей (для консолей без поддержки UTF-8)")
+	batch := flag.Bool("batch", false, "пакетный режим: без меню, код возврата отражает результат")
+	demo := flag.Bool("demo", false, "показать все отчёты на встроенных демонстрационных данных")
+	watch := flag.Bool("watch", false, "следить за base_dir и перестраивать отчёт при появлении нового экспорта")
+	portable := flag.Bool("portable", false, "хранить config.json и state.json рядом с программой, а не в текущей папке")
+	flag.Parse()
+
+	initConsole(*translit)
 
-	dir, err := findLatestExport(cfg.BaseDir)
+	rf, err := reportFlags()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 
-	filePath := filepath.Join(dir, "messages.html")
-	f, err := os.Open(filePath)
-	if err != nil {
-		log.Fatalf("не удалось открыть %s: %v", filePath, err)
+	if *demo {
+		if err := runDemo(rf); err != nil {
+			fatal(err)
+		}
+		flushOut()
+		return
 	}
-	defer f.Close()
 
-	doc, err := goquery.NewDocumentFromReader(f)
+	if *portable {
+		if err := enterPortableDir(); err != nil {
+			fatal(err)
+		}
+	}
+	cfg, err := loadValidConfig()
 	if err != nil {
-		log.Fatalf("ошибка разбора HTML: %v", err)
+		fatal(err)
 	}
-
-	var sales []Sale
-	doc.Find("div.message").Each(func(_ int, msg *goquery.Selection) {
-		text := msg.Find("div.text").Text()
-		if !strings.Contains(text, "Вы успешно продали предмет") {
-			return
+	if *watch {
+		if err := watchReports(cfg, rf); err != nil {
+			fatal(err)
 		}
+		flushOut()
+		return
+	}
+	ctx, stop := runContext(cfg)
+	run, code, err := generateReport(ctx, cfg, rf)
+	stop()
+	if errors.Is(err, errInterrupted) {
+		fmt.Fprintln(out, "\nПолучен сигнал завершения: данные сохранены, работа остановлена.")
+		flushOut()
+		os.Exit(code)
+	}
+	if err != nil {
+		log.Print(err)
+		flushOut()
+		os.Exit(code)
+	}
 
-		dateTitle, ok := msg.Find("div.pull_right.date.details").Attr("title")
-		if !ok {
-			return
+	if *batch {
+		flushOut()
+		switch {
+		case len(run.parsed.Anomalies) > 0:
+			fatal(withKind(ErrMalformedMessage, fmt.Errorf("сообщений бота с ошибками разбора: %d", len(run.parsed.Anomalies))))
+		case rf.strict && len(run.parsed.Unparsed) > 0:
+			fatal(withKind(ErrMalformedMessage, fmt.Errorf("неразобранных сообщений бота: %d", len(run.parsed.Unparsed))))
+		case run.newSales == 0:
+			os.Exit(exitNoNewSales)
 		}
-		ts := strings.Split(dateTitle, " UTC")[0]
-		msgTime, err := time.ParseInLocation("02.01.2006 15:04:05", ts, time.Local)
+		os.Exit(exitOK)
+	}
+	runMenu(configPath, cfg, run.sales, run.now, func() ([]Sale, error) {
+		ctx, stop := runContext(cfg)
+		defer stop()
+		d, _, err := loadReportData(ctx, cfg, rf)
 		if err != nil {
-			return
+			return nil, err
 		}
-
-		m := saleRe.FindStringSubmatch(text)
-		if len(m) != 6 {
-			return
+		defer d.spool.Close()
+		if err := d.filter(cfg, rf, run.st); err != nil {
+			return nil, err
 		}
-
-		server := strings.TrimSpace(m[1])
-		character := strings.TrimSpace(m[2])
-		item := strings.TrimSpace(m[3])
-		if item == "Улучшенный эпинефрин" {
-			item = "Адреналин"
-		}
-		qty, _ := strconv.Atoi(m[4])
-		priceStr := strings.ReplaceAll(strings.ReplaceAll(m[5], " ", ""), ",", ".")
-		price, _ := strconv.ParseFloat(priceStr, 64)
-
-		sales = append(sales, Sale{Time: msgTime, Server: server, Character: character, Item: item, Quantity: qty, Price: price})
+		return d.sales, nil
 	})
+}
 
-	now := time.Now()
-	periods := []struct {
-		name   string
-		window time.Duration
-	}{{"all", 0}, {"day", 24 * time.Hour}, {"week", 7 * 24 * time.Hour}, {"month", 30 * 24 * time.Hour}}
+type runFlags struct {
+	arg            string
+	maxExports     int
+	merge          bool
+	recent         int
+	siteDir        string
+	anonymize      bool
+	asOf           string
+	opts           reportOptions
+	reportUnparsed bool
+	unparsedFile   string
+	strict         bool
+	tz             string
+	withRetired    bool
+	periodSpec     string
+	leaderboard    string
+	compare        string
+}
 
-	aggByPeriod := make(map[string]map[string]*Server)
-	for _, p := range periods {
-		aggByPeriod[p.name] = aggregateSales(sales, now, p.window)
+func defineReportFlags(fs *flag.FlagSet) func() (runFlags, error) {
+	maxExports := fs.Int("max-exports", 0, "брать только N самых новых экспортов ChatExport_* (0 — все)")
+	periodsFlag := fs.String("periods", "", "периоды через запятую: all,day,week,month, today,this-week,this-month,this-year или свои из periods (по умолчанию all,day,week,month)")
+	wide := fs.Bool("wide", false, "объединить периоды в одну сравнительную таблицу на персонажа")
+	trend := fs.Bool("trend", false, "под каждым выбранным предметом показать изменение к предыдущему такому же периоду (▲▼ и %)")
+	leaderboard := fs.String("leaderboard", "", "вывести рейтинг персонажей за период (например, week или свой из periods)")
+	compare := fs.String("compare-servers", "", "сравнить серверы за период (например, week или свой из periods)")
+	recent := fs.Int("recent", 0, "показать N последних продаж на каждом сервере (местное время и время сервера)")
+	siteDir := fs.String("site", "", "сохранить отчёт как статический сайт (index.html, data.json) в папку")
+	anonymize := fs.Bool("anonymize", false, "заменить имена и ID персонажей стабильными псевдонимами")
+	asOfFlag := fs.String("as-of", "", "построить отчёт так, будто сейчас указанный момент (2006-01-02 или 2006-01-02T15:04)")
+	merge := fs.Bool("merge", false, "объединить все папки ChatExport_* с удалением повторов")
+	reportUnparsed := fs.Bool("report-unparsed", false, "показать сообщения с фразой бота, которые не удалось разобрать")
+	unparsedFile := fs.String("unparsed-file", "", "записать полный текст неразобранных сообщений в файл")
+	tz := fs.String("tz", "", "часовой пояс для времени сообщений и отчёта, например Europe/Moscow (перекрывает timezone из config.json)")
+	strict := fs.Bool("strict", false, "как --report-unparsed, а в пакетном режиме неразобранные сообщения дают код возврата 3")
+	includeRetired := fs.Bool("include-retired", false, "показать и персонажей на покое (retired)")
+
+	return func() (runFlags, error) {
+		timezoneOverride = *tz
+		opts := reportOptions{wide: *wide, trend: *trend}
+		return runFlags{
+			arg:            fs.Arg(0),
+			maxExports:     *maxExports,
+			merge:          *merge,
+			recent:         *recent,
+			siteDir:        *siteDir,
+			anonymize:      *anonymize,
+			asOf:           *asOfFlag,
+			opts:           opts,
+			reportUnparsed: *reportUnparsed || *strict,
+			unparsedFile:   *unparsedFile,
+			strict:         *strict,
+			tz:             *tz,
+			withRetired:    *includeRetired,
+			periodSpec:     *periodsFlag,
+			leaderboard:    *leaderboard,
+			compare:        *compare,
+		}, nil
 	}
+}
 
-	for _, srvName := range sortedServerKeys(aggByPeriod["all"]) {
-		fmt.Printf("\nСервер: %s\n", srvName)
-		for _, charID := range sortedCharIDs(aggByPeriod["all"][srvName]) {
-			chAll := aggByPeriod["all"][srvName].Characters[charID]
-			fmt.Printf("Персонаж %s #%s:\n", chAll.Name, chAll.ID)
-			for _, p := range periods {
-				fmt.Printf("  -- %s --\n", p.name)
-				srv := aggByPeriod[p.name][srvName]
-				if srv == nil {
-					fmt.Println("    (нет данных)")
-					continue
-				}
-				ch := srv.Characters[charID]
-				if ch == nil {
-					fmt.Println("    (нет данных)")
-					continue
-				}
-				printCharacterItemStats(ch, cfg.Selected)
-			}
+func (rf *runFlags) resolvePeriods(cfg *Config) error {
+	periods, err := cfg.parsePeriods(rf.periodSpec)
+	if err != nil {
+		return err
+	}
+	rf.opts.periods = periods
+	if rf.leaderboard != "" {
+		lp, err := cfg.parsePeriods(rf.leaderboard)
+		if err != nil {
+			return err
+		}
+		rf.opts.leaderboard = &lp[0]
+	}
+	if rf.compare != "" {
+		cp, err := cfg.parsePeriods(rf.compare)
+		if err != nil {
+			return err
 		}
+		rf.opts.compare = &cp[0]
 	}
+	return nil
+}
 
-	itemsSet := make(map[string]struct{})
-	for _, s := range sales {
-		itemsSet[s.Item] = struct{}{}
+func cmdReport(args []string) error {
+	fs := newFlagSet("report")
+	reportFlags := defineReportFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
-	fmt.Println("\nСписок всех проданных предметов:")
-	var allItems []string
-	for it := range itemsSet {
-		allItems = append(allItems, it)
+	rf, err := reportFlags()
+	if err != nil {
+		return err
 	}
-	sort.Strings(allItems)
-	for _, it := range allItems {
-		fmt.Println(" -", it)
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
 	}
+	ctx, stop := runContext(cfg)
+	defer stop()
+	_, _, err = generateReport(ctx, cfg, rf)
+	return err
+}
 
-	fmt.Print("\nНажмите Enter для выхода...")
-	bufio.NewReader(os.Stdin).ReadBytes('\n')
+type reportRun struct {
+	parsed   *parseResult
+	sales    []Sale
+	newSales int
+	now      time.Time
+	st       *appState
 }
 
-func printCharacterItemStats(ch *Character, selected []string) {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "Тип предмета\tКол-во\tСумма продаж\tСредняя цена")
-	for _, item := range selected {
-		d := ch.Items[item]
-		if d == nil {
-			continue
-		}
-		avg := 0.0
-		if d.Count > 0 {
-			avg = d.Sum / float64(d.Count)
-		}
-		fmt.Fprintf(w, "%s\t%d\t$%.2f\t$%.2f\n", item, d.Count, d.Sum, avg)
-	}
-	w.Flush()
+var errInterrupted = errors.New("прервано сигналом")
 
-	var sumSel, sumAll float64
-	for _, item := range selected {
-		if d := ch.Items[item]; d != nil {
-			sumSel += d.Sum
-		}
+func generateReport(ctx context.Context, cfg *Config, rf runFlags) (*reportRun, int, error) {
+	if err := rf.resolvePeriods(cfg); err != nil {
+		return nil, 1, err
 	}
-	for _, d := range ch.Items {
-		sumAll += d.Sum
+	d, code, err := loadReportData(ctx, cfg, rf)
+	if err != nil {
+		return nil, code, err
 	}
-	fmt.Printf("    Сумма продаж выбранных позиций: $%.2f\n", sumSel)
-	fmt.Printf("    Общая сумма продаж:             $%.2f\n", sumAll)
-}
-
-func aggregateSales(sales []Sale, now time.Time, window time.Duration) map[string]*Server {
-	servers := make(map[string]*Server)
-	for _, s := range sales {
-		if window > 0 && now.Sub(s.Time) > window {
-			continue
+	defer d.spool.Close()
+	parsed, sales := d.parsed, d.sales
+	for _, w := range parsed.Warnings {
+		fmt.Fprintln(out, "Предупреждение:", w)
+	}
+	if n := len(parsed.Undated); n > 0 {
+		fmt.Fprintf(out, "Предупреждение: сообщений пропущено из-за неизвестного формата даты: %d\n", n)
+	}
+	printAnomalies(parsed.Anomalies, cfg)
+	if rf.reportUnparsed {
+		printUnparsed(parsed.Unparsed, cfg)
+	}
+	if rf.unparsedFile != "" {
+		if err := writeUnparsed(rf.unparsedFile, parsed.Unparsed, cfg); err != nil {
+			return nil, 1, err
 		}
+	}
 
-		namePart, idPart := splitCharacter(s.Character)
-		if idPart == "" {
-			idPart = namePart
+	st, err := loadState()
+	if err != nil {
+		return nil, exitCode(err), err
+	}
+	ingest := summarizeIngest(parsed, sales, st.LastSale, d.duplicates, rf.opts.periods, time.Now())
+	newSales := countNewSales(sales, st)
+	recordCharacterNames(st, sales)
+	if err := d.filter(cfg, rf, st); err != nil {
+		return nil, 1, err
+	}
+	sales, purchases, trades := d.sales, d.purchases, d.trades
+	listings, expired, now := d.listings, d.expired, d.now
+	if !rf.anonymize {
+		rf.opts.names = nameHistoryAsOf(st.CharacterNames, now)
+	}
+	if err := printReport(sales, d.history, purchases, trades, cfg, rf.opts, now); err != nil {
+		return nil, 1, err
+	}
+	if rf.recent > 0 {
+		printRecentSales(sales, cfg, rf.recent)
+	}
+	printOwnershipTotals(cfg, sales)
+	printPriceIndex(cfg, sales)
+	printMarketComparison(cfg, sales, now)
+	printForecast(cfg, st, sales, now)
+	printAliasCheck(sales, cfg.Language)
+	printRenameSuggestions(sales, cfg.Language)
+	lowStock := printStockReminders(cfg, sales, now)
+	printGoals(cfg, sales, now)
+	printLotStats(cfg, listings, expired, sales)
+	printTopBuyers(cfg, sales)
+
+	siteDir := rf.siteDir
+	if siteDir == "" {
+		siteDir = cfg.SiteDir
+	}
+	if siteDir != "" {
+		if err := writeSite(siteDir, sales, d.history, cfg, now); err != nil {
+			log.Printf("не удалось сохранить сайт: %v", err)
+		} else {
+			fmt.Fprintf(out, "\nСтатический отчёт сохранён в %s\n", siteDir)
 		}
+	}
 
-		srv := servers[s.Server]
-		if srv == nil {
-			srv = &Server{Name: s.Server, Characters: make(map[string]*Character)}
-			servers[s.Server] = srv
+	if rf.asOf == "" {
+		emitEvents(cfg, st, sales, now)
+		emitLowStock(cfg, lowStock, now)
+		if d.isStale {
+			emitStaleExport(cfg, st, d.stale, now)
 		}
-
-		ch := srv.Characters[idPart]
-		if ch == nil {
-			ch = &Character{ID: idPart, Name: namePart, LastSeen: s.Time, Items: make(map[string]*ItemStats)}
-			srv.Characters[idPart] = ch
-		} else if s.Time.After(ch.LastSeen) {
-			ch.Name = namePart
-			ch.LastSeen = s.Time
+		if err := st.save(); err != nil {
+			log.Printf("не удалось сохранить %s: %v", stateFile, err)
 		}
-
-		stats := ch.Items[s.Item]
-		if stats == nil {
-			stats = &ItemStats{}
-			ch.Items[s.Item] = stats
+		if err := recordIngest(ingest, now); err != nil {
+			log.Print(err)
 		}
-		stats.Count += s.Quantity
-		stats.Sum += s.Price
 	}
-	return servers
-}
-
-func sortedServerKeys(m map[string]*Server) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+	if err := ctxErr(ctx); err != nil {
+		return nil, exitCode(err), err
 	}
-	sort.Strings(keys)
-	return keys
+	return &reportRun{parsed: parsed, sales: sales, newSales: newSales, now: now, st: st}, exitOK, nil
 }
 
-func sortedCharIDs(srv *Server) []string {
-	keys := make([]string, 0, len(srv.Characters))
-	for id := range srv.Characters {
-		keys = append(keys, id)
-	}
-	sort.Slice(keys, func(i, j int) bool {
-		return srv.Characters[keys[i]].Name < srv.Characters[keys[j]].Name
-	})
-	return keys
+type reportData struct {
+	parsed     *parseResult
+	duplicates int
+	stale      staleExport
+	isStale    bool
+	sales      []Sale
+	history    historySales
+	spool      *saleSpool
+	purchases  []Purchase
+	trades     []Trade
+	listings   []Listing
+	expired    []Listing
+	now        time.Time
 }
 
-func splitCharacter(full string) (name, id string) {
-	if i := strings.LastIndex(full, "#"); i != -1 {
-		name = strings.TrimSpace(full[:i])
-		id = strings.TrimSpace(full[i+1:])
+func loadReportData(ctx context.Context, cfg *Config, rf runFlags) (*reportData, int, error) {
+	var exports []exportInfo
+	var err error
+	if rf.arg != "" {
+		exports, err = exportsFromArg(ctx, rf.arg, rf.maxExports)
 	} else {
-		name = strings.TrimSpace(full)
+		exports, err = listExports(ctx, cfg.BaseDir, rf.maxExports)
 	}
-	return
-}
-
-func loadOrCreateConfig(path string) (*Config, error) {
-	var cfg Config
-	file, err := os.Open(path)
-	if err == nil {
-		defer file.Close()
-		if err := json.NewDecoder(file).Decode(&cfg); err == nil && cfg.BaseDir != "" && len(cfg.Selected) > 0 {
-			return &cfg, nil
+	if err != nil && rf.arg == "" && cfg.SalesDB != "" && errors.Is(err, ErrExportNotFound) {
+		fmt.Fprintf(out, "Экспорты не найдены — отчёт строится только по базе продаж %s\n", salesDBName(cfg.SalesDB))
+		exports, err = nil, nil
+	}
+	if err != nil {
+		code, err := failCode(ctx, err, exitExportMissing)
+		return nil, code, err
+	}
+	stale, isStale := findStaleExport(cfg, exports, time.Now())
+	isStale = isStale && rf.arg == "" && rf.asOf == ""
+	if isStale {
+		printStaleWarning(cfg, stale)
+	}
+	var parsed *parseResult
+	var duplicates int
+	if len(exports) == 0 {
+		parsed = &parseResult{}
+	} else if rf.merge || cfg.MergeExports {
+		parsed, duplicates, err = parseAllExports(ctx, exports, cfg)
+		if err != nil {
+			code, err := failCode(ctx, err, exitExportMissing)
+			return nil, code, err
+		}
+		fmt.Fprintf(out, "Объединено экспортов: %d, повторов отброшено: %d\n", len(exports), duplicates)
+	} else {
+		dir := exports[0].Path
+		parsed, err = parseExport(ctx, dir, cfg)
+		if err != nil {
+			code, err := failCode(ctx, err, exitExportMissing)
+			return nil, code, err
+		}
+		if err := cfg.verifyChat(dir, parsed.ChatName); err != nil {
+			return nil, exitExportMissing, err
 		}
 	}
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Введите путь к каталогу ChatExport_*: ")
-	baseDir, _ := reader.ReadString('\n')
-	baseDir = strings.TrimSpace(baseDir)
-
-	fmt.Println("Введите названия предметов (пустая строка для завершения):")
-	var items []string
-	for {
-		line, _ := reader.ReadString('\n')
-		line = strings.TrimSpace(line)
-		if line == "" {
-			break
+	live, err := mergeLiveTrades(cfg, parsed)
+	if err != nil {
+		return nil, 1, err
+	}
+	if live > 0 {
+		fmt.Fprintf(out, "Продаж из живого потока бота и скриншотов, которых нет в экспорте: %d\n", live)
+	}
+	autoPrune(ctx, cfg, time.Now())
+	through := prunedThrough()
+	purged, err := loadPurgeLog()
+	if err != nil {
+		return nil, 1, err
+	}
+	if cfg.SalesLedger {
+		n, err := appendLedger(retainedSales(parsed.Sales, through, purged))
+		if err != nil {
+			return nil, 1, fmt.Errorf("не удалось дописать %s: %w", salesLedgerFile, err)
+		}
+		if n > 0 {
+			fmt.Fprintf(out, "Новых продаж записано в %s: %d\n", salesLedgerFile, n)
 		}
-		items = append(items, line)
 	}
-
-	cfg = Config{BaseDir: baseDir, Selected: items}
-	f, err := os.Create(path)
-	if err == nil {
-		defer f.Close()
-		_ = json.NewEncoder(f).Encode(cfg)
+	var spool *saleSpool
+	if cfg.MemoryLimitMB > 0 {
+		spool = newSaleSpool(cfg.MemoryLimitMB)
 	}
-	return &cfg, nil
-}
-
-func findLatestExport(base string) (string, error) {
-	entries, err := os.ReadDir(base)
+	stored, history, err := mergeSalesDB(ctx, cfg, parsed, through, purged, spool)
 	if err != nil {
-		return "", fmt.Errorf("не удалось открыть %s: %w", base, err)
+		spool.Close()
+		code, err := failCode(ctx, err, 1)
+		return nil, code, err
 	}
-	var best *struct {
-		path    string
-		date    time.Time
-		variant int
+	if stored > 0 || history > 0 {
+		fmt.Fprintf(out, "База продаж %s: новых записей %d, из истории добавлено продаж, которых нет в экспортах: %d\n", salesDBName(cfg.SalesDB), stored, history)
+	}
+	sales, corrected, err := applyCorrections(parsed.Sales, cfg)
+	if err != nil {
+		spool.Close()
+		return nil, 1, err
 	}
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
+	if corrected > 0 {
+		fmt.Fprintf(out, "Исправлено или аннулировано продаж: %d (%s)\n", corrected, correctionsFile)
+	}
+	attributeSales(cfg, sales)
+	d := &reportData{
+		parsed: parsed, duplicates: duplicates, stale: stale, isStale: isStale,
+		sales: sales, purchases: parsed.Purchases, trades: parsed.Trades,
+		listings: parsed.Listings, expired: parsed.Expired,
+	}
+	if spool != nil && history > 0 {
+		corrections, err := loadCorrectionSet()
+		if err != nil {
+			spool.Close()
+			return nil, 1, err
 		}
-		m := exportRe.FindStringSubmatch(e.Name())
-		if len(m) == 0 {
-			continue
+		d.spool = spool
+		d.history = historySales(spool.Each).filter(func(s Sale) (Sale, bool) {
+			s, ok := corrections.apply(s, cfg)
+			attributeSale(cfg, &s)
+			return s, ok
+		})
+	} else {
+		spool.Close()
+	}
+	return d, exitOK, nil
+}
+
+func (d *reportData) filter(cfg *Config, rf runFlags, st *appState) error {
+	if !rf.withRetired {
+		var hidden int
+		d.sales, hidden = withoutRetired(cfg, d.sales, func(s Sale) string { return s.Character })
+		d.purchases, _ = withoutRetired(cfg, d.purchases, func(p Purchase) string { return p.Character })
+		d.trades, _ = withoutRetired(cfg, d.trades, func(t Trade) string { return t.Character })
+		d.listings, _ = withoutRetired(cfg, d.listings, func(l Listing) string { return l.Character })
+		d.expired, _ = withoutRetired(cfg, d.expired, func(l Listing) string { return l.Character })
+		d.history = d.history.filter(func(s Sale) (Sale, bool) { return s, !cfg.isRetired(s.Character) })
+		if hidden > 0 {
+			fmt.Fprintf(out, "Продаж персонажей на покое скрыто: %d (--include-retired — показать)\n", hidden)
 		}
-		d, err := time.Parse("2006-01-02", m[1])
+	}
+	if rf.anonymize {
+		salt, err := anonymizeSalt(cfg, st)
 		if err != nil {
-			continue
-		}
-		v := 0
-		if m[2] != "" {
-			v, _ = strconv.Atoi(m[2])
+			return fmt.Errorf("не удалось создать соль для --anonymize: %w", err)
 		}
-		info := &struct {
-			path    string
-			date    time.Time
-			variant int
-		}{filepath.Join(base, e.Name()), d, v}
-		if best == nil || info.date.After(best.date) || (info.date.Equal(best.date) && info.variant > best.variant) {
-			best = info
+		d.sales = anonymizeSales(d.sales, salt)
+		d.purchases = anonymizePurchases(d.purchases, salt)
+		d.trades = anonymizeTrades(d.trades, salt)
+		d.listings = anonymizeListings(d.listings, salt)
+		d.expired = anonymizeListings(d.expired, salt)
+		d.history = d.history.filter(func(s Sale) (Sale, bool) { return anonymizeSales([]Sale{s}, salt)[0], true })
+	}
+
+	d.now = time.Now()
+	if rf.asOf != "" {
+		asOf, err := parseAsOf(rf.asOf)
+		if err != nil {
+			return err
 		}
+		d.now = asOf
+		d.sales = salesAsOf(d.sales, asOf)
+		d.purchases = purchasesAsOf(d.purchases, asOf)
+		d.trades = tradesAsOf(d.trades, asOf)
+		d.listings = listingsAsOf(d.listings, asOf)
+		d.expired = listingsAsOf(d.expired, asOf)
+		d.history = d.history.filter(func(s Sale) (Sale, bool) { return s, !s.Time.After(asOf) })
+		fmt.Fprintf(out, "Отчёт на прошлый момент: %s (продажи после него не учитываются)\n", formatDateTime(asOf, cfg.Language))
 	}
-	if best == nil {
-		return "", fmt.Errorf("не найдено ни одной папки ChatExport_* в %s", base)
+	return nil
+}
+
+func countNewSales(sales []Sale, st *appState) int {
+	n := 0
+	last := st.LastSale
+	for _, s := range sales {
+		if s.Time.After(st.LastSale) {
+			n++
+		}
+		if s.Time.After(last) {
+			last = s.Time
+		}
 	}
-	return best.path, nil
+	st.LastSale = last
+	return n
 }
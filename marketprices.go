@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const marketPricesCacheFile = "market_prices_cache.json"
+
+type PriceSource struct {
+	URL          string  `json:"url"`
+	CacheMinutes float64 `json:"cache_minutes,omitempty"`
+}
+
+type marketPricesCache struct {
+	URL       string             `json:"url"`
+	FetchedAt time.Time          `json:"fetched_at"`
+	Prices    map[string]float64 `json:"prices"`
+}
+
+func (ps *PriceSource) cacheTTL() time.Duration {
+	if ps.CacheMinutes > 0 {
+		return time.Duration(ps.CacheMinutes * float64(time.Minute))
+	}
+	return time.Hour
+}
+
+func fetchMarketPrices(ps *PriceSource, cfg *Config, now time.Time) (map[string]float64, error) {
+	if data, err := storage.Get(marketPricesCacheFile); err == nil {
+		var c marketPricesCache
+		if json.Unmarshal(data, &c) == nil && c.URL == ps.URL && now.Sub(c.FetchedAt) < ps.cacheTTL() {
+			return c.Prices, nil
+		}
+	}
+
+	resp, err := httpClient.Get(ps.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	var raw map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("ожидается JSON-объект «предмет: цена»: %w", err)
+	}
+	prices := make(map[string]float64, len(raw))
+	for item, price := range raw {
+		base, _ := cfg.splitQuality(item)
+		prices[cfg.canonicalItem(base)] = price
+	}
+
+	if data, err := json.MarshalIndent(marketPricesCache{URL: ps.URL, FetchedAt: now, Prices: prices}, "", "  "); err == nil {
+		_ = storage.Put(marketPricesCacheFile, data)
+	}
+	return prices, nil
+}
+
+func printMarketComparison(cfg *Config, sales []Sale, now time.Time) {
+	if cfg.PriceSource == nil {
+		return
+	}
+	prices, err := fetchMarketPrices(cfg.PriceSource, cfg, now)
+	if err != nil {
+		fmt.Fprintf(out, "\nНе удалось получить рыночные цены: %v\n", err)
+		return
+	}
+
+	mine := make(map[string]*ItemStats)
+	for _, s := range sales {
+		st := mine[s.Item]
+		if st == nil {
+			st = &ItemStats{}
+			mine[s.Item] = st
+		}
+		st.Count += s.Quantity
+		st.Sum += s.Price
+	}
+
+	fmt.Fprintln(out, "\nМои цены и рынок (за штуку):")
+	w := newTable()
+	fmt.Fprintln(w, "    Предмет\tМоя средняя\tРыночная\tРазница")
+	for _, item := range cfg.selected {
+		st, market := mine[item], prices[item]
+		if st == nil || st.Count == 0 || market <= 0 {
+			fmt.Fprintf(w, "    %s\t-\t-\t-\n", item)
+			continue
+		}
+		avg := st.average()
+		fmt.Fprintf(w, "    %s\t$%.2f\t$%.2f\t%+.1f%%\n", item, avg, market, (avg/market-1)*100)
+	}
+	w.Flush()
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func readLine() (string, bool) {
+	flushOut()
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
+
+func runMenu(cfgPath string, cfg *Config, sales []Sale, now time.Time, reload func() ([]Sale, error)) {
+	for {
+		fmt.Fprintln(out, "\nМеню:")
+		fmt.Fprintln(out, "  1 — выход")
+		fmt.Fprintln(out, "  2 — добавить / удалить предметы")
+		fmt.Fprintln(out, "  3 — сменить папку экспорта")
+		fmt.Fprintln(out, "  4 — отчёт по выбранным персонажам и предметам")
+		fmt.Fprintln(out, "  5 — график цены предмета")
+		fmt.Fprint(out, "> ")
+		choice, ok := readLine()
+		if !ok {
+			return
+		}
+		switch choice {
+		case "1":
+			return
+		case "2":
+			editSelectedItems(cfgPath, cfg)
+		case "3":
+			if changeBaseDir(cfgPath, cfg) {
+				sales = reloadSales(sales, reload)
+			}
+		case "4":
+			adHocReport(cfg, sales, now)
+		case "5":
+			priceChart(cfg, sales)
+		default:
+			fmt.Fprintln(out, "Неизвестная команда")
+		}
+	}
+}
+
+func editSelectedItems(cfgPath string, cfg *Config) {
+	saved, err := readConfig(cfgPath)
+	if err != nil {
+		fmt.Fprintf(out, "Не удалось прочитать настройки: %v\n", err)
+		return
+	}
+	for {
+		fmt.Fprintln(out, "\nОтслеживаемые предметы:")
+		for i, it := range saved.Selected {
+			fmt.Fprintf(out, "  %d. %s\n", i+1, it)
+		}
+		fmt.Fprintln(out, "«+ название» — добавить, «- номер» — удалить, пустая строка — назад")
+		fmt.Fprint(out, "> ")
+		line, ok := readLine()
+		if !ok || line == "" {
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "+"):
+			item := strings.TrimSpace(line[1:])
+			if item == "" {
+				continue
+			}
+			saved.Selected = append(saved.Selected, item)
+		case strings.HasPrefix(line, "-"):
+			n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+			if err != nil || n < 1 || n > len(saved.Selected) {
+				fmt.Fprintln(out, "Нет предмета с таким номером")
+				continue
+			}
+			saved.Selected = append(saved.Selected[:n-1], saved.Selected[n:]...)
+		default:
+			fmt.Fprintln(out, "Неизвестная команда")
+			continue
+		}
+		if err := saveConfig(cfgPath, saved); err != nil {
+			fmt.Fprintf(out, "Не удалось сохранить настройки: %v\n", err)
+		}
+		cfg.Selected = append([]string(nil), saved.Selected...)
+		cfg.selected, _ = cfg.normalizeSelected(saved.Selected)
+	}
+}
+
+func changeBaseDir(cfgPath string, cfg *Config) bool {
+	fmt.Fprintf(out, "Текущая папка: %s\nНовый путь (пустая строка — отмена): ", cfg.BaseDir)
+	line, ok := readLine()
+	if !ok || line == "" {
+		return false
+	}
+	saved, err := readConfig(cfgPath)
+	if err != nil {
+		fmt.Fprintf(out, "Не удалось прочитать настройки: %v\n", err)
+		return false
+	}
+	saved.BaseDir = line
+	if err := saveConfig(cfgPath, saved); err != nil {
+		fmt.Fprintf(out, "Не удалось сохранить настройки: %v\n", err)
+		return false
+	}
+	cfg.BaseDir = line
+	return true
+}
+
+func reloadSales(prev []Sale, reload func() ([]Sale, error)) []Sale {
+	start := time.Now()
+	sales, err := reload()
+	if err != nil {
+		fmt.Fprintf(out, "Папка сохранена, но не удалось её разобрать: %v\n", err)
+		return prev
+	}
+	fmt.Fprintf(out, "Папка сохранена, загружено продаж: %d (%.2f с). Отчёты меню используют новую папку.\n", len(sales), time.Since(start).Seconds())
+	return sales
+}
+
+func selectOptions(title string, labels []string) []bool {
+	checked := make([]bool, len(labels))
+	for {
+		fmt.Fprintf(out, "\n%s:\n", title)
+		for i, l := range labels {
+			mark := " "
+			if checked[i] {
+				mark = "x"
+			}
+			fmt.Fprintf(out, "  [%s] %d. %s\n", mark, i+1, l)
+		}
+		fmt.Fprintln(out, "Номера через пробел — отметить/снять, «*» — все, пустая строка — готово")
+		fmt.Fprint(out, "> ")
+		line, ok := readLine()
+		if !ok || line == "" {
+			return checked
+		}
+		for _, f := range strings.Fields(line) {
+			if f == "*" {
+				for i := range checked {
+					checked[i] = true
+				}
+				continue
+			}
+			n, err := strconv.Atoi(f)
+			if err != nil || n < 1 || n > len(labels) {
+				fmt.Fprintf(out, "Пропущено: %s\n", f)
+				continue
+			}
+			checked[n-1] = !checked[n-1]
+		}
+	}
+}
+
+func adHocReport(cfg *Config, sales []Sale, now time.Time) {
+	all := aggregateSales(sales, now, allTime)
+	type charRef struct{ server, id string }
+	var refs []charRef
+	var labels []string
+	for _, srvName := range sortedServerKeys(all, cfg.Language) {
+		for _, id := range sortedCharIDs(all[srvName], cfg.Language) {
+			ch := all[srvName].Characters[id]
+			refs = append(refs, charRef{srvName, id})
+			labels = append(labels, fmt.Sprintf("%s #%s (%s)", ch.Name, ch.ID, srvName))
+		}
+	}
+	if len(refs) == 0 {
+		fmt.Fprintln(out, "Нет данных о продажах")
+		return
+	}
+	chosenChars := make(map[charRef]bool)
+	for i, ok := range selectOptions("Персонажи", labels) {
+		if ok {
+			chosenChars[refs[i]] = true
+		}
+	}
+
+	itemsSet := make(map[string]bool)
+	for _, s := range sales {
+		itemsSet[s.Item] = true
+	}
+	var items []string
+	for it := range itemsSet {
+		items = append(items, it)
+	}
+	sortNames(items, cfg.Language)
+	chosenItems := make(map[string]bool)
+	for i, ok := range selectOptions("Предметы", items) {
+		if ok {
+			chosenItems[items[i]] = true
+		}
+	}
+	if len(chosenChars) == 0 || len(chosenItems) == 0 {
+		fmt.Fprintln(out, "Ничего не выбрано")
+		return
+	}
+
+	var filtered []Sale
+	for _, s := range sales {
+		name, id := splitCharacter(s.Character)
+		if id == "" {
+			id = name
+		}
+		if chosenChars[charRef{s.Server, id}] && chosenItems[s.Item] {
+			filtered = append(filtered, s)
+		}
+	}
+
+	fmt.Fprintf(out, "\nСводный отчёт: персонажей %d, предметов %d\n", len(chosenChars), len(chosenItems))
+	for _, p := range cfg.exportPeriods() {
+		fmt.Fprintf(out, "  -- %s --\n", p.name)
+		stats := make(map[string]*ItemStats)
+		var total Money
+		for _, s := range filtered {
+			if !p.contains(s.Time, now) {
+				continue
+			}
+			d := stats[s.Item]
+			if d == nil {
+				d = &ItemStats{}
+				stats[s.Item] = d
+			}
+			d.Count += s.Quantity
+			d.Sum += s.Price
+			total += s.Price
+		}
+		if len(stats) == 0 {
+			fmt.Fprintln(out, "    (нет данных)")
+			continue
+		}
+		w := newTable()
+		fmt.Fprintln(w, "Тип предмета\tКол-во\tСумма продаж\tСредняя цена")
+		for _, it := range items {
+			d := stats[it]
+			if d == nil {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%d\t$%.2f\t$%.2f\n", it, d.Count, d.Sum, d.average())
+		}
+		w.Flush()
+		fmt.Fprintf(out, "    Итого: $%.2f\n", total)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+func tradeKey(msgID int64, t time.Time, server, character, item string, qty int, price Money) string {
+	if msgID != 0 {
+		return "m" + strconv.FormatInt(msgID, 10)
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%s|%s|%s|%d|%.2f", t.Unix(), server, character, item, qty, price)
+	return "h" + strconv.FormatUint(h.Sum64(), 16)
+}
+
+func (s Sale) key() string {
+	return tradeKey(s.MsgID, s.Time, s.Server, s.Character, s.RawItem, s.Quantity, s.Price)
+}
+
+func (p Purchase) key() string {
+	return tradeKey(p.MsgID, p.Time, p.Server, p.Character, p.Item, p.Quantity, p.Price)
+}
+
+func (p Purchase) contentKey() string {
+	p.MsgID = 0
+	return p.key()
+}
+
+type keySequence map[string]int
+
+func (q keySequence) next(key string, msgID int64) string {
+	if msgID != 0 {
+		return key
+	}
+	n := q[key]
+	q[key] = n + 1
+	if n == 0 {
+		return key
+	}
+	return key + "#" + strconv.Itoa(n)
+}
+
+type mergeSet struct {
+	ids       map[string]bool
+	content   map[string]bool
+	anonymous map[string]bool
+}
+
+func newMergeSet() *mergeSet {
+	return &mergeSet{ids: make(map[string]bool), content: make(map[string]bool), anonymous: make(map[string]bool)}
+}
+
+func (m *mergeSet) add(msgID int64, key, content string, seq keySequence) bool {
+	content = seq.next(content, 0)
+	switch {
+	case msgID == 0 && m.content[content]:
+		return false
+	case msgID == 0:
+		m.anonymous[content] = true
+	case m.ids[key] || m.anonymous[content]:
+		return false
+	default:
+		m.ids[key] = true
+	}
+	m.content[content] = true
+	return true
+}
+
+func parseAllExports(ctx context.Context, exports []exportInfo, cfg *Config) (merged *parseResult, duplicates int, err error) {
+	merged = &parseResult{}
+	seenSales, seenPurchases, seenTrades := newMergeSet(), newMergeSet(), newMergeSet()
+	seenListings, seenExpired := newMergeSet(), newMergeSet()
+	seenAnomalies := make(map[string]bool)
+	seenUnparsed := make(map[string]bool)
+	seenUndated := make(map[string]bool)
+	results := make([]*parseResult, len(exports))
+	err = forEachParallel(len(exports), cfg.parseWorkers(), func(i int) error {
+		res, err := parseExport(ctx, exports[i].Path, cfg)
+		results[i] = res
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := len(exports) - 1; i >= 0; i-- {
+		dir, res := exports[i].Path, results[i]
+		if err := cfg.verifyChat(dir, res.ChatName); err != nil {
+			return nil, 0, err
+		}
+		merged.ChatName, merged.Layout = res.ChatName, res.Layout
+		merged.Warnings = append(merged.Warnings, res.Warnings...)
+
+		saleSeq, purchaseSeq, tradeSeq := make(keySequence), make(keySequence), make(keySequence)
+		listingSeq, expiredSeq := make(keySequence), make(keySequence)
+		for _, s := range res.Sales {
+			if seenSales.add(s.MsgID, s.key(), contentKey(s), saleSeq) {
+				merged.Sales = append(merged.Sales, s)
+			} else {
+				duplicates++
+			}
+		}
+		for _, p := range res.Purchases {
+			if seenPurchases.add(p.MsgID, p.key(), p.contentKey(), purchaseSeq) {
+				merged.Purchases = append(merged.Purchases, p)
+			} else {
+				duplicates++
+			}
+		}
+		for _, t := range res.Trades {
+			if seenTrades.add(t.MsgID, t.key(), t.contentKey(), tradeSeq) {
+				merged.Trades = append(merged.Trades, t)
+			} else {
+				duplicates++
+			}
+		}
+		for _, l := range res.Listings {
+			if seenListings.add(l.MsgID, l.key(), l.contentKey(), listingSeq) {
+				merged.Listings = append(merged.Listings, l)
+			} else {
+				duplicates++
+			}
+		}
+		for _, l := range res.Expired {
+			if seenExpired.add(l.MsgID, l.key(), l.contentKey(), expiredSeq) {
+				merged.Expired = append(merged.Expired, l)
+			} else {
+				duplicates++
+			}
+		}
+		for _, a := range res.Anomalies {
+			k := a.Time.String() + "|" + a.Text
+			if !seenAnomalies[k] {
+				seenAnomalies[k] = true
+				merged.Anomalies = append(merged.Anomalies, a)
+			}
+		}
+		for _, a := range res.Unparsed {
+			k := a.Time.String() + "|" + a.Text
+			if !seenUnparsed[k] {
+				seenUnparsed[k] = true
+				merged.Unparsed = append(merged.Unparsed, a)
+			}
+		}
+		for _, a := range res.Undated {
+			k := a.Reason + "|" + a.Text
+			if !seenUndated[k] {
+				seenUndated[k] = true
+				merged.Undated = append(merged.Undated, a)
+			}
+		}
+	}
+	sortSalesByTime(merged.Sales)
+	return merged, duplicates, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Money int64
+
+const dollar Money = 100
+
+var errBadAmount = errors.New("неверная сумма")
+
+func moneyFromFloat(v float64) Money {
+	return Money(math.Round(v * 100))
+}
+
+func (m Money) Float() float64 {
+	return float64(m) / 100
+}
+
+func (m Money) String() string {
+	sign := ""
+	abs := int64(m)
+	if abs < 0 {
+		sign, abs = "-", -abs
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
+
+func (m Money) Format(f fmt.State, verb rune) {
+	if prec, ok := f.Precision(); (verb != 'f' && verb != 'v' && verb != 's') || (ok && prec != 2) {
+		fmt.Fprintf(f, fmt.FormatString(f, verb), m.Float())
+		return
+	}
+	s := m.String()
+	if f.Flag('+') && m >= 0 {
+		s = "+" + s
+	}
+	if w, ok := f.Width(); ok && len(s) < w {
+		pad := strings.Repeat(" ", w-len(s))
+		if f.Flag('-') {
+			s += pad
+		} else {
+			s = pad + s
+		}
+	}
+	fmt.Fprint(f, s)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := m.String()
+	s = strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+	if s == "" || s == "-" {
+		s = "0"
+	}
+	return []byte(s), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	v, err := parseMoney(string(data))
+	if err != nil {
+		return fmt.Errorf("%w %s", errBadAmount, data)
+	}
+	*m = v
+	return nil
+}
+
+func parseMoney(s string) (Money, error) {
+	if strings.ContainsAny(s, "eE") {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsInf(v, 0) || math.IsNaN(v) {
+			return 0, errBadAmount
+		}
+		return moneyFromFloat(v), nil
+	}
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg, s = true, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, errBadAmount
+	}
+	var cents int64
+	for _, c := range whole {
+		if c < '0' || c > '9' {
+			return 0, errBadAmount
+		}
+		cents = cents*10 + int64(c-'0')
+		if cents > math.MaxInt64/1000 {
+			return 0, errBadAmount
+		}
+	}
+	cents *= 100
+	for i, c := range frac {
+		if c < '0' || c > '9' {
+			return 0, errBadAmount
+		}
+		switch {
+		case i == 0:
+			cents += int64(c-'0') * 10
+		case i == 1:
+			cents += int64(c - '0')
+		case i == 2 && c >= '5':
+			cents++
+		}
+	}
+	if neg {
+		cents = -cents
+	}
+	return Money(cents), nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Notifier interface {
+	Notify(ev hookEvent) error
+}
+
+type NotifyChannel struct {
+	Type      string   `json:"type"`
+	Events    []string `json:"events,omitempty"`
+	Threshold Money    `json:"threshold,omitempty"`
+
+	URL      string   `json:"url,omitempty"`
+	BotToken string   `json:"bot_token,omitempty"`
+	ChatID   string   `json:"chat_id,omitempty"`
+	SMTPHost string   `json:"smtp_host,omitempty"`
+	SMTPPort int      `json:"smtp_port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+type routedNotifier struct {
+	channel  NotifyChannel
+	notifier Notifier
+}
+
+var notifierFactories = map[string]func(NotifyChannel) (Notifier, error){
+	"discord":  newDiscordNotifier,
+	"telegram": newTelegramNotifier,
+	"email":    newEmailNotifier,
+	"webhook":  newWebhookNotifier,
+	"desktop":  func(NotifyChannel) (Notifier, error) { return desktopNotifier{}, nil },
+}
+
+func buildNotifiers(channels []NotifyChannel) ([]routedNotifier, error) {
+	var res []routedNotifier
+	for i, ch := range channels {
+		factory, ok := notifierFactories[ch.Type]
+		if !ok {
+			return nil, fmt.Errorf("канал уведомлений #%d: неизвестный тип %q", i+1, ch.Type)
+		}
+		n, err := factory(ch)
+		if err != nil {
+			return nil, fmt.Errorf("канал уведомлений #%d (%s): %w", i+1, ch.Type, err)
+		}
+		res = append(res, routedNotifier{channel: ch, notifier: n})
+	}
+	return res, nil
+}
+
+func (ch NotifyChannel) wants(ev hookEvent) bool {
+	if len(ch.Events) > 0 && !slices.Contains(ch.Events, ev.Name) {
+		return false
+	}
+	if ev.Name == eventDailyRevenueAbove {
+		return ev.Data["threshold"] == fmt.Sprintf("%.2f", ch.Threshold)
+	}
+	return true
+}
+
+func emit(cfg *Config, ev hookEvent) {
+	if user := cfg.discordUsers[ev.Data["id"]]; user != "" {
+		ev.Data["discord_user"] = user
+	}
+	fireHooks(cfg.Hooks, ev)
+	for _, rn := range cfg.notifiers {
+		if !rn.channel.wants(ev) {
+			continue
+		}
+		if err := rn.notifier.Notify(ev); err != nil {
+			log.Printf("уведомление %s (%s): %v", rn.channel.Type, ev.Name, err)
+		}
+	}
+}
+
+func eventMessage(ev hookEvent) (title, body string) {
+	d := ev.Data
+	switch ev.Name {
+	case eventIngestFinished:
+		body = fmt.Sprintf("Разбор завершён: %s продаж.", d["sales"])
+		if d["new_items"] != "" {
+			body += " Новые предметы: " + d["new_items"]
+		}
+		return "Market: отчёт обновлён", body
+	case eventNewItemSeen:
+		return "Market: новый предмет", fmt.Sprintf("В продажах появился предмет «%s».", d["item"])
+	case eventDailyRevenueAbove:
+		return "Market: дневная выручка", fmt.Sprintf("%s #%s (%s): выручка за сегодня $%s превысила $%s.", d["character"], d["id"], d["server"], d["revenue"], d["threshold"])
+	case eventGoalReached:
+		who := ""
+		if d["character"] != "" {
+			who = d["character"] + ", поздравляем! "
+		}
+		return "Market: цель выполнена", fmt.Sprintf("%sЦель «%s» (%s) выполнена: %s из %s.", who, d["goal"], d["period"], d["done"], d["target"])
+	case eventTopSeller:
+		return "Market: лучший продавец дня", fmt.Sprintf("%s: лучший продавец — %s #%s (%s), выручка $%s, продаж: %s.", d["date"], d["character"], d["id"], d["server"], d["revenue"], d["sales"])
+	case eventExportStale:
+		return "Market: экспорт устарел", fmt.Sprintf("Последний экспорт %s сделан %s — %s дн. назад. Сделайте новый экспорт чата.", d["export"], d["date"], d["days"])
+	case eventLowStock:
+		return "Market: заканчивается запас", fmt.Sprintf("«%s»: осталось %s шт., хватит примерно на %s дн.", d["item"], d["remaining"], d["days_left"])
+	}
+	keys := make([]string, 0, len(d))
+	for k, v := range d {
+		keys = append(keys, k+"="+v)
+	}
+	slices.Sort(keys)
+	return "Market: " + ev.Name, strings.Join(keys, ", ")
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+func postJSON(u string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(u, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
+
+type discordNotifier struct{ url string }
+
+func newDiscordNotifier(ch NotifyChannel) (Notifier, error) {
+	if ch.URL == "" {
+		return nil, fmt.Errorf("не указан url вебхука")
+	}
+	return discordNotifier{url: ch.URL}, nil
+}
+
+func (n discordNotifier) Notify(ev hookEvent) error {
+	title, body := eventMessage(ev)
+	content := "**" + title + "**\n" + body
+	user := ev.Data["discord_user"]
+	if user == "" {
+		return postJSON(n.url, map[string]string{"content": content})
+	}
+	allowed := []string{}
+	if isDiscordUserID(user) {
+		allowed = append(allowed, user)
+	}
+	return postJSON(n.url, map[string]any{
+		"content":          discordMention(user) + " " + content,
+		"allowed_mentions": map[string]any{"parse": []string{}, "users": allowed},
+	})
+}
+
+type telegramNotifier struct{ token, chatID string }
+
+func newTelegramNotifier(ch NotifyChannel) (Notifier, error) {
+	if ch.BotToken == "" || ch.ChatID == "" {
+		return nil, fmt.Errorf("нужны bot_token и chat_id")
+	}
+	return telegramNotifier{token: ch.BotToken, chatID: ch.ChatID}, nil
+}
+
+func (n telegramNotifier) Notify(ev hookEvent) error {
+	title, body := eventMessage(ev)
+	return postJSON("https://api.telegram.org/bot"+url.PathEscape(n.token)+"/sendMessage", map[string]string{
+		"chat_id": n.chatID,
+		"text":    title + "\n" + body,
+	})
+}
+
+type emailNotifier struct{ ch NotifyChannel }
+
+func newEmailNotifier(ch NotifyChannel) (Notifier, error) {
+	if ch.SMTPHost == "" || ch.From == "" || len(ch.To) == 0 {
+		return nil, fmt.Errorf("нужны smtp_host, from и to")
+	}
+	if ch.SMTPPort == 0 {
+		ch.SMTPPort = 587
+	}
+	return emailNotifier{ch: ch}, nil
+}
+
+func (n emailNotifier) Notify(ev hookEvent) error {
+	title, body := eventMessage(ev)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		n.ch.From, strings.Join(n.ch.To, ", "), mimeHeader(title), body)
+	var auth smtp.Auth
+	if n.ch.Username != "" {
+		auth = smtp.PlainAuth("", n.ch.Username, n.ch.Password, n.ch.SMTPHost)
+	}
+	addr := fmt.Sprintf("%s:%d", n.ch.SMTPHost, n.ch.SMTPPort)
+	return smtp.SendMail(addr, auth, n.ch.From, n.ch.To, msg.Bytes())
+}
+
+func mimeHeader(s string) string {
+	return "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(s)) + "?="
+}
+
+type webhookNotifier struct{ url string }
+
+func newWebhookNotifier(ch NotifyChannel) (Notifier, error) {
+	if ch.URL == "" {
+		return nil, fmt.Errorf("не указан url")
+	}
+	return webhookNotifier{url: ch.URL}, nil
+}
+
+func (n webhookNotifier) Notify(ev hookEvent) error {
+	return postJSON(n.url, ev)
+}
+
+type desktopNotifier struct{}
+
+func (desktopNotifier) Notify(ev hookEvent) error {
+	title, body := eventMessage(ev)
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		script := `Add-Type -AssemblyName System.Windows.Forms;` +
+			`$n = New-Object System.Windows.Forms.NotifyIcon;` +
+			`$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true;` +
+			`$n.ShowBalloonTip(10000, $env:MARKET_TITLE, $env:MARKET_BODY, 'Info'); Start-Sleep -Seconds 10; $n.Dispose()`
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", script)
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", `display notification (system attribute "MARKET_BODY") with title (system attribute "MARKET_TITLE")`)
+	default:
+		cmd = exec.Command("notify-send", title, body)
+	}
+	cmd.Env = append(cmd.Environ(), "MARKET_TITLE="+title, "MARKET_BODY="+body)
+	return cmd.Run()
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const ocrChat int64 = -1
+
+type importRecord struct {
+	Hash   string    `json:"hash"`
+	Time   time.Time `json:"time"`
+	Result string    `json:"result"`
+}
+
+type ocrInput struct {
+	name    string
+	data    []byte
+	modTime time.Time
+}
+
+func cmdOCR(args []string) error {
+	fs := newFlagSet("ocr")
+	at := fs.String("time", "", "время продаж на скриншотах (2006-01-02T15:04); по умолчанию — время изменения файла")
+	dryRun := fs.Bool("dry-run", false, "только показать распознанные сообщения, ничего не сохранять")
+	importID := fs.String("import-id", "", "ID загрузки: повтор с тем же ID и теми же файлами ничего не добавит и вернёт прежний итог")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Использование: market ocr [флаги] СКРИНШОТ.png|ТЕКСТ.txt|- ...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("не указаны файлы")
+	}
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	var fixed time.Time
+	if *at != "" {
+		if fixed, err = parseAsOf(*at); err != nil {
+			return err
+		}
+	}
+
+	inputs := make([]ocrInput, fs.NArg())
+	for i, name := range fs.Args() {
+		if inputs[i], err = readOCRInput(name); err != nil {
+			return err
+		}
+	}
+	hash := ocrInputsHash(inputs, *at)
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	if rec, ok := st.Imports[*importID]; ok && *importID != "" {
+		if rec.Hash != hash {
+			return fmt.Errorf("ID загрузки %q уже использован %s для других данных", *importID, rec.Time.Format("2006-01-02 15:04"))
+		}
+		fmt.Fprintf(out, "Загрузка %q уже выполнена %s, повтор ничего не добавил.\n", *importID, rec.Time.Format("2006-01-02 15:04"))
+		fmt.Fprintln(out, rec.Result)
+		return nil
+	}
+
+	known, err := loadLiveMessages()
+	if err != nil {
+		return err
+	}
+	seen := make(map[int64]bool)
+	for _, m := range known {
+		if m.Chat == ocrChat {
+			seen[m.ID] = true
+		}
+	}
+	parsers := cfg.parsers()
+	triggers, labels := messageMarkers(cfg.messageFormats())
+	var msgs []liveMessage
+	skipped, unknown := 0, 0
+	for _, in := range inputs {
+		text, err := ocrText(cfg, in)
+		if err != nil {
+			return err
+		}
+		t := fixed
+		if t.IsZero() {
+			t = in.modTime
+		}
+		for i, part := range splitMessages(text, triggers, labels) {
+			if !parsers.match(part) {
+				unknown++
+				continue
+			}
+			m := liveMessage{ID: ocrMessageID(t, i, part), Chat: ocrChat, Time: t, Text: string(part)}
+			if seen[m.ID] {
+				skipped++
+				continue
+			}
+			seen[m.ID] = true
+			msgs = append(msgs, m)
+		}
+	}
+
+	printLiveMessages(msgs, cfg)
+	result := fmt.Sprintf("Распознано сообщений о сделках: %d, уже импортированы раньше: %d, без сделки: %d", len(msgs), skipped, unknown)
+	if !*dryRun {
+		if err := appendLiveMessages(msgs); err != nil {
+			return fmt.Errorf("не удалось записать %s: %w", liveMessagesFile, err)
+		}
+		if *importID != "" {
+			if st.Imports == nil {
+				st.Imports = make(map[string]importRecord)
+			}
+			st.Imports[*importID] = importRecord{Hash: hash, Time: time.Now(), Result: result}
+			if err := st.save(); err != nil {
+				return fmt.Errorf("не удалось сохранить %s: %w", stateFile, err)
+			}
+		}
+		if len(msgs) > 0 {
+			notifyGoals(cfg)
+		}
+	}
+	fmt.Fprintln(out, result)
+	return nil
+}
+
+func readOCRInput(name string) (ocrInput, error) {
+	if name == "-" {
+		data, err := io.ReadAll(stdin)
+		return ocrInput{name: name, data: data, modTime: time.Now()}, err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return ocrInput{}, err
+	}
+	data, err := os.ReadFile(name)
+	return ocrInput{name: name, data: data, modTime: info.ModTime()}, err
+}
+
+func ocrInputsHash(inputs []ocrInput, at string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|", at)
+	for _, in := range inputs {
+		fmt.Fprintf(h, "%d|", len(in.data))
+		h.Write(in.data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func ocrText(cfg *Config, in ocrInput) ([]byte, error) {
+	name := in.name
+	if name == "-" || strings.EqualFold(filepath.Ext(name), ".txt") {
+		return in.data, nil
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case cfg.OCRCommand == "":
+		cmd = exec.Command("tesseract", name, "stdout", "-l", "rus+eng")
+	case runtime.GOOS == "windows":
+		cmd = exec.Command("cmd", "/C", cfg.OCRCommand)
+	default:
+		cmd = exec.Command("sh", "-c", cfg.OCRCommand)
+	}
+	cmd.Env = append(os.Environ(), "MARKET_FILE="+name)
+	cmd.Stderr = os.Stderr
+	text, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось распознать %s: %w", name, err)
+	}
+	return text, nil
+}
+
+func messageMarkers(formats []*tradeFormat) (triggers, labels [][]byte) {
+	for _, tf := range formats {
+		triggers = append(triggers, tf.saleTrigger, tf.purchaseTrigger, tf.tradeOutTrigger, tf.tradeInTrigger, tf.listingTrigger, tf.expiredTrigger)
+		labels = append(labels, tf.labels...)
+	}
+	return triggers, labels
+}
+
+func splitMessages(text []byte, triggers, labels [][]byte) [][]byte {
+	var starts []int
+	for i := 0; i < len(text); i++ {
+		for _, t := range triggers {
+			if len(t) > 0 && bytes.HasPrefix(text[i:], t) {
+				starts = append(starts, i)
+				i += len(t) - 1
+				break
+			}
+		}
+	}
+	parts := make([][]byte, len(starts))
+	for i, s := range starts {
+		end := len(text)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		parts[i] = trimMessageTail(bytes.TrimSpace(text[s:end]), labels)
+	}
+	return parts
+}
+
+func trimMessageTail(msg []byte, labels [][]byte) []byte {
+	lines := bytes.Split(msg, []byte("\n"))
+	keep := 1
+	for i := 1; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
+		for _, l := range labels {
+			if bytes.HasPrefix(line, l) {
+				keep = i + 1
+				break
+			}
+		}
+	}
+	return bytes.TrimSpace(bytes.Join(lines[:keep], []byte("\n")))
+}
+
+func ocrMessageID(t time.Time, index int, text []byte) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%d|", t.Unix(), index)
+	h.Write(bytes.Join(bytes.Fields(text), []byte(" ")))
+	return int64(h.Sum64() & math.MaxInt64)
+}
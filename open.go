@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+func exportsFromArg(ctx context.Context, path string, max int) ([]exportInfo, error) {
+	info, err := guardIO(ctx, path, func() (os.FileInfo, error) { return os.Stat(path) })
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, withKind(ErrExportNotFound, fmt.Errorf("не удалось открыть %s: %w", path, err))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть %s: %w", path, err)
+	}
+	if !info.IsDir() && !isZipExport(path) {
+		path = filepath.Dir(path)
+	}
+	m := exportRe.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return listExports(ctx, path, max)
+	}
+	d, _ := time.Parse("2006-01-02", m[1])
+	v := 0
+	if m[2] != "" {
+		v, _ = strconv.Atoi(m[2])
+	}
+	return []exportInfo{{Path: path, Date: d, Variant: v}}, nil
+}
+
+func enterPortableDir() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return os.Chdir(filepath.Dir(exe))
+}
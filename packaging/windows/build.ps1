@@ -0,0 +1,61 @@
+# Сборка Windows-пакетов market_helper:
+#   dist\market_helper-<версия>-windows-amd64.zip — портативный архив;
+#   dist\market_helper-<версия>-setup.exe          — установщик (если найден Inno Setup);
+#   dist\market_helper.json                        — манифест scoop с хэшем архива.
+#
+#   powershell -ExecutionPolicy Bypass -File packaging\windows\build.ps1 -Version 1.2.3 `
+#       -ReleaseUrl https://github.com/gxdlxss/market_helper/releases/download/v1.2.3
+
+param(
+    [Parameter(Mandatory = $true)][string]$Version,
+    [string]$ReleaseUrl = ""
+)
+$ErrorActionPreference = "Stop"
+
+$root = Resolve-Path (Join-Path $PSScriptRoot "..\..")
+$dist = Join-Path $root "dist"
+$stage = Join-Path $dist "stage"
+Remove-Item -Recurse -Force $stage -ErrorAction SilentlyContinue
+New-Item -ItemType Directory -Force $stage | Out-Null
+
+Push-Location $root
+try {
+    $env:CGO_ENABLED = "0"
+    $env:GOOS = "windows"
+    $env:GOARCH = "amd64"
+    go build -trimpath -ldflags "-s -w" -o (Join-Path $dist "market.exe") .
+    if ($LASTEXITCODE -ne 0) { throw "go build завершился с ошибкой" }
+} finally {
+    Pop-Location
+}
+
+Copy-Item (Join-Path $dist "market.exe") $stage
+Copy-Item (Join-Path $root "README.md") $stage
+Copy-Item (Join-Path $PSScriptRoot "context-menu-install.cmd") $stage
+Copy-Item (Join-Path $PSScriptRoot "context-menu-uninstall.cmd") $stage
+
+$zipName = "market_helper-$Version-windows-amd64.zip"
+$zip = Join-Path $dist $zipName
+Remove-Item -Force $zip -ErrorAction SilentlyContinue
+Compress-Archive -Path (Join-Path $stage "*") -DestinationPath $zip
+Remove-Item -Recurse -Force $stage
+Write-Host "Архив: $zip"
+
+$iscc = Get-Command ISCC.exe -ErrorAction SilentlyContinue
+if (-not $iscc) {
+    $default = Join-Path ${env:ProgramFiles(x86)} "Inno Setup 6\ISCC.exe"
+    if (Test-Path $default) { $iscc = Get-Item $default }
+}
+if ($iscc) {
+    & $iscc.Source "/DAppVersion=$Version" "/DDistDir=$dist" (Join-Path $PSScriptRoot "market.iss")
+    if ($LASTEXITCODE -ne 0) { throw "ISCC завершился с ошибкой" }
+} else {
+    Write-Warning "Inno Setup не найден — установщик не собран, только портативный архив."
+}
+
+$hash = (Get-FileHash -Algorithm SHA256 $zip).Hash.ToLower()
+$url = if ($ReleaseUrl) { "$($ReleaseUrl.TrimEnd('/'))/$zipName" } else { $zipName }
+(Get-Content -Raw -Encoding UTF8 (Join-Path $PSScriptRoot "market_helper.json.in")).
+    Replace("@VERSION@", $Version).Replace("@URL@", $url).Replace("@HASH@", $hash) |
+    Set-Content -Encoding UTF8 (Join-Path $dist "market_helper.json")
+Write-Host "Манифест scoop: $(Join-Path $dist 'market_helper.json') (sha256 $hash)"
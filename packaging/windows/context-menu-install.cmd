@@ -0,0 +1,16 @@
+@echo off
+chcp 65001 >nul
+rem Регистрирует пункт «Анализировать в market_helper» для папок ChatExport_*
+rem в контекстном меню Проводника текущего пользователя (для портативного zip).
+setlocal
+set "EXE=%~dp0market.exe"
+if not exist "%EXE%" (
+  echo Не найден %EXE%
+  exit /b 1
+)
+set "KEY=HKCU\Software\Classes\Directory\shell\market_helper"
+reg add "%KEY%" /ve /d "Анализировать в market_helper" /f >nul || exit /b 1
+reg add "%KEY%" /v Icon /d "\"%EXE%\"" /f >nul
+reg add "%KEY%" /v AppliesTo /d "System.ItemNameDisplay:~<\"ChatExport_\"" /f >nul
+reg add "%KEY%\command" /ve /d "\"%EXE%\" --portable \"%%1\"" /f >nul || exit /b 1
+echo Пункт контекстного меню добавлен.
@@ -0,0 +1,5 @@
+@echo off
+chcp 65001 >nul
+rem Удаляет пункт «Анализировать в market_helper» из контекстного меню папок.
+reg delete "HKCU\Software\Classes\Directory\shell\market_helper" /f >nul 2>&1
+echo Пункт контекстного меню удалён.
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+var textBufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+type parseAnomaly struct {
+	Time   time.Time
+	Text   string
+	Reason string
+}
+
+type parseResult struct {
+	Sales     []Sale
+	Purchases []Purchase
+	Trades    []Trade
+	Listings  []Listing
+	Expired   []Listing
+	Anomalies []parseAnomaly
+	Unparsed  []parseAnomaly
+	Undated   []parseAnomaly
+	Layout    string
+	ChatName  string
+	Warnings  []string
+}
+
+func parseExport(ctx context.Context, dir string, cfg *Config) (*parseResult, error) {
+	key := parserFingerprint(cfg) + "\x00" + dir
+	if res := warmExports.get(key); res != nil {
+		return res, nil
+	}
+	var sales []Sale
+	res, err := parseExportFunc(ctx, dir, cfg, func(s Sale) error {
+		sales = append(sales, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.Sales = sales
+	warmExports.put(key, res)
+	return res, nil
+}
+
+// parsedExports keeps parse results in memory between daemon requests. It is
+// nil outside the daemon, and the daemon's watcher resets it when an export
+// changes.
+type parsedExports struct {
+	mu      sync.Mutex
+	results map[string]*parseResult
+}
+
+var warmExports *parsedExports
+
+func newParsedExports() *parsedExports {
+	return &parsedExports{results: make(map[string]*parseResult)}
+}
+
+func (p *parsedExports) get(key string) *parseResult {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if res := p.results[key]; res != nil {
+		return res.clone()
+	}
+	return nil
+}
+
+func (p *parsedExports) put(key string, res *parseResult) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.results[key] = res.clone()
+	p.mu.Unlock()
+}
+
+func (p *parsedExports) reset() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	clear(p.results)
+	p.mu.Unlock()
+}
+
+// clone copies the slices, so callers may append to or edit the result
+// without touching the cached one.
+func (res *parseResult) clone() *parseResult {
+	c := *res
+	c.Sales = slices.Clone(res.Sales)
+	c.Purchases = slices.Clone(res.Purchases)
+	c.Trades = slices.Clone(res.Trades)
+	c.Listings = slices.Clone(res.Listings)
+	c.Expired = slices.Clone(res.Expired)
+	c.Anomalies = slices.Clone(res.Anomalies)
+	c.Unparsed = slices.Clone(res.Unparsed)
+	c.Undated = slices.Clone(res.Undated)
+	c.Warnings = slices.Clone(res.Warnings)
+	return &c
+}
+
+var pageRe = regexp.MustCompile(`^messages(\d*)\.html$`)
+
+func exportPages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать %s: %w", dir, err)
+	}
+	type page struct {
+		name string
+		num  int
+	}
+	var pages []page
+	for _, e := range entries {
+		m := pageRe.FindStringSubmatch(e.Name())
+		if m == nil || e.IsDir() {
+			continue
+		}
+		num := 1
+		if m[1] != "" {
+			num, _ = strconv.Atoi(m[1])
+		}
+		pages = append(pages, page{e.Name(), num})
+	}
+	if len(pages) == 0 {
+		return nil, withKind(ErrExportNotFound, fmt.Errorf("не удалось открыть %s: %w", filepath.Join(dir, "messages.html"), os.ErrNotExist))
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].num < pages[j].num })
+	paths := make([]string, len(pages))
+	for i, p := range pages {
+		paths[i] = filepath.Join(dir, p.name)
+	}
+	return paths, nil
+}
+
+type exportSources struct {
+	sources []pageSource
+	zip     *zip.ReadCloser
+}
+
+func findExportSources(dir string) (exportSources, error) {
+	var found exportSources
+	if isZipExport(dir) {
+		zr, err := zip.OpenReader(dir)
+		if err != nil {
+			return found, fmt.Errorf("не удалось открыть архив %s: %w", dir, err)
+		}
+		found.zip = zr
+		if found.sources, err = zipExportSources(dir, &zr.Reader); err != nil {
+			zr.Close()
+			return found, err
+		}
+	} else if jsonPath := filepath.Join(dir, jsonExportFile); fileExists(jsonPath) {
+		found.sources = []pageSource{fileSource(jsonPath)}
+	} else {
+		pages, err := exportPages(dir)
+		if err != nil {
+			return found, err
+		}
+		for _, page := range pages {
+			found.sources = append(found.sources, fileSource(page))
+		}
+	}
+	return found, nil
+}
+
+func parseExportFunc(ctx context.Context, dir string, cfg *Config, emit func(Sale) error) (*parseResult, error) {
+	found, err := guardIO(ctx, dir, func() (exportSources, error) { return findExportSources(dir) })
+	if err != nil {
+		return nil, err
+	}
+	if found.zip != nil {
+		defer found.zip.Close()
+	}
+	sources := found.sources
+
+	pages := make([]*cachedPage, len(sources))
+	err = forEachParallel(len(sources), cfg.parseWorkers(), func(i int) error {
+		parse := parsePage
+		if filepath.Base(sources[i].name) == jsonExportFile {
+			parse = parseJSONExport
+		}
+		page, err := loadPage(ctx, sources[i], cfg, parse)
+		pages[i] = page
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	saveParseCache()
+
+	res := &parseResult{}
+	for _, page := range pages {
+		if err := res.addPage(page, cfg, emit); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+type pageElement struct {
+	tag  string
+	kind elementKind
+}
+
+type elementKind uint8
+
+const (
+	kindOther elementKind = iota
+	kindMessage
+	kindText
+	kindHeader
+	kindChatName
+)
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true,
+	"img": true, "input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+type pageMessage struct {
+	id    int64
+	dates []string
+	found []bool
+}
+
+// date returns v as a string, reusing the copy made for another layout that
+// read the same attribute.
+func (m *pageMessage) date(v []byte) string {
+	for i, d := range m.dates {
+		if m.found[i] && d == string(v) {
+			return d
+		}
+	}
+	return string(v)
+}
+
+type pageAttr struct {
+	key, val []byte
+}
+
+// pageStrings interns tag names and class attributes, which repeat on every
+// message of a page, so the tokenizer's byte slices are copied only once.
+type pageStrings map[string]string
+
+func (ps pageStrings) intern(b []byte) string {
+	if s, ok := ps[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	ps[s] = s
+	return s
+}
+
+func parsePage(r io.Reader, filePath string, cfg *Config, res *parseResult, emit func(Sale) error) error {
+	z := html.NewTokenizer(bufio.NewReaderSize(r, 64<<10))
+	buf := textBufPool.Get().(*bytes.Buffer)
+	defer textBufPool.Put(buf)
+	var chatName strings.Builder
+
+	var (
+		stack     []pageElement
+		attrs     []pageAttr
+		strs      = make(pageStrings)
+		msg       *pageMessage
+		textDepth int
+		inHeader  bool
+		nameDepth int
+		messages  int
+		parsers   = cfg.parsers()
+		layouts   = cfg.trades().layouts
+		scores    = make([]int, len(layouts))
+		msgSel    = parseSelector(layouts[0].message)
+		textSel   = parseSelector(layouts[0].text)
+		dateSels  = make([]simpleSelector, len(layouts))
+	)
+	for i, l := range layouts {
+		dateSels[i] = parseSelector(l.date)
+	}
+	cur := pageMessage{dates: make([]string, len(layouts)), found: make([]bool, len(layouts))}
+
+	finishMessage := func() error {
+		m := msg
+		msg = nil
+		var msgTime time.Time
+		var rawDate string
+		parsed := false
+		for i, l := range layouts {
+			if !m.found[i] {
+				continue
+			}
+			if rawDate == "" {
+				rawDate = m.dates[i]
+			}
+			if t, ok := l.parseTime(m.dates[i]); ok {
+				scores[i]++
+				if !parsed {
+					msgTime, parsed = t, true
+				}
+			}
+		}
+		text := buf.Bytes()
+		if !parsers.match(text) {
+			return nil
+		}
+		if !parsed {
+			res.Undated = append(res.Undated, parseAnomaly{Text: string(text), Reason: rawDate})
+			return nil
+		}
+		return res.addTrade(text, msgTime, m.id, cfg, emit)
+	}
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return fmt.Errorf("ошибка разбора HTML %s: %w", filePath, err)
+			}
+			if res.ChatName == "" {
+				res.ChatName = strings.TrimSpace(chatName.String())
+			}
+			best := 0
+			for i := range scores {
+				if scores[i] > scores[best] {
+					best = i
+				}
+			}
+			if res.Layout == "" {
+				res.Layout = layouts[best].name
+			}
+			if scores[best] == 0 && messages > 0 {
+				res.Warnings = append(res.Warnings, fmt.Sprintf("%s: неизвестная структура экспорта — даты сообщений не распознаны, возможно, изменился формат Telegram", filePath))
+			}
+			return nil
+
+		case html.TextToken:
+			if msg != nil && textDepth > 0 {
+				buf.Write(z.Text())
+			} else if nameDepth > 0 {
+				chatName.Write(z.Text())
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			tag := strs.intern(name)
+			attrs = attrs[:0]
+			for hasAttr {
+				var a pageAttr
+				a.key, a.val, hasAttr = z.TagAttr()
+				attrs = append(attrs, a)
+			}
+			class := strs.intern(attrValue(attrs, "class"))
+			kind := kindOther
+			switch {
+			case msg == nil && msgSel.matches(tag, class):
+				kind = kindMessage
+				messages++
+				msg = &cur
+				clear(msg.dates)
+				clear(msg.found)
+				msg.id, _ = strconv.ParseInt(string(bytes.TrimPrefix(attrValue(attrs, "id"), []byte("message"))), 10, 64)
+				buf.Reset()
+			case msg != nil && textSel.matches(tag, class):
+				kind = kindText
+				textDepth++
+			case msg == nil && tag == "div" && hasClass(class, "page_header"):
+				kind = kindHeader
+				inHeader = true
+			case inHeader && tag == "div" && hasClass(class, "text") && hasClass(class, "bold"):
+				kind = kindChatName
+				nameDepth++
+			}
+			if msg != nil {
+				for i, sel := range dateSels {
+					if !msg.found[i] && sel.matches(tag, class) {
+						for _, attr := range layouts[i].dateAttrs {
+							if v := attrValue(attrs, attr); len(v) > 0 {
+								msg.dates[i], msg.found[i] = msg.date(v), true
+								break
+							}
+						}
+					}
+				}
+			}
+			if tt == html.StartTagToken && !voidElements[tag] {
+				stack = append(stack, pageElement{tag: tag, kind: kind})
+			} else if kind == kindMessage {
+				if err := finishMessage(); err != nil {
+					return err
+				}
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			i := len(stack) - 1
+			for i >= 0 && stack[i].tag != string(name) {
+				i--
+			}
+			if i < 0 {
+				continue
+			}
+			for j := len(stack) - 1; j >= i; j-- {
+				switch stack[j].kind {
+				case kindMessage:
+					textDepth = 0
+					if err := finishMessage(); err != nil {
+						return err
+					}
+				case kindText:
+					textDepth--
+				case kindHeader:
+					inHeader = false
+				case kindChatName:
+					nameDepth--
+				}
+			}
+			stack = stack[:i]
+		}
+	}
+}
+
+func attrValue(attrs []pageAttr, key string) []byte {
+	for _, a := range attrs {
+		if string(a.key) == key {
+			return a.val
+		}
+	}
+	return nil
+}
+
+func parseAmount(raw []byte) (Money, error) {
+	var digits [32]byte
+	b := digits[:0]
+	for _, c := range raw {
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == ',':
+			b = append(b, '.')
+		default:
+			b = append(b, c)
+		}
+	}
+	return parseMoney(string(b))
+}
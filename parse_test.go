@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func benchmarkPage(messages int) []byte {
+	servers := []string{"Atlanta", "Detroit"}
+	chars := []string{"Icy Godless #288032", "Godless Satanic #268065", "Тихая Гавань #118734"}
+	items := []string{"Адреналин", "HK MP5‑SD", "Улучшенный эпинефрин", "Фиолетовая карточка"}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.FixedZone("", 3*3600))
+
+	var b bytes.Buffer
+	b.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8"/><title>Exported Data</title></head><body><div class="page_wrap">`)
+	b.WriteString(`<div class="page_header"><div class="content"><div class="text bold">Majestic Market</div></div></div><div class="page_body chat_page"><div class="history">`)
+	for i := range messages {
+		t := start.Add(time.Duration(i) * time.Minute)
+		fmt.Fprintf(&b, "<div class=\"message default clearfix\" id=\"message%d\">\n<div class=\"body\">\n", i+1)
+		fmt.Fprintf(&b, "<div class=\"pull_right date details\" title=\"%s UTC+03:00\">%s</div>\n", t.Format("02.01.2006 15:04:05"), t.Format("15:04"))
+		b.WriteString("<div class=\"from_name\">Market Bot</div>\n")
+		if i%10 == 9 {
+			b.WriteString("<div class=\"text\">Спасибо, что пользуетесь рынком!<br>Новые лоты появятся после рестарта.</div>\n")
+		} else {
+			fmt.Fprintf(&b, "<div class=\"text\">Вы успешно продали предмет!<br><br>Сервер: %s<br>Персонаж: %s<br>Название: %s<br>Кол-во: %d<br>Цена продажи: $%d 000</div>\n",
+				servers[i%len(servers)], chars[i%len(chars)], items[i%len(items)], i%5+1, i%900+5)
+		}
+		b.WriteString("</div></div>\n")
+	}
+	b.WriteString("</div></div></div></body></html>\n")
+	return b.Bytes()
+}
+
+func BenchmarkParsePage(b *testing.B) {
+	const messages = 100_000
+	cfg := &Config{BaseDir: "bench", Selected: []string{"Адреналин"}}
+	if _, err := validateConfig(cfg); err != nil {
+		b.Fatal(err)
+	}
+	page := benchmarkPage(messages)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for b.Loop() {
+		sales := 0
+		res := &parseResult{}
+		err := parsePage(bytes.NewReader(page), "messages.html", cfg, res, func(Sale) error {
+			sales++
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if want := messages - messages/10; sales != want {
+			b.Fatalf("parsed %d sales, want %d", sales, want)
+		}
+	}
+}
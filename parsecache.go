@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	parseCacheVersion = 8
+	parseCacheFile    = "parse_cache.gob"
+	pageMemoSize      = 256
+)
+
+type parseCache struct {
+	Fingerprint string
+	Files       map[string]*cachedPage
+
+	dirty bool
+}
+
+type cachedPage struct {
+	Size      int64
+	ModTime   time.Time
+	Hash      string
+	Sales     []Sale
+	Purchases []Purchase
+	Trades    []Trade
+	Listings  []Listing
+	Expired   []Listing
+	Anomalies []parseAnomaly
+	Unparsed  []parseAnomaly
+	Undated   []parseAnomaly
+	ChatName  string
+	Layout    string
+	Warnings  []string
+}
+
+var (
+	parseCacheMu     sync.Mutex
+	loadedParseCache *parseCache
+	pageMemo         = newLRU[string, *cachedPage](pageMemoSize)
+)
+
+func parserFingerprint(cfg *Config) string {
+	data, _ := json.Marshal(struct {
+		Aliases  map[string]string
+		Suffixes []string
+		Limits   Limits
+		Profile  *Profile
+		Parsers  []string
+		Formats  []string
+		Version  int
+		Timezone string
+	}{cfg.itemAliases, cfg.QualitySuffixes, cfg.limits(), cfg.Profile, parserNames(), formatNames(cfg.messageFormats()), parseCacheVersion, cfg.timezone()})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func openParseCache(cfg *Config) *parseCache {
+	fp := parserFingerprint(cfg)
+	if c := loadedParseCache; c != nil && c.Fingerprint == fp {
+		return c
+	}
+	c := &parseCache{Fingerprint: fp, Files: make(map[string]*cachedPage)}
+	loadedParseCache = c
+	data, err := storage.Get(parseCacheFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return c
+	}
+	if err == nil {
+		data, err = decodeStore(data)
+	}
+	var stored parseCache
+	if err != nil || gob.NewDecoder(bytes.NewReader(data)).Decode(&stored) != nil || stored.Fingerprint != fp {
+		c.dirty = true
+		return c
+	}
+	if stored.Files != nil {
+		c.Files = stored.Files
+	}
+	return c
+}
+
+func (c *parseCache) save() error {
+	if !c.dirty {
+		return nil
+	}
+	for key := range c.Files {
+		path, _, _ := strings.Cut(key, zipEntrySep)
+		if !fileExists(path) {
+			delete(c.Files, key)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(c); err != nil {
+		return err
+	}
+	if err := storage.Put(parseCacheFile, compressStore(buf.Bytes())); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+func hashFile(ctx context.Context, path string) (string, error) {
+	f, err := guardIO(ctx, path, func() (*os.File, error) { return os.Open(path) })
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, newCtxReader(ctx, f, path)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+type pageSource struct {
+	key     string
+	name    string
+	size    int64
+	modTime time.Time
+	hash    func(ctx context.Context) (string, error)
+	open    func() (io.ReadCloser, error)
+}
+
+func fileSource(path string) pageSource {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		key = path
+	}
+	src := pageSource{
+		key:  key,
+		name: path,
+		hash: func(ctx context.Context) (string, error) { return hashFile(ctx, path) },
+		open: func() (io.ReadCloser, error) { return os.Open(path) },
+	}
+	if info, err := os.Stat(path); err == nil {
+		src.size, src.modTime = info.Size(), info.ModTime()
+	}
+	return src
+}
+
+func (c *parseCache) lookup(ctx context.Context, src pageSource) (*cachedPage, string, error) {
+	parseCacheMu.Lock()
+	page := c.Files[src.key]
+	parseCacheMu.Unlock()
+	if page != nil && page.Size == src.size && page.ModTime.Equal(src.modTime) {
+		return page, page.Hash, nil
+	}
+	hash, err := src.hash(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("не удалось прочитать %s: %w", src.name, err)
+	}
+	if page != nil && page.Hash == hash {
+		parseCacheMu.Lock()
+		page.Size, page.ModTime = src.size, src.modTime
+		c.dirty = true
+		parseCacheMu.Unlock()
+		return page, hash, nil
+	}
+	return nil, hash, nil
+}
+
+func loadPage(ctx context.Context, src pageSource, cfg *Config, parse func(io.Reader, string, *Config, *parseResult, func(Sale) error) error) (*cachedPage, error) {
+	parseCacheMu.Lock()
+	c := openParseCache(cfg)
+	parseCacheMu.Unlock()
+	page, hash, err := c.lookup(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	memoKey := c.Fingerprint + ":" + hash
+	parseCacheMu.Lock()
+	if page != nil {
+		pageMemo.Put(memoKey, page)
+	} else if memo, ok := pageMemo.Get(memoKey); ok {
+		copied := *memo
+		copied.Size, copied.ModTime = src.size, src.modTime
+		page = &copied
+		c.Files[src.key] = page
+		c.dirty = true
+	}
+	parseCacheMu.Unlock()
+	if page != nil {
+		return page, nil
+	}
+
+	r, err := guardIO(ctx, src.name, src.open)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть %s: %w", src.name, err)
+	}
+	defer r.Close()
+	release := acquireParseSlot(cfg)
+	part := &parseResult{}
+	var sales []Sale
+	err = parse(newCtxReader(ctx, r, src.name), src.name, cfg, part, func(s Sale) error {
+		sales = append(sales, s)
+		return nil
+	})
+	release()
+	if err != nil {
+		return nil, err
+	}
+	page = &cachedPage{
+		Size: src.size, ModTime: src.modTime, Hash: hash,
+		Sales: sales, Purchases: part.Purchases, Trades: part.Trades, Listings: part.Listings, Expired: part.Expired, Anomalies: part.Anomalies, Unparsed: part.Unparsed, Undated: part.Undated,
+		ChatName: part.ChatName, Layout: part.Layout, Warnings: part.Warnings,
+	}
+	parseCacheMu.Lock()
+	c.Files[src.key] = page
+	c.dirty = true
+	pageMemo.Put(memoKey, page)
+	parseCacheMu.Unlock()
+	return page, nil
+}
+
+func (res *parseResult) addPage(page *cachedPage, cfg *Config, emit func(Sale) error) error {
+	if res.ChatName == "" {
+		res.ChatName = page.ChatName
+	}
+	if res.Layout == "" {
+		res.Layout = page.Layout
+	}
+	for _, p := range page.Purchases {
+		p.Item = cfg.canonicalItem(p.Item)
+		res.Purchases = append(res.Purchases, p)
+	}
+	for _, t := range page.Trades {
+		t.Item = cfg.canonicalItem(t.Item)
+		res.Trades = append(res.Trades, t)
+	}
+	for _, l := range page.Listings {
+		l.Item = cfg.canonicalItem(l.Item)
+		res.Listings = append(res.Listings, l)
+	}
+	for _, l := range page.Expired {
+		l.Item = cfg.canonicalItem(l.Item)
+		res.Expired = append(res.Expired, l)
+	}
+	res.Anomalies = append(res.Anomalies, page.Anomalies...)
+	res.Unparsed = append(res.Unparsed, page.Unparsed...)
+	res.Undated = append(res.Undated, page.Undated...)
+	res.Warnings = append(res.Warnings, page.Warnings...)
+	for _, s := range page.Sales {
+		s.Item = cfg.canonicalItem(s.Item)
+		if err := emit(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func saveParseCache() {
+	parseCacheMu.Lock()
+	defer parseCacheMu.Unlock()
+	if loadedParseCache == nil {
+		return
+	}
+	_ = loadedParseCache.save()
+}
+
+type parseCachePurge struct{}
+
+func (parseCachePurge) Name() string { return parseCacheFile }
+
+// Purge deletes the whole cache when it holds matching records: pages are
+// cached as parsed, and the next run parses the exports again.
+func (parseCachePurge) Purge(f purgeFilter, dryRun bool) (int, error) {
+	data, err := storage.Get(parseCacheFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err == nil {
+		data, err = decodeStore(data)
+	}
+	if err != nil {
+		return 0, err
+	}
+	var stored parseCache
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil {
+		return 0, fmt.Errorf("%s повреждён: %w", parseCacheFile, err)
+	}
+	n := 0
+	match := func(character string, t time.Time) {
+		if _, id := splitCharacter(character); f.matches(id, t) {
+			n++
+		}
+	}
+	for _, page := range stored.Files {
+		for _, s := range page.Sales {
+			match(s.Character, s.Time)
+		}
+		for _, p := range page.Purchases {
+			match(p.Character, p.Time)
+		}
+		for _, t := range page.Trades {
+			match(t.Character, t.Time)
+		}
+		for _, l := range page.Listings {
+			match(l.Character, l.Time)
+		}
+		for _, l := range page.Expired {
+			match(l.Character, l.Time)
+		}
+	}
+	if dryRun || n == 0 {
+		return n, nil
+	}
+	parseCacheMu.Lock()
+	loadedParseCache = nil
+	parseCacheMu.Unlock()
+	warmExports.reset()
+	return n, storage.Delete(parseCacheFile)
+}
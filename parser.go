@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+type Parser interface {
+	Match(text []byte) bool
+	Parse(text []byte, t time.Time) (Sale, error)
+}
+
+var errNotTrade = errors.New("сообщение не соответствует формату")
+
+type parserKind struct {
+	name string
+	new  func(cfg *Config) Parser
+	add  func(res *parseResult, s Sale, emit func(Sale) error) error
+}
+
+var parserRegistry = []parserKind{
+	{"purchase", perLanguage(newPurchaseParser), addPurchase},
+	{"sale", perLanguage(newSaleParser), addSale},
+	{"trade_out", perLanguage(newTradeParser(func(tf *tradeFormat) []byte { return tf.tradeOutTrigger })), addTradeOut},
+	{"trade_in", perLanguage(newTradeParser(func(tf *tradeFormat) []byte { return tf.tradeInTrigger })), addTradeIn},
+	{"listing", perLanguage(newListingParser), addListing},
+	{"expired", perLanguage(newExpiredParser), addExpired},
+}
+
+func parserNames() []string {
+	names := make([]string, len(parserRegistry))
+	for i, k := range parserRegistry {
+		names[i] = k.name
+	}
+	return names
+}
+
+type boundParser struct {
+	Parser
+	kind string
+	add  func(res *parseResult, s Sale, emit func(Sale) error) error
+}
+
+type parserSet []boundParser
+
+func buildParsers(cfg *Config) parserSet {
+	ps := make(parserSet, len(parserRegistry))
+	for i, k := range parserRegistry {
+		ps[i] = boundParser{Parser: k.new(cfg), kind: k.name, add: k.add}
+	}
+	return ps
+}
+
+func (cfg *Config) parsers() parserSet {
+	if cfg.messageParsers != nil {
+		return cfg.messageParsers
+	}
+	return buildParsers(cfg)
+}
+
+func (ps parserSet) match(text []byte) bool {
+	for _, p := range ps {
+		if p.Match(text) {
+			return true
+		}
+	}
+	return false
+}
+
+type languageParsers []Parser
+
+func perLanguage(build func(cfg *Config, tf *tradeFormat) Parser) func(cfg *Config) Parser {
+	return func(cfg *Config) Parser {
+		formats := cfg.messageFormats()
+		ps := make(languageParsers, len(formats))
+		for i, tf := range formats {
+			ps[i] = build(cfg, tf)
+		}
+		return ps
+	}
+}
+
+func (ps languageParsers) Match(text []byte) bool {
+	for _, p := range ps {
+		if p.Match(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ps languageParsers) Parse(text []byte, t time.Time) (Sale, error) {
+	for _, p := range ps {
+		if p.Match(text) {
+			return p.Parse(text, t)
+		}
+	}
+	return Sale{}, errNotTrade
+}
+
+func (res *parseResult) addTrade(text []byte, msgTime time.Time, msgID int64, cfg *Config, emit func(Sale) error) error {
+	for _, p := range cfg.parsers() {
+		if !p.Match(text) {
+			continue
+		}
+		s, err := p.Parse(text, msgTime)
+		if errors.Is(err, errNotTrade) {
+			res.Unparsed = append(res.Unparsed, parseAnomaly{Time: msgTime, Text: string(text), Reason: p.kind})
+			return nil
+		}
+		if err != nil {
+			res.Anomalies = append(res.Anomalies, parseAnomaly{Time: msgTime, Text: string(text), Reason: err.Error()})
+			return nil
+		}
+		s.MsgID = msgID
+		return p.add(res, s, emit)
+	}
+	return nil
+}
+
+func addSale(_ *parseResult, s Sale, emit func(Sale) error) error {
+	return emit(s)
+}
+
+func addPurchase(res *parseResult, s Sale, _ func(Sale) error) error {
+	res.Purchases = append(res.Purchases, Purchase{MsgID: s.MsgID, Time: s.Time, Server: s.Server, Character: s.Character, Item: s.Item, Quantity: s.Quantity, Price: s.Price})
+	return nil
+}
+
+type tradeTextParser struct {
+	trigger       []byte
+	re            *regexp.Regexp
+	labels        [][][]byte
+	currency      []byte
+	optionalPrice bool
+	fee           *regexp.Regexp
+	buyer         *regexp.Regexp
+	cfg           *Config
+	limits        Limits
+}
+
+func newSaleParser(cfg *Config, tf *tradeFormat) Parser {
+	return &tradeTextParser{trigger: tf.saleTrigger, re: tf.saleRe, labels: tf.saleLabels, currency: tf.currency, fee: tf.feeRe, buyer: tf.buyerRe, cfg: cfg, limits: cfg.limits()}
+}
+
+func newPurchaseParser(cfg *Config, tf *tradeFormat) Parser {
+	return &tradeTextParser{trigger: tf.purchaseTrigger, re: tf.purchaseRe, labels: tf.purchaseLabels, currency: tf.currency, cfg: cfg, limits: cfg.limits()}
+}
+
+func (p *tradeTextParser) Match(text []byte) bool {
+	return bytes.Contains(text, p.trigger)
+}
+
+func (p *tradeTextParser) Parse(text []byte, t time.Time) (Sale, error) {
+	if !containsAll(text, p.labels) {
+		return Sale{}, errNotTrade
+	}
+	m := p.re.FindSubmatchIndex(text)
+	if m == nil {
+		return Sale{}, errNotTrade
+	}
+	field := func(i int) []byte {
+		if m[2*i] < 0 {
+			return nil
+		}
+		return bytes.TrimSpace(text[m[2*i]:m[2*i+1]])
+	}
+
+	qty, _ := strconv.Atoi(string(field(4)))
+	var price Money
+	checked := p.limits.MinPrice
+	if field(5) != nil || !p.optionalPrice {
+		rawPrice := bytes.TrimSpace(bytes.ReplaceAll(field(5), p.currency, nil))
+		var err error
+		if price, err = parseAmount(rawPrice); err != nil {
+			return Sale{}, withKind(ErrMalformedMessage, fmt.Errorf("не удалось разобрать цену %q", rawPrice))
+		}
+		checked = price
+	}
+	if reason := p.limits.check(checked, qty); reason != "" {
+		return Sale{}, withKind(ErrMalformedMessage, errors.New(reason))
+	}
+	var fee Money
+	if p.fee != nil {
+		if fm := p.fee.FindSubmatch(text); fm != nil {
+			rawFee := bytes.TrimSpace(bytes.ReplaceAll(fm[1], p.currency, nil))
+			var err error
+			if fee, err = parseAmount(rawFee); err != nil {
+				return Sale{}, withKind(ErrMalformedMessage, fmt.Errorf("не удалось разобрать комиссию %q", rawFee))
+			}
+		}
+	}
+	var buyer string
+	if p.buyer != nil {
+		if bm := p.buyer.FindSubmatch(text); bm != nil {
+			buyer = string(bytes.TrimSpace(bm[1]))
+		}
+	}
+
+	rawItem, quality := p.cfg.splitQuality(string(field(3)))
+	return Sale{
+		Time:         t,
+		Server:       string(field(1)),
+		Character:    string(field(2)),
+		Item:         p.cfg.aliasItem(rawItem),
+		RawItem:      rawItem,
+		Quality:      quality,
+		Quantity:     qty,
+		Price:        price,
+		Fee:          fee,
+		Counterparty: buyer,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const defaultPaydayMinutes = 60
+
+type paydayCycle struct {
+	Start      time.Time
+	Sales      int
+	Count      int
+	Revenue    Money
+	Characters map[string]bool
+}
+
+func (cfg *Config) paydayLength() time.Duration {
+	if cfg.PaydayMinutes > 0 {
+		return time.Duration(cfg.PaydayMinutes) * time.Minute
+	}
+	return defaultPaydayMinutes * time.Minute
+}
+
+func paydayCycles(sales []Sale, length time.Duration) []*paydayCycle {
+	byStart := make(map[time.Time]*paydayCycle)
+	for _, s := range sales {
+		start := s.Time.Truncate(length)
+		c := byStart[start]
+		if c == nil {
+			c = &paydayCycle{Start: start, Characters: make(map[string]bool)}
+			byStart[start] = c
+		}
+		c.Sales++
+		c.Count += s.Quantity
+		c.Revenue += s.Price
+		c.Characters[s.Server+"/"+s.Character] = true
+	}
+	cycles := make([]*paydayCycle, 0, len(byStart))
+	for _, c := range byStart {
+		cycles = append(cycles, c)
+	}
+	sort.Slice(cycles, func(i, j int) bool { return cycles[i].Start.Before(cycles[j].Start) })
+	return cycles
+}
+
+func cmdPaydays(args []string) error {
+	fs := newFlagSet("paydays")
+	last := fs.Int("last", 24, "показать N последних циклов с продажами (0 — все)")
+	server := fs.String("server", "", "учитывать только продажи на этом сервере")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	var sales []Sale
+	ctx, stop := runContext(cfg)
+	defer stop()
+	err = eachLatestSale(ctx, cfg, func(s Sale) error {
+		if *server == "" || s.Server == *server {
+			sales = append(sales, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(sales) == 0 {
+		return errors.New("в экспорте нет продаж")
+	}
+	printPaydays(paydayCycles(sales, cfg.paydayLength()), cfg, *last)
+	return nil
+}
+
+func printPaydays(cycles []*paydayCycle, cfg *Config, last int) {
+	length := cfg.paydayLength()
+	first, end := cycles[0].Start, cycles[len(cycles)-1].Start.Add(length)
+	total := int(end.Sub(first) / length)
+
+	var revenue Money
+	best := cycles[0]
+	for _, c := range cycles {
+		revenue += c.Revenue
+		if c.Revenue > best.Revenue {
+			best = c
+		}
+	}
+
+	shown := cycles
+	if last > 0 && len(shown) > last {
+		shown = shown[len(shown)-last:]
+	}
+	fmt.Fprintf(out, "\nПродажи по циклам выплат (%d мин.), последние %d с продажами:\n", int(length.Minutes()), len(shown))
+	w := newTable()
+	fmt.Fprintln(w, "Начало цикла\tПродаж\tКол-во\tВыручка\tПерсонажей")
+	for _, c := range shown {
+		fmt.Fprintf(w, "%s\t%d\t%d\t$%.2f\t%d\n", formatDateTime(c.Start, cfg.Language), c.Sales, c.Count, c.Revenue, len(c.Characters))
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "    Циклов с продажами: %d из %d (%.0f%%)\n", len(cycles), total, float64(len(cycles))/float64(total)*100)
+	fmt.Fprintf(out, "    Средняя выручка за цикл: $%.2f (с учётом пустых циклов: $%.2f)\n", revenue.Float()/float64(len(cycles)), revenue.Float()/float64(total))
+	fmt.Fprintf(out, "    Лучший цикл: %s — $%.2f\n", formatDateTime(best.Start, cfg.Language), best.Revenue)
+}
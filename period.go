@@ -0,0 +1,247 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PeriodDef struct {
+	Name     string `json:"name"`
+	Rolling  string `json:"rolling,omitempty"`
+	Calendar string `json:"calendar,omitempty"`
+	Since    string `json:"since,omitempty"`
+	Until    string `json:"until,omitempty"`
+}
+
+type period struct {
+	name     string
+	window   time.Duration
+	calendar string
+	since    time.Time
+	until    time.Time
+}
+
+var allPeriods = []period{{name: "all"}, {name: "day", window: 24 * time.Hour}, {name: "week", window: 7 * 24 * time.Hour}, {name: "month", window: 30 * 24 * time.Hour}}
+
+var calendarPeriods = []period{{name: "today", calendar: "day"}, {name: "this-week", calendar: "week"}, {name: "this-month", calendar: "month"}, {name: "this-year", calendar: "year"}}
+
+var allTime = allPeriods[0]
+
+func (p period) start(now time.Time) time.Time {
+	switch {
+	case p.window > 0:
+		return now.Add(-p.window)
+	case p.calendar != "":
+		return calendarStart(p.calendar, now)
+	}
+	return p.since
+}
+
+func (p period) contains(t, now time.Time) bool {
+	if start := p.start(now); !start.IsZero() && t.Before(start) {
+		return false
+	}
+	return p.until.IsZero() || t.Before(p.until)
+}
+
+// within reports whether q covers every moment p covers at now.
+func (p period) within(q period, now time.Time) bool {
+	ps, qs := p.start(now), q.start(now)
+	if !qs.IsZero() && (ps.IsZero() || ps.Before(qs)) {
+		return false
+	}
+	return q.until.IsZero() || (!p.until.IsZero() && !p.until.After(q.until))
+}
+
+func (p period) previous(now time.Time) (time.Time, bool) {
+	switch {
+	case p.window > 0:
+		return now.Add(-p.window), true
+	case p.calendar != "":
+		return calendarStart(p.calendar, now).Add(-time.Nanosecond), true
+	}
+	return time.Time{}, false
+}
+
+func calendarStart(unit string, now time.Time) time.Time {
+	y, m, d := now.Date()
+	switch unit {
+	case "week":
+		d -= (int(now.Weekday()) + 6) % 7
+	case "month":
+		d = 1
+	case "year":
+		m, d = time.January, 1
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+}
+
+func parseWindow(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("неверная длительность %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("неверная длительность %q (например, 72h или 14d)", s)
+	}
+	return d, nil
+}
+
+func parsePeriodBound(value string, endOfDay bool) (time.Time, error) {
+	for _, layout := range asOfLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range asOfDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			if endOfDay {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("не удалось разобрать дату %q", value)
+}
+
+func (d PeriodDef) build() (period, error) {
+	p := period{name: strings.TrimSpace(d.Name)}
+	kinds := 0
+	if d.Rolling != "" {
+		kinds++
+		w, err := parseWindow(d.Rolling)
+		if err != nil {
+			return period{}, err
+		}
+		p.window = w
+	}
+	if d.Calendar != "" {
+		kinds++
+		switch d.Calendar {
+		case "day", "week", "month", "year":
+			p.calendar = d.Calendar
+		default:
+			return period{}, fmt.Errorf("неизвестный calendar %q (допустимо: day, week, month, year)", d.Calendar)
+		}
+	}
+	if d.Since != "" || d.Until != "" {
+		kinds++
+		var err error
+		if d.Since != "" {
+			if p.since, err = parsePeriodBound(d.Since, false); err != nil {
+				return period{}, err
+			}
+		}
+		if d.Until != "" {
+			if p.until, err = parsePeriodBound(d.Until, true); err != nil {
+				return period{}, err
+			}
+		}
+		if !p.since.IsZero() && !p.until.IsZero() && !p.since.Before(p.until) {
+			return period{}, errors.New("since должно быть раньше until")
+		}
+	}
+	if kinds != 1 {
+		return period{}, errors.New("укажите что-то одно: rolling, calendar или since/until")
+	}
+	return p, nil
+}
+
+func buildPeriods(defs []PeriodDef) ([]period, error) {
+	seen := make(map[string]bool)
+	for _, p := range append(allPeriods[:len(allPeriods):len(allPeriods)], calendarPeriods...) {
+		seen[p.name] = true
+	}
+	var res []period
+	for i, d := range defs {
+		p, err := d.build()
+		if err != nil {
+			return nil, fmt.Errorf("periods #%d: %w", i+1, err)
+		}
+		switch {
+		case p.name == "" || strings.ContainsAny(p.name, ", "):
+			return nil, fmt.Errorf("periods #%d: name не может быть пустым или содержать пробелы и запятые", i+1)
+		case seen[p.name]:
+			return nil, fmt.Errorf("periods #%d: период %q уже есть", i+1, p.name)
+		}
+		seen[p.name] = true
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func (cfg *Config) knownPeriods() []period {
+	res := append(allPeriods[:len(allPeriods):len(allPeriods)], calendarPeriods...)
+	return append(res, cfg.periods...)
+}
+
+func (cfg *Config) exportPeriods() []period {
+	return append(allPeriods[:len(allPeriods):len(allPeriods)], cfg.periods...)
+}
+
+func (cfg *Config) parsePeriods(spec string) ([]period, error) {
+	if strings.TrimSpace(spec) == "" {
+		return allPeriods, nil
+	}
+	known := cfg.knownPeriods()
+	var res []period
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, p := range known {
+			if p.name == name {
+				res = append(res, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(known))
+			for i, p := range known {
+				names[i] = p.name
+			}
+			return nil, fmt.Errorf("неизвестный период %q (допустимо: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return res, nil
+}
+
+func uniquePeriods(periods []period) []period {
+	seen := make(map[string]bool, len(periods))
+	var res []period
+	for _, p := range periods {
+		if !seen[p.name] {
+			seen[p.name] = true
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func aggregatePeriods(sales []Sale, periods []period, now time.Time) map[string]map[string]*Server {
+	periods = uniquePeriods(periods)
+	res := make(map[string]map[string]*Server, len(periods))
+	for _, p := range periods {
+		res[p.name] = make(map[string]*Server)
+	}
+	for _, s := range sales {
+		addPeriodSale(res, periods, s, now)
+	}
+	return res
+}
+
+// addPeriodSale adds s to the tables of aggregatePeriods whose period has it.
+func addPeriodSale(agg map[string]map[string]*Server, periods []period, s Sale, now time.Time) {
+	for _, p := range periods {
+		if p.contains(s.Time, now) {
+			aggregateSale(agg[p.name], s)
+		}
+	}
+}
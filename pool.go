@@ -0,0 +1,58 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+var (
+	parseSlotsMu sync.Mutex
+	parseSlots   chan struct{}
+)
+
+func (cfg *Config) parseWorkers() int {
+	if cfg.ParseWorkers > 0 {
+		return cfg.ParseWorkers
+	}
+	return runtime.NumCPU()
+}
+
+func forEachParallel(n, workers int, fn func(i int) error) error {
+	if workers > n {
+		workers = n
+	}
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func acquireParseSlot(cfg *Config) func() {
+	parseSlotsMu.Lock()
+	if parseSlots == nil || cap(parseSlots) != cfg.parseWorkers() {
+		parseSlots = make(chan struct{}, cfg.parseWorkers())
+	}
+	slots := parseSlots
+	parseSlotsMu.Unlock()
+	slots <- struct{}{}
+	return func() { <-slots }
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const priceIndexDays = 14
+
+type indexPoint struct {
+	Day   time.Time
+	Cost  float64
+	Index float64
+}
+
+func priceIndex(basket map[string]float64, sales []Sale) []indexPoint {
+	type acc struct {
+		sum Money
+		qty int
+	}
+	daily := make(map[string]map[string]*acc)
+	for _, s := range sales {
+		if _, ok := basket[s.Item]; !ok || s.Quantity <= 0 {
+			continue
+		}
+		day := s.Time.Format("2006-01-02")
+		if daily[day] == nil {
+			daily[day] = make(map[string]*acc)
+		}
+		a := daily[day][s.Item]
+		if a == nil {
+			a = &acc{}
+			daily[day][s.Item] = a
+		}
+		a.sum += s.Price
+		a.qty += s.Quantity
+	}
+	days := make([]string, 0, len(daily))
+	for d := range daily {
+		days = append(days, d)
+	}
+	sort.Strings(days)
+
+	last := make(map[string]float64)
+	var points []indexPoint
+	base := 0.0
+	for _, d := range days {
+		for item, a := range daily[d] {
+			last[item] = a.sum.Float() / float64(a.qty)
+		}
+		if len(last) < len(basket) {
+			continue
+		}
+		cost := 0.0
+		for item, w := range basket {
+			cost += w * last[item]
+		}
+		if base == 0 {
+			base = cost
+		}
+		t, _ := time.ParseInLocation("2006-01-02", d, time.Local)
+		points = append(points, indexPoint{Day: t, Cost: cost, Index: cost / base * 100})
+	}
+	return points
+}
+
+func printPriceIndex(cfg *Config, sales []Sale) {
+	if len(cfg.basket) == 0 {
+		return
+	}
+	points := priceIndex(cfg.basket, sales)
+	fmt.Fprintln(out, "\nИндекс цен по корзине (база = 100):")
+	if len(points) == 0 {
+		fmt.Fprintln(out, "    (недостаточно данных: нужны продажи каждого предмета корзины)")
+		return
+	}
+	start := 0
+	if len(points) > priceIndexDays {
+		start = len(points) - priceIndexDays
+	}
+	w := newTable()
+	fmt.Fprintln(w, "    Дата\tСтоимость корзины\tИндекс\tИзменение")
+	for i := start; i < len(points); i++ {
+		p := points[i]
+		change := ""
+		if i > 0 {
+			change = fmt.Sprintf("%+.1f%%", (p.Cost/points[i-1].Cost-1)*100)
+		}
+		fmt.Fprintf(w, "    %s\t$%.2f\t%.1f\t%s\n", formatDate(p.Day, cfg.Language), p.Cost, p.Index, change)
+	}
+	w.Flush()
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Profile struct {
+	Name            string        `json:"name,omitempty"`
+	SaleTrigger     string        `json:"sale_trigger,omitempty"`
+	PurchaseTrigger string        `json:"purchase_trigger,omitempty"`
+	TradeOutTrigger string        `json:"trade_out_trigger,omitempty"`
+	TradeInTrigger  string        `json:"trade_in_trigger,omitempty"`
+	ListingTrigger  string        `json:"listing_trigger,omitempty"`
+	ExpiredTrigger  string        `json:"expired_trigger,omitempty"`
+	Labels          ProfileLabels `json:"labels"`
+	DateFormats     []string      `json:"date_formats,omitempty"`
+	Currency        string        `json:"currency,omitempty"`
+}
+
+type ProfileLabels struct {
+	Server        []string `json:"server,omitempty"`
+	Character     []string `json:"character,omitempty"`
+	Item          []string `json:"item,omitempty"`
+	Quantity      []string `json:"quantity,omitempty"`
+	SalePrice     []string `json:"sale_price,omitempty"`
+	PurchasePrice []string `json:"purchase_price,omitempty"`
+	Counterparty  []string `json:"counterparty,omitempty"`
+	TradePrice    []string `json:"trade_price,omitempty"`
+	ListingPrice  []string `json:"listing_price,omitempty"`
+	Fee           []string `json:"fee,omitempty"`
+	Buyer         []string `json:"buyer,omitempty"`
+}
+
+var defaultProfile = Profile{
+	Name:            "default",
+	SaleTrigger:     "Вы успешно продали предмет",
+	PurchaseTrigger: "Вы успешно купили предмет",
+	TradeOutTrigger: "Вы передали предмет",
+	TradeInTrigger:  "Вы получили предмет",
+	ListingTrigger:  "Вы выставили предмет на продажу",
+	ExpiredTrigger:  "Лот не продан и возвращён",
+	Labels: ProfileLabels{
+		Server:        []string{"Сервер:"},
+		Character:     []string{"Персонаж:"},
+		Item:          []string{"Название:", "Предмет:"},
+		Quantity:      []string{"Кол-во:", "Количество:"},
+		SalePrice:     []string{"Цена продажи:"},
+		PurchasePrice: []string{"Цена покупки:"},
+		Counterparty:  []string{"Игрок:", "Получатель:", "Отправитель:"},
+		TradePrice:    []string{"Сумма сделки:"},
+		ListingPrice:  []string{"Цена лота:", "Цена:"},
+		Fee:           []string{"Комиссия рынка:", "Комиссия:"},
+		Buyer:         []string{"Покупатель:"},
+	},
+	Currency: "$",
+}
+
+var englishProfile = Profile{
+	Name:            "english",
+	SaleTrigger:     "You have successfully sold an item",
+	PurchaseTrigger: "You have successfully bought an item",
+	TradeOutTrigger: "You have given an item",
+	TradeInTrigger:  "You have received an item",
+	ListingTrigger:  "You have listed an item",
+	ExpiredTrigger:  "Your lot has expired",
+	Labels: ProfileLabels{
+		Server:        []string{"Server:"},
+		Character:     []string{"Character:"},
+		Item:          []string{"Item:", "Name:"},
+		Quantity:      []string{"Quantity:", "Amount:", "Qty:"},
+		SalePrice:     []string{"Sale price:", "Price:"},
+		PurchasePrice: []string{"Purchase price:", "Price:"},
+		Counterparty:  []string{"Player:", "Recipient:", "Sender:"},
+		TradePrice:    []string{"Deal amount:", "Price:"},
+		ListingPrice:  []string{"Listing price:", "Price:"},
+		Fee:           []string{"Market fee:", "Commission:", "Fee:"},
+		Buyer:         []string{"Buyer:"},
+	},
+	Currency: "$",
+}
+
+type tradeFormat struct {
+	name            string
+	saleTrigger     []byte
+	purchaseTrigger []byte
+	saleRe          *regexp.Regexp
+	purchaseRe      *regexp.Regexp
+	saleLabels      [][][]byte
+	purchaseLabels  [][][]byte
+	tradeOutTrigger []byte
+	tradeInTrigger  []byte
+	tradeRe         *regexp.Regexp
+	tradeLabels     [][][]byte
+	listingTrigger  []byte
+	listingRe       *regexp.Regexp
+	listingLabels   [][][]byte
+	expiredTrigger  []byte
+	expiredRe       *regexp.Regexp
+	expiredLabels   [][][]byte
+	feeRe           *regexp.Regexp
+	buyerRe         *regexp.Regexp
+	labels          [][]byte
+	currency        []byte
+	layouts         []exportLayout
+}
+
+var defaultTradeFormat = mustTradeFormat(defaultProfile)
+
+func mustTradeFormat(p Profile) *tradeFormat {
+	tf, err := p.compile()
+	if err != nil {
+		panic(err)
+	}
+	return tf
+}
+
+func (p Profile) withDefaults() Profile {
+	d := defaultProfile
+	if p.Name != "" {
+		d.Name = p.Name
+	}
+	if p.SaleTrigger != "" {
+		d.SaleTrigger = p.SaleTrigger
+	}
+	if p.PurchaseTrigger != "" {
+		d.PurchaseTrigger = p.PurchaseTrigger
+	}
+	if p.TradeOutTrigger != "" {
+		d.TradeOutTrigger = p.TradeOutTrigger
+	}
+	if p.TradeInTrigger != "" {
+		d.TradeInTrigger = p.TradeInTrigger
+	}
+	if p.ListingTrigger != "" {
+		d.ListingTrigger = p.ListingTrigger
+	}
+	if p.ExpiredTrigger != "" {
+		d.ExpiredTrigger = p.ExpiredTrigger
+	}
+	for _, f := range []struct{ dst, src *[]string }{
+		{&d.Labels.Server, &p.Labels.Server},
+		{&d.Labels.Character, &p.Labels.Character},
+		{&d.Labels.Item, &p.Labels.Item},
+		{&d.Labels.Quantity, &p.Labels.Quantity},
+		{&d.Labels.SalePrice, &p.Labels.SalePrice},
+		{&d.Labels.PurchasePrice, &p.Labels.PurchasePrice},
+		{&d.Labels.Counterparty, &p.Labels.Counterparty},
+		{&d.Labels.TradePrice, &p.Labels.TradePrice},
+		{&d.Labels.ListingPrice, &p.Labels.ListingPrice},
+		{&d.Labels.Fee, &p.Labels.Fee},
+		{&d.Labels.Buyer, &p.Labels.Buyer},
+	} {
+		if len(*f.src) > 0 {
+			*f.dst = *f.src
+		}
+	}
+	d.DateFormats = p.DateFormats
+	if p.Currency != "" {
+		d.Currency = p.Currency
+	}
+	return d
+}
+
+func labelPattern(labels []string) string {
+	quoted := make([]string, len(labels))
+	for i, l := range labels {
+		quoted[i] = regexp.QuoteMeta(strings.TrimSpace(l))
+	}
+	return "(?:" + strings.Join(quoted, "|") + ")"
+}
+
+func labelBytes(groups ...[]string) [][][]byte {
+	res := make([][][]byte, len(groups))
+	for i, g := range groups {
+		for _, l := range g {
+			res[i] = append(res[i], []byte(strings.TrimSpace(l)))
+		}
+	}
+	return res
+}
+
+func (p Profile) compile() (*tradeFormat, error) {
+	p = p.withDefaults()
+	triggers := map[string]bool{}
+	for _, t := range []string{p.SaleTrigger, p.PurchaseTrigger, p.TradeOutTrigger, p.TradeInTrigger, p.ListingTrigger, p.ExpiredTrigger} {
+		if triggers[t] {
+			return nil, fmt.Errorf("profile: фраза %q указана для двух типов сообщений", t)
+		}
+		triggers[t] = true
+	}
+	groups := [][]string{p.Labels.Server, p.Labels.Character, p.Labels.Item, p.Labels.Quantity, p.Labels.SalePrice, p.Labels.PurchasePrice, p.Labels.Counterparty, p.Labels.TradePrice, p.Labels.ListingPrice, p.Labels.Fee, p.Labels.Buyer}
+	var all []string
+	for _, g := range groups {
+		for _, l := range g {
+			if strings.TrimSpace(l) == "" {
+				return nil, errors.New("profile: пустая подпись поля")
+			}
+		}
+		all = append(all, g...)
+	}
+	cur := regexp.QuoteMeta(p.Currency)
+	buyer := `(?:` + labelPattern(p.Labels.Buyer) + `.+?\s*)?`
+	build := func(price []string) (*regexp.Regexp, error) {
+		return regexp.Compile(`(?s)` +
+			labelPattern(p.Labels.Server) + `\s*(.+?)\s*` +
+			labelPattern(p.Labels.Character) + `\s*(.+?)\s*` + buyer +
+			labelPattern(p.Labels.Item) + `\s*(.+?)\s*` +
+			labelPattern(p.Labels.Quantity) + `\s*([0-9]+)\s*` + buyer +
+			labelPattern(price) + `\s*(` + cur + `[0-9\s,]+|[0-9][0-9\s,]*` + cur + `)`)
+	}
+	saleRe, err := build(p.Labels.SalePrice)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	purchaseRe, err := build(p.Labels.PurchasePrice)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	listingRe, err := build(p.Labels.ListingPrice)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	expiredRe, err := regexp.Compile(`(?s)` +
+		labelPattern(p.Labels.Server) + `\s*(.+?)\s*` +
+		labelPattern(p.Labels.Character) + `\s*(.+?)\s*` +
+		labelPattern(p.Labels.Item) + `\s*(.+?)\s*` +
+		labelPattern(p.Labels.Quantity) + `\s*([0-9]+)` +
+		`(?:\s*` + labelPattern(p.Labels.ListingPrice) + `\s*(` + cur + `[0-9\s,]+|[0-9][0-9\s,]*` + cur + `))?`)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	feeRe, err := regexp.Compile(labelPattern(p.Labels.Fee) + `\s*(` + cur + `[0-9\s,]+|[0-9][0-9\s,]*` + cur + `)`)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	buyerRe, err := regexp.Compile(`(?s)` + labelPattern(p.Labels.Buyer) + `\s*(.+?)\s*(?:` + labelPattern(all) + `|$)`)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+	tradeRe, err := regexp.Compile(`(?s)` +
+		labelPattern(p.Labels.Server) + `\s*(.+?)\s*` +
+		labelPattern(p.Labels.Character) + `\s*(.+?)\s*` +
+		labelPattern(p.Labels.Counterparty) + `\s*(.+?)\s*` +
+		labelPattern(p.Labels.Item) + `\s*(.+?)\s*` +
+		labelPattern(p.Labels.Quantity) + `\s*([0-9]+)` +
+		`(?:\s*` + labelPattern(p.Labels.TradePrice) + `\s*(` + cur + `[0-9\s,]+|[0-9][0-9\s,]*` + cur + `))?`)
+	if err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+
+	layouts := make([]exportLayout, len(exportLayouts))
+	for i, l := range exportLayouts {
+		l.dateLayouts = append(append([]string(nil), l.dateLayouts...), p.DateFormats...)
+		layouts[i] = l
+	}
+	return &tradeFormat{
+		name:            p.Name,
+		saleTrigger:     []byte(p.SaleTrigger),
+		purchaseTrigger: []byte(p.PurchaseTrigger),
+		saleRe:          saleRe,
+		purchaseRe:      purchaseRe,
+		saleLabels:      labelBytes(p.Labels.Server, p.Labels.Character, p.Labels.SalePrice),
+		purchaseLabels:  labelBytes(p.Labels.Server, p.Labels.Character, p.Labels.PurchasePrice),
+		tradeOutTrigger: []byte(p.TradeOutTrigger),
+		tradeInTrigger:  []byte(p.TradeInTrigger),
+		tradeRe:         tradeRe,
+		tradeLabels:     labelBytes(p.Labels.Server, p.Labels.Character, p.Labels.Counterparty),
+		listingTrigger:  []byte(p.ListingTrigger),
+		listingRe:       listingRe,
+		listingLabels:   labelBytes(p.Labels.Server, p.Labels.Character, p.Labels.ListingPrice),
+		expiredTrigger:  []byte(p.ExpiredTrigger),
+		expiredRe:       expiredRe,
+		expiredLabels:   labelBytes(p.Labels.Server, p.Labels.Character, p.Labels.Item),
+		feeRe:           feeRe,
+		buyerRe:         buyerRe,
+		labels:          labelBytes(all)[0],
+		currency:        []byte(p.Currency),
+		layouts:         layouts,
+	}, nil
+}
+
+var defaultMessageFormats = []*tradeFormat{defaultTradeFormat, mustTradeFormat(englishProfile)}
+
+func (cfg *Config) trades() *tradeFormat {
+	if cfg.tradeFormat != nil {
+		return cfg.tradeFormat
+	}
+	return defaultTradeFormat
+}
+
+func (cfg *Config) messageFormats() []*tradeFormat {
+	if cfg.tradeFormats != nil {
+		return cfg.tradeFormats
+	}
+	return defaultMessageFormats
+}
+
+func compileMessageFormats(p Profile) ([]*tradeFormat, error) {
+	main, err := p.compile()
+	if err != nil {
+		return nil, err
+	}
+	en := englishProfile
+	en.DateFormats = p.DateFormats
+	alt, err := en.compile()
+	if err != nil {
+		return nil, err
+	}
+	return []*tradeFormat{main, alt}, nil
+}
+
+func formatNames(formats []*tradeFormat) []string {
+	names := make([]string, len(formats))
+	for i, tf := range formats {
+		names[i] = tf.name
+	}
+	return names
+}
+
+func containsAll(text []byte, groups [][][]byte) bool {
+	for _, g := range groups {
+		found := false
+		for _, l := range g {
+			if bytes.Contains(text, l) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
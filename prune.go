@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+const salesMonthlyFile = "sales_monthly.json"
+
+type Retention struct {
+	OlderThan string `json:"older_than"`
+	Archive   string `json:"archive,omitempty"`
+
+	window time.Duration
+}
+
+func (r *Retention) validate() error {
+	d, err := parseWindow(r.OlderThan)
+	if err != nil {
+		return fmt.Errorf("retention: older_than: %w", err)
+	}
+	r.window = d
+	return nil
+}
+
+type monthlyTotal struct {
+	Month     string `json:"month"`
+	Server    string `json:"server"`
+	Character string `json:"character"`
+	Item      string `json:"item"`
+	Sales     int    `json:"sales"`
+	Quantity  int    `json:"quantity"`
+	Revenue   Money  `json:"revenue"`
+	Fees      Money  `json:"fees,omitempty"`
+}
+
+type monthlySnapshot struct {
+	Through time.Time      `json:"through"`
+	Totals  []monthlyTotal `json:"totals"`
+}
+
+func loadMonthlySnapshot() (*monthlySnapshot, error) {
+	data, err := storage.Get(salesMonthlyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return &monthlySnapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m monthlySnapshot
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s повреждён: %w", salesMonthlyFile, err)
+	}
+	return &m, nil
+}
+
+func (m *monthlySnapshot) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.Put(salesMonthlyFile, data)
+}
+
+func (m *monthlySnapshot) add(sales []Sale) {
+	type key struct{ month, server, character, item string }
+	index := make(map[key]int, len(m.Totals))
+	for i, t := range m.Totals {
+		index[key{t.Month, t.Server, t.Character, t.Item}] = i
+	}
+	for _, s := range sales {
+		k := key{s.Time.Format("2006-01"), s.Server, s.Character, s.Item}
+		i, ok := index[k]
+		if !ok {
+			i = len(m.Totals)
+			index[k] = i
+			m.Totals = append(m.Totals, monthlyTotal{Month: k.month, Server: k.server, Character: k.character, Item: k.item})
+		}
+		t := &m.Totals[i]
+		t.Sales++
+		t.Quantity += s.Quantity
+		t.Revenue += s.Price
+		t.Fees += s.Fee
+	}
+	sort.Slice(m.Totals, func(i, j int) bool {
+		a, b := m.Totals[i], m.Totals[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.Server != b.Server {
+			return a.Server < b.Server
+		}
+		if a.Character != b.Character {
+			return a.Character < b.Character
+		}
+		return a.Item < b.Item
+	})
+}
+
+func (m *monthlySnapshot) months() int {
+	seen := make(map[string]bool)
+	for _, t := range m.Totals {
+		seen[t.Month] = true
+	}
+	return len(seen)
+}
+
+func prunedThrough() time.Time {
+	m, err := loadMonthlySnapshot()
+	if err != nil {
+		return time.Time{}
+	}
+	return m.Through
+}
+
+func retainedSales(sales []Sale, through time.Time, purged purgeLog) []Sale {
+	if through.IsZero() && len(purged) == 0 {
+		return sales
+	}
+	kept := make([]Sale, 0, len(sales))
+	for _, s := range sales {
+		if !s.Time.Before(through) && !purged.covers(s.Character, s.Time) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func pruneCutoff(window time.Duration, now time.Time) time.Time {
+	return calendarStart("month", now.Add(-window))
+}
+
+func storedSales(ctx context.Context, cfg *Config, before time.Time) ([]string, []Sale, error) {
+	seen := make(map[string]bool)
+	var keys []string
+	var sales []Sale
+	err := eachStoredSale(ctx, cfg, before, func(key string, s Sale) error {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+			sales = append(sales, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, sales, nil
+}
+
+// eachStoredSale streams the ledger and then the sales database row by row.
+// A sale kept in both comes twice under the same key.
+func eachStoredSale(ctx context.Context, cfg *Config, before time.Time, fn func(key string, s Sale) error) error {
+	err := eachLedgerEntry(salesLedgerFile, func(e ledgerEntry) error {
+		if before.IsZero() || e.Time.Before(before) {
+			return fn(e.Key, e.sale())
+		}
+		return nil
+	})
+	if err != nil || cfg.SalesDB == "" {
+		return err
+	}
+	if _, err := os.Stat(cfg.SalesDB); errors.Is(err, os.ErrNotExist) && !isPostgresDSN(cfg.SalesDB) {
+		return nil
+	}
+	db, err := openSalesDB(cfg.SalesDB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var cond string
+	var args []any
+	if !before.IsZero() {
+		cond, args = "time < ?", []any{before.UTC().Format(time.RFC3339)}
+	}
+	if err := eachSalesDBRow(ctx, db, cond, args, fn); err != nil {
+		return fmt.Errorf("%s: %w", salesDBName(cfg.SalesDB), err)
+	}
+	return nil
+}
+
+func storedSalesBefore(ctx context.Context, cfg *Config, cutoff time.Time) ([]string, []Sale, error) {
+	keys, sales, err := storedSales(ctx, cfg, cutoff)
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		seen[key] = true
+	}
+	msgs, err := loadLiveMessages()
+	if err != nil {
+		return nil, nil, err
+	}
+	live := &parseResult{}
+	var liveSales []Sale
+	for _, m := range msgs {
+		if m.Time.Before(cutoff) {
+			_ = live.addTrade([]byte(m.Text), m.Time, m.ID, cfg, func(s Sale) error {
+				liveSales = append(liveSales, s)
+				return nil
+			})
+		}
+	}
+	for i, key := range storedSaleKeys(liveSales) {
+		if !seen[key] {
+			keys = append(keys, key)
+			sales = append(sales, liveSales[i])
+		}
+	}
+	return keys, sales, nil
+}
+
+func archiveSales(path string, keys []string, sales []Sale) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for i, s := range sales {
+		if err := enc.Encode(newLedgerEntry(keys[i], s)); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type pruneResult struct {
+	sales   int
+	months  int
+	records map[string]int
+}
+
+func prune(ctx context.Context, cfg *Config, cutoff time.Time, archive string, dryRun bool) (pruneResult, error) {
+	res := pruneResult{records: make(map[string]int)}
+	keys, sales, err := storedSalesBefore(ctx, cfg, cutoff)
+	if err != nil {
+		return res, err
+	}
+	res.sales = len(sales)
+	months := make(map[string]bool)
+	for _, s := range sales {
+		months[s.Time.Format("2006-01")] = true
+	}
+	res.months = len(months)
+
+	targets := purgeTargets(cfg)
+	for _, t := range targets {
+		n, err := t.Purge(purgeFilter{before: cutoff}, true)
+		if err != nil {
+			return res, fmt.Errorf("%s: %w", t.Name(), err)
+		}
+		res.records[t.Name()] = n
+	}
+	if dryRun {
+		return res, nil
+	}
+
+	snap, err := loadMonthlySnapshot()
+	if err != nil {
+		return res, err
+	}
+	for i := range sales {
+		sales[i].Item = cfg.canonicalItem(sales[i].Item)
+	}
+	snap.add(sales)
+	if cutoff.After(snap.Through) {
+		snap.Through = cutoff
+	}
+	if archive != "" && len(sales) > 0 {
+		if err := archiveSales(archive, keys, sales); err != nil {
+			return res, fmt.Errorf("не удалось дописать архив %s: %w", archive, err)
+		}
+	}
+	if err := snap.save(); err != nil {
+		return res, fmt.Errorf("не удалось сохранить %s: %w", salesMonthlyFile, err)
+	}
+	for _, t := range targets {
+		if res.records[t.Name()] == 0 {
+			continue
+		}
+		if _, err := t.Purge(purgeFilter{before: cutoff}, false); err != nil {
+			return res, fmt.Errorf("%s: %w", t.Name(), err)
+		}
+	}
+	return res, nil
+}
+
+func autoPrune(ctx context.Context, cfg *Config, now time.Time) {
+	if cfg.Retention == nil {
+		return
+	}
+	cutoff := pruneCutoff(cfg.Retention.window, now)
+	if !cutoff.After(prunedThrough()) {
+		return
+	}
+	res, err := prune(ctx, cfg, cutoff, cfg.Retention.Archive, false)
+	if err != nil {
+		fmt.Fprintf(out, "Предупреждение: не удалось удалить старые продажи: %v\n", err)
+		return
+	}
+	if res.sales > 0 {
+		fmt.Fprintf(out, "Хранение: продаж до %s удалено %d, итоги по месяцам сохранены в %s\n", formatDate(cutoff, cfg.Language), res.sales, salesMonthlyFile)
+	}
+}
+
+func cmdPrune(args []string) error {
+	fs := newFlagSet("prune")
+	olderThan := fs.String("older-than", "", "удалить продажи старше срока, например 180d (по умолчанию retention.older_than)")
+	archive := fs.String("archive", "", "дописать удаляемые продажи в этот файл JSONL (по умолчанию retention.archive)")
+	dryRun := fs.Bool("dry-run", false, "только показать, что будет удалено")
+	yes := fs.Bool("yes", false, "не спрашивать подтверждение")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	var window time.Duration
+	label := *olderThan
+	switch {
+	case label != "":
+		if window, err = parseWindow(label); err != nil {
+			return err
+		}
+	case cfg.Retention != nil:
+		window, label = cfg.Retention.window, cfg.Retention.OlderThan
+	default:
+		return errors.New("укажите --older-than (например, 180d) или retention.older_than в config.json")
+	}
+	if *archive == "" && cfg.Retention != nil {
+		*archive = cfg.Retention.Archive
+	}
+
+	ctx, stop := runContext(cfg)
+	defer stop()
+	cutoff := pruneCutoff(window, time.Now())
+	fmt.Fprintf(out, "Удаляются записи до %s (старше %s, целыми месяцами)\n", formatDate(cutoff, cfg.Language), label)
+	res, err := prune(ctx, cfg, cutoff, *archive, true)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, t := range purgeTargets(cfg) {
+		fmt.Fprintf(out, "%s: записей к удалению — %d\n", t.Name(), res.records[t.Name()])
+		total += res.records[t.Name()]
+	}
+	fmt.Fprintf(out, "Продаж к сворачиванию в итоги по месяцам: %d (месяцев: %d)\n", res.sales, res.months)
+	if total == 0 || *dryRun {
+		return nil
+	}
+	question := fmt.Sprintf("Удалить %d записей? Итоги по месяцам останутся в %s", total, salesMonthlyFile)
+	if *archive != "" {
+		question += ", продажи — в архиве " + *archive
+	}
+	if !*yes && !confirm(question+".") {
+		fmt.Fprintln(out, "Отменено")
+		return nil
+	}
+	if _, err := prune(ctx, cfg, cutoff, *archive, false); err != nil {
+		return err
+	}
+	snap, err := loadMonthlySnapshot()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Готово. В %s итоги за %d мес.; они учитываются в market trends.\n", salesMonthlyFile, snap.months())
+	return nil
+}
+
+type monthlyPurge struct{}
+
+func (monthlyPurge) Name() string { return salesMonthlyFile }
+
+// Purge removes monthly totals that lie wholly before the cutoff. A total
+// cannot be split by day, so a --before inside a month that has totals is
+// refused.
+func (monthlyPurge) Purge(f purgeFilter, dryRun bool) (int, error) {
+	snap, err := loadMonthlySnapshot()
+	if err != nil || len(snap.Totals) == 0 {
+		return 0, err
+	}
+	kept := snap.Totals[:0]
+	n := 0
+	for _, t := range snap.Totals {
+		start, err := time.ParseInLocation("2006-01", t.Month, time.Local)
+		if err != nil {
+			kept = append(kept, t)
+			continue
+		}
+		end := start.AddDate(0, 1, 0)
+		_, id := splitCharacter(t.Character)
+		if !f.before.IsZero() && start.Before(f.before) && end.After(f.before) && (f.character == "" || id == f.character) {
+			return 0, fmt.Errorf("итоги за %s нельзя разделить по дням — укажите --before %s или %s", t.Month, start.Format("2006-01-02"), end.Format("2006-01-02"))
+		}
+		if f.matches(id, end.Add(-time.Nanosecond)) {
+			n++
+			continue
+		}
+		kept = append(kept, t)
+	}
+	if dryRun || n == 0 {
+		return n, nil
+	}
+	snap.Totals = kept
+	return n, snap.save()
+}
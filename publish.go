@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PublishTarget struct {
+	Type      string `json:"type"`
+	PublicURL string `json:"public_url,omitempty"`
+
+	Target string `json:"target,omitempty"`
+	Port   int    `json:"port,omitempty"`
+
+	Bucket    string `json:"bucket,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	AccessKey string `json:"access_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+}
+
+type publisher interface {
+	Publish(dir string) error
+}
+
+var publisherFactories = map[string]func(PublishTarget) (publisher, error){
+	"scp": newSCPPublisher,
+	"s3":  newS3Publisher,
+}
+
+func buildPublisher(t *PublishTarget) (publisher, error) {
+	if t == nil {
+		return nil, nil
+	}
+	kind := t.Type
+	if kind == "sftp" {
+		kind = "scp"
+	}
+	factory, ok := publisherFactories[kind]
+	if !ok {
+		return nil, fmt.Errorf("publish: неизвестный тип %q (допустимо: scp, s3)", t.Type)
+	}
+	p, err := factory(*t)
+	if err != nil {
+		return nil, fmt.Errorf("publish (%s): %w", t.Type, err)
+	}
+	return p, nil
+}
+
+func cmdPublish(args []string) error {
+	fset := newFlagSet("publish")
+	dir := fset.String("dir", "", "папка для сборки (по умолчанию site_dir или временная папка)")
+	noUpload := fset.Bool("no-upload", false, "только собрать, не выгружать")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		*dir = cfg.SiteDir
+	}
+	if cfg.publisher == nil {
+		*noUpload = true
+		if *dir == "" {
+			return errors.New("назначение publish не настроено — укажите его в config.json или задайте папку через --dir")
+		}
+	}
+	if *dir == "" {
+		tmp, err := os.MkdirTemp("", "market-publish-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		*dir = tmp
+	}
+
+	ctx, stop := runContext(cfg)
+	defer stop()
+	sales, err := loadLatestSales(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := writeSite(*dir, sales, nil, cfg, time.Now()); err != nil {
+		return fmt.Errorf("не удалось собрать отчёт: %w", err)
+	}
+	fmt.Fprintf(out, "Отчёт собран в %s\n", *dir)
+
+	if *noUpload {
+		return nil
+	}
+	if err := cfg.publisher.Publish(*dir); err != nil {
+		return fmt.Errorf("не удалось выгрузить отчёт: %w", err)
+	}
+	fmt.Fprintln(out, "Отчёт выгружен.")
+	if cfg.Publish.PublicURL != "" {
+		fmt.Fprintln(out, "Адрес:", cfg.Publish.PublicURL)
+	}
+	return nil
+}
+
+type scpPublisher struct {
+	target string
+	port   int
+}
+
+func newSCPPublisher(t PublishTarget) (publisher, error) {
+	if !strings.Contains(t.Target, ":") {
+		return nil, errors.New("target должен иметь вид user@host:/путь")
+	}
+	return scpPublisher{target: t.Target, port: t.Port}, nil
+}
+
+func (p scpPublisher) Publish(dir string) error {
+	args := []string{"-B", "-r"}
+	if p.port != 0 {
+		args = append(args, "-P", strconv.Itoa(p.port))
+	}
+	args = append(args, filepath.Join(dir, "."), p.target)
+	cmd := exec.Command("scp", args...)
+	cmd.Stdout, cmd.Stderr = out, os.Stderr
+	return cmd.Run()
+}
+
+type s3Publisher struct {
+	endpoint, bucket, region, prefix string
+	accessKey, secretKey             string
+}
+
+func newS3Publisher(t PublishTarget) (publisher, error) {
+	p := s3Publisher{
+		endpoint:  strings.TrimSuffix(t.Endpoint, "/"),
+		bucket:    t.Bucket,
+		region:    t.Region,
+		prefix:    strings.Trim(t.Prefix, "/"),
+		accessKey: t.AccessKey,
+		secretKey: t.SecretKey,
+	}
+	if p.bucket == "" {
+		return nil, errors.New("не указан bucket")
+	}
+	if p.region == "" {
+		p.region = "us-east-1"
+	}
+	if p.endpoint == "" {
+		p.endpoint = "https://s3." + p.region + ".amazonaws.com"
+	}
+	if p.accessKey == "" {
+		p.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	}
+	if p.secretKey == "" {
+		p.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if p.accessKey == "" || p.secretKey == "" {
+		return nil, errors.New("не указаны access_key/secret_key (или AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)")
+	}
+	return p, nil
+}
+
+func (p s3Publisher) Publish(dir string) error {
+	return filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		body, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		key := path.Join(p.prefix, filepath.ToSlash(rel))
+		if err := p.put(key, body); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		return nil
+	})
+}
+
+func (p s3Publisher) put(key string, body []byte) error {
+	uri := "/" + s3Escape(p.bucket) + "/" + s3Escape(key)
+	req, err := http.NewRequest(http.MethodPut, p.endpoint+uri, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	p.sign(req, uri, body, time.Now().UTC())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return nil
+}
+
+func (p s3Publisher) sign(req *http.Request, uri string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		uri,
+		"",
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + p.region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := []byte("AWS4" + p.secretKey)
+	for _, part := range []string{day, p.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", p.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func s3Escape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
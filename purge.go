@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type purgeFilter struct {
+	before    time.Time
+	character string
+}
+
+// matches reports whether a record of the character with the given ID made
+// at t falls under every filter that is set.
+func (f purgeFilter) matches(characterID string, t time.Time) bool {
+	if f.character == "" && f.before.IsZero() {
+		return false
+	}
+	return (f.character == "" || characterID == f.character) && (f.before.IsZero() || t.Before(f.before))
+}
+
+type purgeTarget interface {
+	Name() string
+	Purge(f purgeFilter, dryRun bool) (int, error)
+}
+
+func cmdPurge(args []string) error {
+	fs := newFlagSet("purge")
+	before := fs.String("before", "", "удалить записи старше даты (2006-01-02)")
+	character := fs.String("character", "", "удалить записи персонажа с указанным ID (вместе с --before — только старше даты)")
+	dryRun := fs.Bool("dry-run", false, "только показать, что будет удалено")
+	yes := fs.Bool("yes", false, "не спрашивать подтверждение")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var f purgeFilter
+	if *before != "" {
+		t, err := time.ParseInLocation("2006-01-02", *before, time.Local)
+		if err != nil {
+			return fmt.Errorf("дата %q не в формате 2006-01-02", *before)
+		}
+		f.before = t
+	}
+	f.character = strings.TrimPrefix(strings.TrimSpace(*character), "#")
+	if f.before.IsZero() && f.character == "" {
+		return errors.New("укажите --before и/или --character")
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	ctx, stop := runContext(cfg)
+	defer stop()
+	targets := []purgeTarget{correctionsPurge{ctx: ctx, cfg: cfg}}
+	targets = append(targets, purgeTargets(cfg)...)
+	targets = append(targets, parseCachePurge{}, monthlyPurge{})
+	if cfg.Retention != nil && cfg.Retention.Archive != "" {
+		targets = append(targets, ledgerPurge{path: cfg.Retention.Archive})
+	}
+	if cfg.Storage == "bolt" || fileExists(boltStoreFile) {
+		targets = append(targets, boltStorePurge{cfg: cfg})
+	}
+
+	total := 0
+	for _, t := range targets {
+		n, err := t.Purge(f, true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Name(), err)
+		}
+		fmt.Fprintf(out, "%s: записей к удалению — %d\n", t.Name(), n)
+		total += n
+	}
+	if *dryRun {
+		return nil
+	}
+	if total > 0 {
+		if !*yes && !confirm(fmt.Sprintf("Удалить %d записей безвозвратно?", total)) {
+			fmt.Fprintln(out, "Отменено")
+			return nil
+		}
+		for _, t := range targets {
+			if _, err := t.Purge(f, false); err != nil {
+				return fmt.Errorf("%s: %w", t.Name(), err)
+			}
+		}
+	}
+	if err := recordPurge(purgeRecord{Time: time.Now(), Character: f.character, Before: f.before}); err != nil {
+		return fmt.Errorf("не удалось сохранить %s: %w", purgeLogFile, err)
+	}
+	fmt.Fprintf(out, "Готово. Фильтр сохранён в %s: такие продажи не будут снова записаны в %s, sales_db и живой поток при следующих отчётах.\n", purgeLogFile, salesLedgerFile)
+	fmt.Fprintln(out, "Исходные папки ChatExport_* не изменяются — удалите их вручную, если нужно.")
+	return nil
+}
+
+const purgeLogFile = "purges.json"
+
+type purgeRecord struct {
+	Time      time.Time `json:"time"`
+	Character string    `json:"character,omitempty"`
+	Before    time.Time `json:"before,omitzero"`
+}
+
+type purgeLog []purgeRecord
+
+func loadPurgeLog() (purgeLog, error) {
+	data, err := storage.Get(purgeLogFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var l purgeLog
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("%s повреждён: %w", purgeLogFile, err)
+	}
+	return l, nil
+}
+
+func recordPurge(r purgeRecord) error {
+	l, err := loadPurgeLog()
+	if err != nil {
+		return err
+	}
+	for _, old := range l {
+		if old.Character == r.Character && old.Before.Equal(r.Before) {
+			return nil
+		}
+	}
+	data, err := json.MarshalIndent(append(l, r), "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.Put(purgeLogFile, data)
+}
+
+// covers reports whether a record of the character made at t was purged
+// earlier and must not be stored again.
+func (l purgeLog) covers(character string, t time.Time) bool {
+	_, id := splitCharacter(character)
+	for _, r := range l {
+		if (purgeFilter{before: r.Before, character: r.Character}).matches(id, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// purgeTargets lists the stored records that both purge and prune remove.
+// Purge also clears the corrections, the parse cache, the monthly totals and
+// the retention archive, which prune itself fills.
+func purgeTargets(cfg *Config) []purgeTarget {
+	targets := []purgeTarget{statePurge{}, ledgerPurge{path: salesLedgerFile}}
+	if cfg.SiteDir != "" {
+		targets = append(targets, sitePurge{dir: cfg.SiteDir, cfg: cfg})
+	}
+	if cfg.SalesDB != "" {
+		targets = append(targets, salesDBPurge{path: cfg.SalesDB})
+	}
+	if cfg.hasLiveSources() {
+		targets = append(targets, liveMessagesPurge{cfg: cfg})
+	}
+	return targets
+}
+
+type statePurge struct{}
+
+func (statePurge) Name() string { return stateFile }
+
+func (statePurge) Purge(f purgeFilter, dryRun bool) (int, error) {
+	st, err := loadState()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for key, day := range st.RevenueAlerts {
+		var id string
+		if parts := strings.Split(key, "/"); len(parts) == 3 {
+			id = parts[1]
+		}
+		d, err := time.ParseInLocation("2006-01-02", day, time.Local)
+		if err != nil && !f.before.IsZero() {
+			continue
+		}
+		if f.matches(id, d) {
+			n++
+			if !dryRun {
+				delete(st.RevenueAlerts, key)
+			}
+		}
+	}
+	for key, names := range st.CharacterNames {
+		var id string
+		if parts := strings.Split(key, "/"); len(parts) == 2 {
+			id = parts[1]
+		}
+		keptNames := names[:0]
+		for _, name := range names {
+			if f.matches(id, name.LastSeen) {
+				n++
+				continue
+			}
+			keptNames = append(keptNames, name)
+		}
+		if !dryRun {
+			st.CharacterNames[key] = keptNames
+			if len(keptNames) == 0 {
+				delete(st.CharacterNames, key)
+			}
+		}
+	}
+	kept := st.Forecasts[:0]
+	for _, fc := range st.Forecasts {
+		if d, err := time.ParseInLocation("2006-01-02", fc.Date, time.Local); err == nil && f.matches("", d) {
+			n++
+			continue
+		}
+		kept = append(kept, fc)
+	}
+	if dryRun || n == 0 {
+		return n, nil
+	}
+	st.Forecasts = kept
+	return n, st.save()
+}
+
+type sitePurge struct {
+	dir string
+	cfg *Config
+}
+
+func (p sitePurge) Name() string { return filepath.Join(p.dir, "data.json") }
+
+func (p sitePurge) Purge(f purgeFilter, dryRun bool) (int, error) {
+	raw, err := readSiteJSON(p.dir, p.cfg)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var data siteData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return 0, err
+	}
+	if f.character == "" || !f.before.IsZero() {
+		return 0, nil
+	}
+	n := 0
+	for i := range data.Servers {
+		kept := data.Servers[i].Characters[:0]
+		for _, ch := range data.Servers[i].Characters {
+			if ch.ID == f.character {
+				n++
+				continue
+			}
+			kept = append(kept, ch)
+		}
+		data.Servers[i].Characters = kept
+	}
+	if dryRun || n == 0 {
+		return n, nil
+	}
+	return n, renderSite(p.dir, data, p.cfg)
+}
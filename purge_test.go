@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPurgeCombinesFilters(t *testing.T) {
+	t.Chdir(t.TempDir())
+	storage = fileStorage{}
+
+	day := func(d int) time.Time { return time.Date(2026, 9, d, 12, 0, 0, 0, time.Local) }
+	sales := []Sale{
+		{MsgID: 1, Time: day(1), Server: "Atlanta", Character: "Icy Godless #288032", Item: "Адреналин", RawItem: "Адреналин", Quantity: 1, Price: 5000 * dollar},
+		{MsgID: 2, Time: day(20), Server: "Atlanta", Character: "Icy Godless #288032", Item: "Адреналин", RawItem: "Адреналин", Quantity: 1, Price: 5000 * dollar},
+		{MsgID: 3, Time: day(2), Server: "Atlanta", Character: "Godless Satanic #268065", Item: "Адреналин", RawItem: "Адреналин", Quantity: 2, Price: 9000 * dollar},
+		{MsgID: 4, Time: day(21), Server: "Atlanta", Character: "Godless Satanic #268065", Item: "Адреналин", RawItem: "Адреналин", Quantity: 2, Price: 9000 * dollar},
+	}
+	if _, err := appendLedger(sales); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "sales.db")
+	db, err := openSalesDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upsertSales(context.Background(), db, sales, day(22)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	f := purgeFilter{before: day(10), character: "288032"}
+	for _, target := range []purgeTarget{ledgerPurge{path: salesLedgerFile}, salesDBPurge{path: dbPath}} {
+		if n, err := target.Purge(f, true); err != nil || n != 1 {
+			t.Fatalf("%s dry run: %d, %v; want 1 record", target.Name(), n, err)
+		}
+		if n, err := target.Purge(f, false); err != nil || n != 1 {
+			t.Fatalf("%s: %d, %v; want 1 record", target.Name(), n, err)
+		}
+	}
+
+	entries, err := loadLedger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ledgerIDs []int64
+	for _, e := range entries {
+		ledgerIDs = append(ledgerIDs, e.MsgID)
+	}
+	db, err = openSalesDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stored, err := loadSalesDB(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dbIDs []int64
+	for _, s := range stored {
+		dbIDs = append(dbIDs, s.MsgID)
+	}
+	for name, ids := range map[string][]int64{salesLedgerFile: ledgerIDs, "sales_db": dbIDs} {
+		slices.Sort(ids)
+		if !slices.Equal(ids, []int64{2, 3, 4}) {
+			t.Errorf("%s keeps messages %v, want [2 3 4]", name, ids)
+		}
+	}
+}
+
+func TestRetainedSalesSkipsPurged(t *testing.T) {
+	t.Chdir(t.TempDir())
+	storage = fileStorage{}
+
+	day := func(d int) time.Time { return time.Date(2026, 9, d, 12, 0, 0, 0, time.Local) }
+	if err := recordPurge(purgeRecord{Time: day(25), Character: "288032", Before: day(10)}); err != nil {
+		t.Fatal(err)
+	}
+	purged, err := loadPurgeLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sales := []Sale{
+		{MsgID: 1, Time: day(1), Character: "Icy Godless #288032"},
+		{MsgID: 2, Time: day(20), Character: "Icy Godless #288032"},
+		{MsgID: 3, Time: day(2), Character: "Godless Satanic #268065"},
+	}
+	var ids []int64
+	for _, s := range retainedSales(sales, time.Time{}, purged) {
+		ids = append(ids, s.MsgID)
+	}
+	if !slices.Equal(ids, []int64{2, 3}) {
+		t.Errorf("retained messages %v, want [2 3]", ids)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+func printRecentSales(sales []Sale, cfg *Config, n int) {
+	byServer := make(map[string][]Sale)
+	for _, s := range sales {
+		byServer[s.Server] = append(byServer[s.Server], s)
+	}
+	servers := make([]string, 0, len(byServer))
+	for srv := range byServer {
+		servers = append(servers, srv)
+	}
+	sortNames(servers, cfg.Language)
+
+	for _, srv := range servers {
+		list := byServer[srv]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+		if len(list) > n {
+			list = list[:n]
+		}
+		loc := cfg.serverLocation(srv)
+		fmt.Fprintf(out, "\nПоследние продажи, сервер %s", srv)
+		if loc != time.Local {
+			fmt.Fprintf(out, " (время сервера: %s)", loc)
+		}
+		fmt.Fprintln(out, ":")
+		w := newTable()
+		fmt.Fprintln(w, "ID\tМестное время\tВремя сервера\tПерсонаж\tПредмет\tКол-во\tЦена")
+		for _, s := range list {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t$%.2f\n",
+				s.MsgID, formatDateTime(s.Time.Local(), cfg.Language), formatDateTime(s.Time.In(loc), cfg.Language),
+				s.Character, s.Item, s.Quantity, s.Price)
+		}
+		w.Flush()
+	}
+}
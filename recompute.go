@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"time"
+)
+
+func cmdRecompute(args []string) error {
+	fs := newFlagSet("recompute")
+	siteDir := fs.String("site", "", "папка сайта (по умолчанию site_dir)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	if *siteDir == "" {
+		*siteDir = cfg.SiteDir
+	}
+
+	for _, cache := range []string{exportCacheFile, parseCacheFile} {
+		if err := storage.Delete(cache); err != nil {
+			return err
+		}
+	}
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	known := make([]string, 0, len(st.KnownItems))
+	for _, it := range st.KnownItems {
+		known = append(known, cfg.canonicalItem(it))
+	}
+	slices.Sort(known)
+	known = slices.Compact(known)
+	if merged := len(st.KnownItems) - len(known); merged > 0 {
+		fmt.Fprintf(out, "Известные предметы: объединено синонимов — %d\n", merged)
+	}
+	st.KnownItems = known
+	if err := st.save(); err != nil {
+		return err
+	}
+
+	if *siteDir == "" {
+		fmt.Fprintln(out, "site_dir не задан — пересчитано только состояние.")
+		return nil
+	}
+	ctx, stop := runContext(cfg)
+	defer stop()
+	sales, err := loadLatestSales(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*siteDir, 0o755); err != nil {
+		return fmt.Errorf("не удалось создать %s: %w", *siteDir, err)
+	}
+	now := time.Now()
+	data, err := buildSiteData(sales, nil, cfg, now)
+	if err != nil {
+		return err
+	}
+	if prev := loadSiteSnapshot(*siteDir, cfg); prev != nil {
+		printRecomputeDiff(prev, &data)
+		data.Changes = diffSiteSnapshots(prev, &data)
+	}
+	if err := renderSite(*siteDir, data, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Отчёт в %s пересобран из экспорта.\n", *siteDir)
+	return nil
+}
+
+func printRecomputeDiff(prev, cur *siteData) {
+	before, after := characterRevenue(prev), characterRevenue(cur)
+	var chars []siteDelta
+	for key, d := range after {
+		d.Before = before[key].After
+		delete(before, key)
+		if d.Before != d.After {
+			chars = append(chars, d)
+		}
+	}
+	for _, d := range before {
+		d.Before, d.After = d.After, 0
+		chars = append(chars, d)
+	}
+
+	prevItems := make(map[string]Money)
+	for _, it := range prev.Totals {
+		prevItems[it.Name] = it.Sum
+	}
+	var items []siteDelta
+	for _, it := range cur.Totals {
+		if old := prevItems[it.Name]; old != it.Sum {
+			items = append(items, siteDelta{Name: it.Name, Before: old.Float(), After: it.Sum.Float()})
+		}
+		delete(prevItems, it.Name)
+	}
+	for name, old := range prevItems {
+		items = append(items, siteDelta{Name: name, Before: old.Float()})
+	}
+
+	if len(chars) == 0 && len(items) == 0 {
+		fmt.Fprintln(out, "Цифры не изменились.")
+		return
+	}
+	printDeltaTable("Выручка персонажей", "Персонаж", chars)
+	printDeltaTable("Выручка по предметам", "Предмет", items)
+}
+
+func printDeltaTable(title, column string, deltas []siteDelta) {
+	if len(deltas) == 0 {
+		return
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Name < deltas[j].Name })
+	fmt.Fprintf(out, "\n%s:\n", title)
+	w := newTable()
+	fmt.Fprintf(w, "    %s\tБыло\tСтало\tРазница\n", column)
+	for _, d := range deltas {
+		fmt.Fprintf(w, "    %s\t$%.2f\t$%.2f\t%+.2f\n", d.Name, d.Before, d.After, d.After-d.Before)
+	}
+	w.Flush()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type characterName struct {
+	Name      string    `json:"name"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+func characterKey(server, id string) string {
+	return server + "/" + id
+}
+
+func observeName(names []characterName, name string, t time.Time) []characterName {
+	for i := range names {
+		if names[i].Name != name {
+			continue
+		}
+		if t.Before(names[i].FirstSeen) {
+			names[i].FirstSeen = t
+		}
+		if t.After(names[i].LastSeen) {
+			names[i].LastSeen = t
+		}
+		return names
+	}
+	return append(names, characterName{Name: name, FirstSeen: t, LastSeen: t})
+}
+
+func recordCharacterNames(st *appState, sales []Sale) {
+	if st.CharacterNames == nil {
+		st.CharacterNames = make(map[string][]characterName)
+	}
+	for _, s := range sales {
+		name, id := splitCharacter(s.Character)
+		if id == "" || name == "" {
+			continue
+		}
+		key := characterKey(s.Server, id)
+		st.CharacterNames[key] = observeName(st.CharacterNames[key], name, s.Time)
+	}
+	for _, names := range st.CharacterNames {
+		sort.Slice(names, func(i, j int) bool { return names[i].FirstSeen.Before(names[j].FirstSeen) })
+	}
+}
+
+func nameHistoryAsOf(history map[string][]characterName, now time.Time) map[string][]characterName {
+	res := make(map[string][]characterName, len(history))
+	for key, names := range history {
+		for _, n := range names {
+			if n.FirstSeen.After(now) {
+				continue
+			}
+			if n.LastSeen.After(now) {
+				n.LastSeen = now
+			}
+			res[key] = append(res[key], n)
+		}
+	}
+	return res
+}
+
+func printNameHistory(names []characterName, lang string) {
+	if len(names) < 2 {
+		return
+	}
+	parts := make([]string, len(names))
+	for i, n := range names {
+		parts[i] = fmt.Sprintf("%s (%s – %s)", n.Name, formatDate(n.FirstSeen, lang), formatDate(n.LastSeen, lang))
+	}
+	fmt.Fprintf(out, "  История имён: %s\n", strings.Join(parts, ", "))
+}
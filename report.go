@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type reportOptions struct {
+	periods     []period
+	wide        bool
+	trend       bool
+	leaderboard *period
+	compare     *period
+	names       map[string][]characterName
+}
+
+func printReport(sales []Sale, history historySales, purchases []Purchase, trades []Trade, cfg *Config, opts reportOptions, now time.Time) error {
+	periods := uniquePeriods(append([]period{allTime}, opts.periods...))
+	aggByPeriod := aggregatePeriods(sales, periods, now)
+	first, last, ok := salesRange(sales)
+	itemsSet := make(map[string]struct{})
+	for _, s := range sales {
+		itemsSet[s.Item] = struct{}{}
+	}
+	err := history.each(func(s Sale) error {
+		addPeriodSale(aggByPeriod, periods, s, now)
+		first, last, ok = extendRange(first, last, ok, s.Time)
+		itemsSet[s.Item] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать сброшенные на диск продажи: %w", err)
+	}
+
+	fmt.Fprintf(out, "Отчёт сформирован: %s\n", formatDateTime(now, cfg.Language))
+	if ok {
+		fmt.Fprintf(out, "Данные о продажах: с %s по %s\n", formatDate(first, cfg.Language), formatDate(last, cfg.Language))
+	}
+	for _, p := range periods {
+		addPurchases(aggByPeriod[p.name], purchases, now, p)
+		addTrades(aggByPeriod[p.name], trades, now, p)
+	}
+	all := aggByPeriod[allTime.name]
+	prevByPeriod := make(map[string]map[string]*Server)
+	if opts.trend {
+		for _, p := range opts.periods {
+			if prevNow, ok := p.previous(now); ok {
+				prevByPeriod[p.name] = aggregateSales(salesAsOf(sales, prevNow), prevNow, p)
+			}
+		}
+	}
+
+	for _, srvName := range sortedServerKeys(all, cfg.Language) {
+		fmt.Fprintf(out, "\nСервер: %s\n", srvName)
+		for _, charID := range sortedCharIDs(all[srvName], cfg.Language) {
+			chAll := all[srvName].Characters[charID]
+			fmt.Fprintf(out, "Персонаж %s #%s:\n", chAll.Name, chAll.ID)
+			printNameHistory(opts.names[characterKey(srvName, chAll.ID)], cfg.Language)
+			if opts.wide {
+				printWideCharacterStats(aggByPeriod, opts.periods, srvName, charID, cfg.selected)
+				continue
+			}
+			for _, p := range opts.periods {
+				fmt.Fprintf(out, "  -- %s --\n", p.name)
+				ch := periodCharacter(aggByPeriod[p.name], srvName, charID)
+				if ch == nil {
+					fmt.Fprintln(out, "    (нет данных)")
+					continue
+				}
+				var prev *Character
+				if agg, ok := prevByPeriod[p.name]; ok {
+					if prev = periodCharacter(agg, srvName, charID); prev == nil {
+						prev = &Character{}
+					}
+				}
+				printCharacterItemStats(ch, prev, cfg.selected, cfg.Language)
+			}
+		}
+	}
+
+	if opts.compare != nil {
+		printServerComparison(aggregateSales(sales, now, *opts.compare), *opts.compare, cfg)
+	}
+	if opts.leaderboard != nil {
+		printLeaderboard(aggregateSales(sales, now, *opts.leaderboard), *opts.leaderboard, cfg, now)
+	}
+
+	fmt.Fprintln(out, "\nСписок всех проданных предметов:")
+	var allItems []string
+	for it := range itemsSet {
+		allItems = append(allItems, it)
+	}
+	sortNames(allItems, cfg.Language)
+	shown, hidden := topRows(allItems, cfg.TopN)
+	for _, it := range shown {
+		fmt.Fprintln(out, " -", it)
+	}
+	printMoreRows(hidden, cfg)
+	return nil
+}
+
+func topRows[T any](rows []T, n int) ([]T, int) {
+	if n <= 0 || len(rows) <= n {
+		return rows, 0
+	}
+	return rows[:n], len(rows) - n
+}
+
+func fullListLink(cfg *Config) string {
+	switch {
+	case cfg.Publish != nil && cfg.Publish.PublicURL != "":
+		return strings.TrimRight(cfg.Publish.PublicURL, "/") + "/data.json"
+	case cfg.SiteDir != "":
+		return filepath.Join(cfg.SiteDir, "data.json")
+	}
+	return ""
+}
+
+func moreRowsNote(hidden int, cfg *Config) string {
+	if link := fullListLink(cfg); link != "" {
+		return fmt.Sprintf("…и ещё %d (полный список — %s)", hidden, link)
+	}
+	return fmt.Sprintf("…и ещё %d (полный список — в data.json, см. --site)", hidden)
+}
+
+func printMoreRows(hidden int, cfg *Config) {
+	if hidden > 0 {
+		fmt.Fprintln(out, " ", moreRowsNote(hidden, cfg))
+	}
+}
+
+func periodCharacter(agg map[string]*Server, srvName, charID string) *Character {
+	srv := agg[srvName]
+	if srv == nil {
+		return nil
+	}
+	return srv.Characters[charID]
+}
+
+func printWideCharacterStats(aggByPeriod map[string]map[string]*Server, periods []period, srvName, charID string, selected []string) {
+	chars := make([]*Character, len(periods))
+	for i, p := range periods {
+		chars[i] = periodCharacter(aggByPeriod[p.name], srvName, charID)
+	}
+
+	w := newTable()
+	fmt.Fprint(w, "Тип предмета")
+	for _, p := range periods {
+		fmt.Fprintf(w, "\t%s: кол-во\t%s: сумма", p.name, p.name)
+	}
+	fmt.Fprintln(w)
+	for _, item := range selected {
+		fmt.Fprint(w, item)
+		for _, ch := range chars {
+			if ch == nil || ch.Items[item] == nil {
+				fmt.Fprint(w, "\t-\t-")
+				continue
+			}
+			d := ch.Items[item]
+			fmt.Fprintf(w, "\t%d\t$%.2f", d.Count, d.Sum)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprint(w, "Всего")
+	for _, ch := range chars {
+		var sum Money
+		if ch != nil {
+			sum = ch.Revenue()
+		}
+		fmt.Fprintf(w, "\t\t$%.2f", sum)
+	}
+	fmt.Fprintln(w)
+	w.Flush()
+}
+
+func trendMark(before, after float64) string {
+	switch {
+	case before == 0 && after == 0:
+		return "="
+	case before == 0:
+		return "▲ новое"
+	case after > before:
+		return fmt.Sprintf("▲ +%.0f%%", percentChange(before, after))
+	case after < before:
+		return fmt.Sprintf("▼ %.0f%%", percentChange(before, after))
+	}
+	return "= 0%"
+}
+
+func printCharacterItemStats(ch, prev *Character, selected []string, lang string) {
+	fees := ch.Fees()
+	row := func(w *tableWriter, name string, d *ItemStats) {
+		fmt.Fprintf(w, "%s\t%d\t$%.2f\t$%.2f", name, d.Count, d.Sum, d.average())
+		if fees > 0 {
+			fmt.Fprintf(w, "\t$%.2f\t$%.2f", d.Fees, d.Sum-d.Fees)
+		}
+		fmt.Fprintln(w)
+	}
+	w := newTable()
+	if fees > 0 {
+		fmt.Fprintln(w, "Тип предмета\tКол-во\tСумма продаж\tСредняя цена\tКомиссия\tЧистыми")
+	} else {
+		fmt.Fprintln(w, "Тип предмета\tКол-во\tСумма продаж\tСредняя цена")
+	}
+	for _, item := range selected {
+		d := ch.Items[item]
+		if d == nil {
+			continue
+		}
+		row(w, item, d)
+		if prev != nil {
+			was := prev.Items[item]
+			if was == nil {
+				was = &ItemStats{}
+			}
+			fmt.Fprintf(w, "\t%s\t%s\t%s\n", trendMark(float64(was.Count), float64(d.Count)), trendMark(was.Sum.Float(), d.Sum.Float()), trendMark(was.average(), d.average()))
+		}
+		qualities := make([]string, 0, len(d.Qualities))
+		for q := range d.Qualities {
+			qualities = append(qualities, q)
+		}
+		sortNames(qualities, lang)
+		for _, q := range qualities {
+			row(w, "  └ "+q, d.Qualities[q])
+		}
+	}
+	w.Flush()
+
+	var sumSel Money
+	for _, item := range selected {
+		if d := ch.Items[item]; d != nil {
+			sumSel += d.Sum
+		}
+	}
+	fmt.Fprintf(out, "    Сумма продаж выбранных позиций: $%.2f\n", sumSel)
+	fmt.Fprintf(out, "    Общая сумма продаж:             $%.2f\n", ch.Revenue())
+	if ch.Foreign > 0 {
+		fmt.Fprintf(out, "    Из них чужие товары:            $%.2f\n", ch.Foreign)
+	}
+	if fees > 0 {
+		fmt.Fprintf(out, "    Комиссия рынка:                 $%.2f\n", fees)
+		fmt.Fprintf(out, "    Выручка за вычетом комиссии:    $%.2f\n", ch.Revenue()-fees)
+	}
+	if len(ch.Bought) > 0 || ch.Trades > 0 {
+		printBuySellStats(ch, lang)
+	}
+}
+
+func printBuySellStats(ch *Character, lang string) {
+	if len(ch.Bought) > 0 {
+		fmt.Fprintf(out, "    Потрачено на покупки:           $%.2f\n", ch.Spent)
+	}
+	if ch.Trades > 0 {
+		fmt.Fprintf(out, "    Сделки с игроками (%d):          получено $%.2f, отдано $%.2f\n", ch.Trades, ch.TradeReceived, ch.TradePaid)
+	}
+	fmt.Fprintf(out, "    Чистый доход:                   $%.2f\n", ch.Revenue()-ch.Fees()-ch.Spent+ch.TradeReceived-ch.TradePaid)
+	if len(ch.Bought) == 0 {
+		return
+	}
+
+	items := make([]string, 0, len(ch.Bought))
+	for it := range ch.Bought {
+		items = append(items, it)
+	}
+	sortNames(items, lang)
+	w := newTable()
+	fmt.Fprintln(w, "    Покупка / продажа\tКуплено\tСр. цена покупки\tПродано\tСр. цена продажи\tНаценка")
+	for _, it := range items {
+		b := ch.Bought[it]
+		sold, sellAvg, margin := "-", "-", "-"
+		if d := ch.Items[it]; d != nil && d.Count > 0 {
+			sold = fmt.Sprint(d.Count)
+			sellAvg = fmt.Sprintf("$%.2f", d.average())
+			if b.average() > 0 {
+				margin = fmt.Sprintf("%+.1f%%", (d.average()/b.average()-1)*100)
+			}
+		}
+		fmt.Fprintf(w, "    %s\t%d\t$%.2f\t%s\t%s\t%s\n", it, b.Count, b.average(), sold, sellAvg, margin)
+	}
+	w.Flush()
+}
+
+func printUnparsed(unparsed []parseAnomaly, cfg *Config) {
+	if len(unparsed) == 0 {
+		fmt.Fprintln(out, "Неразобранных сообщений нет: все сообщения с фразами бота распознаны.")
+		return
+	}
+	fmt.Fprintf(out, "Неразобранные сообщения: %d с фразой бота не подошли под формат и пропущены\n", len(unparsed))
+	for _, a := range unparsed {
+		fmt.Fprintf(out, "  %s [%s]\n    %s\n", formatDateTime(a.Time, cfg.Language), a.Reason, strings.Join(strings.Fields(a.Text), " "))
+	}
+	fmt.Fprintln(out)
+}
+
+func writeUnparsed(path string, unparsed []parseAnomaly, cfg *Config) error {
+	var b strings.Builder
+	for _, a := range unparsed {
+		fmt.Fprintf(&b, "%s [%s]\n%s\n\n", formatDateTime(a.Time, cfg.Language), a.Reason, strings.TrimSpace(a.Text))
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("не удалось записать %s: %w", path, err)
+	}
+	fmt.Fprintf(out, "Неразобранные сообщения (%d) записаны в %s\n", len(unparsed), path)
+	return nil
+}
+
+func printAnomalies(anomalies []parseAnomaly, cfg *Config) {
+	if len(anomalies) == 0 {
+		return
+	}
+	const maxShown = 10
+	fmt.Fprintf(out, "Аномалии разбора: %d сообщений не учтено в статистике\n", len(anomalies))
+	for i, a := range anomalies {
+		if i == maxShown {
+			fmt.Fprintf(out, "  …и ещё %d\n", len(anomalies)-maxShown)
+			break
+		}
+		fmt.Fprintf(out, "  %s: %s\n    %s\n", formatDateTime(a.Time, cfg.Language), a.Reason, strings.Join(strings.Fields(a.Text), " "))
+	}
+	fmt.Fprintln(out)
+}
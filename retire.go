@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const characterUsage = `Использование:
+  market character list
+  market character retire <ID>
+  market character unretire <ID>
+
+ID — число после «#» в имени персонажа; можно указать и «Имя #ID».`
+
+func retiredID(s string) string {
+	if _, id := splitCharacter(strings.TrimSpace(s)); id != "" {
+		return id
+	}
+	return strings.TrimPrefix(strings.TrimSpace(s), "#")
+}
+
+func (cfg *Config) isRetired(character string) bool {
+	if len(cfg.retired) == 0 {
+		return false
+	}
+	name, id := splitCharacter(character)
+	if id == "" {
+		id = name
+	}
+	return cfg.retired[id]
+}
+
+func withoutRetired[T any](cfg *Config, rows []T, character func(T) string) ([]T, int) {
+	if len(cfg.retired) == 0 {
+		return rows, 0
+	}
+	kept := make([]T, 0, len(rows))
+	for _, r := range rows {
+		if !cfg.isRetired(character(r)) {
+			kept = append(kept, r)
+		}
+	}
+	return kept, len(rows) - len(kept)
+}
+
+func cmdCharacter(args []string) error {
+	if len(args) == 0 {
+		return errors.New(characterUsage)
+	}
+	cfg, err := readConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		if len(cfg.Retired) == 0 {
+			fmt.Fprintln(out, "Персонажей на покое нет")
+			return nil
+		}
+		fmt.Fprintln(out, "На покое (не попадают в отчёты без --include-retired):")
+		for _, id := range cfg.Retired {
+			fmt.Fprintf(out, " - #%s\n", retiredID(id))
+		}
+		return nil
+	case "retire":
+		if len(args) != 2 || retiredID(args[1]) == "" {
+			return errors.New(characterUsage)
+		}
+		id := retiredID(args[1])
+		if slices.ContainsFunc(cfg.Retired, func(s string) bool { return retiredID(s) == id }) {
+			fmt.Fprintf(out, "Персонаж #%s уже на покое\n", id)
+			return nil
+		}
+		cfg.Retired = append(cfg.Retired, id)
+	case "unretire":
+		if len(args) != 2 {
+			return errors.New(characterUsage)
+		}
+		id := retiredID(args[1])
+		i := slices.IndexFunc(cfg.Retired, func(s string) bool { return retiredID(s) == id })
+		if i < 0 {
+			return fmt.Errorf("персонажа #%s нет в списке retired", id)
+		}
+		cfg.Retired = slices.Delete(cfg.Retired, i, i+1)
+	default:
+		return errors.New(characterUsage)
+	}
+
+	if err := saveConfig(configPath, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Настройки сохранены")
+	return nil
+}
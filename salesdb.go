@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const salesDBSchema = `
+CREATE TABLE IF NOT EXISTS sales (
+	key          TEXT PRIMARY KEY,
+	msg_id       BIGINT NOT NULL,
+	time         TEXT NOT NULL,
+	server       TEXT NOT NULL,
+	character    TEXT NOT NULL,
+	character_id TEXT NOT NULL,
+	item         TEXT NOT NULL,
+	raw_item     TEXT NOT NULL,
+	quality      TEXT NOT NULL,
+	owner        TEXT NOT NULL,
+	counterparty TEXT NOT NULL,
+	quantity     INTEGER NOT NULL,
+	price        BIGINT NOT NULL,
+	fee          BIGINT NOT NULL,
+	machine      TEXT NOT NULL DEFAULT '',
+	first_seen   TEXT NOT NULL,
+	updated      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sales_time ON sales (time);
+CREATE INDEX IF NOT EXISTS sales_character ON sales (server, character_id);
+`
+
+const salesDBColumns = "msg_id, time, server, character, item, raw_item, quality, owner, counterparty, quantity, price, fee"
+
+type salesDB struct {
+	*sql.DB
+	postgres bool
+}
+
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+func salesDBName(dsn string) string {
+	if !isPostgresDSN(dsn) {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil {
+		return u.Redacted()
+	}
+	return "postgres"
+}
+
+func openSalesDB(dsn string) (*salesDB, error) {
+	db := &salesDB{postgres: isPostgresDSN(dsn)}
+	var err error
+	if db.postgres {
+		db.DB, err = sql.Open("postgres", dsn)
+	} else {
+		db.DB, err = sql.Open("sqlite", "file:"+dsn+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(salesDBSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", salesDBName(dsn), err)
+	}
+	if _, err := db.Exec("SELECT machine FROM sales WHERE 1 = 0"); err != nil {
+		if _, err := db.Exec("ALTER TABLE sales ADD COLUMN machine TEXT NOT NULL DEFAULT ''"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %w", salesDBName(dsn), err)
+		}
+	}
+	return db, nil
+}
+
+func (db *salesDB) rebind(query string) string {
+	if !db.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func machineName() string {
+	if name, err := os.Hostname(); err == nil {
+		return name
+	}
+	return ""
+}
+
+const salesDBUpdate = `msg_id = excluded.msg_id, item = excluded.item, quality = excluded.quality, owner = excluded.owner,
+	counterparty = excluded.counterparty, fee = excluded.fee, updated = excluded.updated`
+
+const salesDBChanged = `(sales.msg_id <> excluded.msg_id OR sales.item <> excluded.item OR sales.quality <> excluded.quality
+	OR sales.owner <> excluded.owner OR sales.counterparty <> excluded.counterparty OR sales.fee <> excluded.fee)`
+
+// upsertSales writes sales and returns how many of them were new rows.
+// Postgres reports that per row through xmax; SQLite runs the insert with
+// DO NOTHING first and reads changes() from RowsAffected, updating the row
+// only when that inserted nothing.
+func upsertSales(ctx context.Context, db *salesDB, sales []Sale, now time.Time) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	insert := `INSERT INTO sales (key, ` + salesDBColumns + `, character_id, machine, first_seen, updated)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	var upsert string
+	if db.postgres {
+		insert += ` ON CONFLICT (key) DO UPDATE SET ` + salesDBUpdate + ` WHERE ` + salesDBChanged + ` RETURNING (xmax = 0)`
+	} else {
+		upsert = insert + ` ON CONFLICT (key) DO UPDATE SET ` + salesDBUpdate + ` WHERE ` + salesDBChanged
+		insert += ` ON CONFLICT (key) DO NOTHING`
+	}
+	stmt, err := tx.PrepareContext(ctx, db.rebind(insert))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	var update *sql.Stmt
+	if upsert != "" {
+		if update, err = tx.PrepareContext(ctx, upsert); err != nil {
+			return 0, err
+		}
+		defer update.Close()
+	}
+
+	stamp, machine := now.UTC().Format(time.RFC3339), machineName()
+	keys := storedSaleKeys(sales)
+	inserted := 0
+	for i, s := range sales {
+		_, id := splitCharacter(s.Character)
+		args := []any{keys[i], s.MsgID, s.Time.UTC().Format(time.RFC3339), s.Server, s.Character, s.Item, s.RawItem,
+			s.Quality, s.Owner, s.Counterparty, s.Quantity, int64(s.Price), int64(s.Fee), id, machine, stamp, stamp}
+		if db.postgres {
+			var isNew bool
+			err := stmt.QueryRowContext(ctx, args...).Scan(&isNew)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			if isNew {
+				inserted++
+			}
+			continue
+		}
+		res, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return 0, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return 0, err
+		} else if n > 0 {
+			inserted++
+			continue
+		}
+		if _, err := update.ExecContext(ctx, args...); err != nil {
+			return 0, err
+		}
+	}
+	return inserted, tx.Commit()
+}
+
+func loadSalesDB(ctx context.Context, db *salesDB) ([]Sale, error) {
+	_, sales, err := querySalesDB(ctx, db, "")
+	return sales, err
+}
+
+func querySalesDB(ctx context.Context, db *salesDB, cond string, args ...any) (keys []string, sales []Sale, err error) {
+	err = eachSalesDBRow(ctx, db, cond, args, func(key string, s Sale) error {
+		keys = append(keys, key)
+		sales = append(sales, s)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, sales, nil
+}
+
+func eachSalesDBRow(ctx context.Context, db *salesDB, cond string, args []any, fn func(key string, s Sale) error) error {
+	query := "SELECT key, " + salesDBColumns + " FROM sales"
+	if cond != "" {
+		query += " WHERE " + cond
+	}
+	rows, err := db.QueryContext(ctx, db.rebind(query+" ORDER BY time, key"), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s Sale
+		var key, t string
+		var price, fee int64
+		if err := rows.Scan(&key, &s.MsgID, &t, &s.Server, &s.Character, &s.Item, &s.RawItem, &s.Quality, &s.Owner, &s.Counterparty, &s.Quantity, &price, &fee); err != nil {
+			return err
+		}
+		if s.Time, err = time.Parse(time.RFC3339, t); err != nil {
+			return fmt.Errorf("неверное время %q: %w", t, err)
+		}
+		s.Time = s.Time.In(time.Local)
+		s.Price, s.Fee = Money(price), Money(fee)
+		if err := fn(key, s); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type salesDBRow struct {
+	key         string
+	characterID string
+	sale        Sale
+	err         error
+}
+
+// readSalesDBRows returns every row as stored, keeping the ones whose time
+// does not parse, so verify can report them.
+func readSalesDBRows(ctx context.Context, dsn string) ([]salesDBRow, error) {
+	db, err := openSalesDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(ctx, "SELECT key, character_id, "+salesDBColumns+" FROM sales ORDER BY key")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []salesDBRow
+	for rows.Next() {
+		var r salesDBRow
+		var t string
+		var price, fee int64
+		s := &r.sale
+		if err := rows.Scan(&r.key, &r.characterID, &s.MsgID, &t, &s.Server, &s.Character, &s.Item, &s.RawItem, &s.Quality, &s.Owner, &s.Counterparty, &s.Quantity, &price, &fee); err != nil {
+			return nil, err
+		}
+		if s.Time, err = time.Parse(time.RFC3339, t); err != nil {
+			r.err = fmt.Errorf("неверное время %q", t)
+		}
+		s.Time = s.Time.In(time.Local)
+		s.Price, s.Fee = Money(price), Money(fee)
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// mergeSalesDB stores the parsed sales and adds the rows missing from the
+// exports to res.Sales, or to history when it is set.
+func mergeSalesDB(ctx context.Context, cfg *Config, res *parseResult, through time.Time, purged purgeLog, history *saleSpool) (stored, added int, err error) {
+	if cfg.SalesDB == "" {
+		return 0, 0, nil
+	}
+	db, err := openSalesDB(cfg.SalesDB)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+	if stored, err = upsertSales(ctx, db, retainedSales(res.Sales, through, purged), time.Now()); err != nil {
+		return 0, 0, fmt.Errorf("не удалось записать продажи в %s: %w", salesDBName(cfg.SalesDB), err)
+	}
+	seen := make(map[string]int, len(res.Sales))
+	for _, s := range res.Sales {
+		seen[contentKey(s)]++
+	}
+	err = eachSalesDBRow(ctx, db, "", nil, func(_ string, s Sale) error {
+		if k := contentKey(s); seen[k] > 0 {
+			seen[k]--
+			return nil
+		}
+		s.Item = cfg.canonicalItem(s.Item)
+		added++
+		if history != nil {
+			return history.Add(s)
+		}
+		res.Sales = append(res.Sales, s)
+		return nil
+	})
+	if err != nil {
+		return stored, 0, fmt.Errorf("не удалось прочитать %s: %w", salesDBName(cfg.SalesDB), err)
+	}
+	if added > 0 && history == nil {
+		sortSalesByTime(res.Sales)
+	}
+	return stored, added, nil
+}
+
+func runSalesQuery(cfg *Config, query string) error {
+	if cfg.SalesDB == "" {
+		return errors.New("база продаж не настроена: укажите sales_db в config.json")
+	}
+	if _, err := os.Stat(cfg.SalesDB); err != nil && !isPostgresDSN(cfg.SalesDB) {
+		return fmt.Errorf("база продаж %s ещё не создана — запустите отчёт: %w", cfg.SalesDB, err)
+	}
+	db, err := openSalesDB(cfg.SalesDB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rows, err := db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintln(w, strings.Join(cols, "\t"))
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		cells := make([]string, len(values))
+		for i, v := range values {
+			switch v := v.(type) {
+			case nil:
+				cells[i] = "NULL"
+			case []byte:
+				cells[i] = string(v)
+			case float64:
+				cells[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				cells[i] = fmt.Sprint(v)
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Строк: %d\n", n)
+	return nil
+}
+
+type salesDBPurge struct {
+	path string
+}
+
+func (p salesDBPurge) Name() string { return salesDBName(p.path) }
+
+func (p salesDBPurge) Purge(f purgeFilter, dryRun bool) (int, error) {
+	if f.character == "" && f.before.IsZero() {
+		return 0, nil
+	}
+	if _, err := os.Stat(p.path); errors.Is(err, os.ErrNotExist) && !isPostgresDSN(p.path) {
+		return 0, nil
+	}
+	db, err := openSalesDB(p.path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	where, args := []string{}, []any{}
+	if f.character != "" {
+		where = append(where, "character_id = ?")
+		args = append(args, f.character)
+	}
+	if !f.before.IsZero() {
+		where = append(where, "time < ?")
+		args = append(args, f.before.UTC().Format(time.RFC3339))
+	}
+	cond := strings.Join(where, " AND ")
+	if dryRun {
+		var n int
+		err := db.QueryRow(db.rebind("SELECT count(*) FROM sales WHERE "+cond), args...).Scan(&n)
+		return n, err
+	}
+	r, err := db.Exec(db.rebind("DELETE FROM sales WHERE "+cond), args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	return int(n), err
+}
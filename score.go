@@ -0,0 +1,59 @@
+package main
+
+type ScoreWeights struct {
+	Revenue     float64 `json:"revenue"`
+	Velocity    float64 `json:"velocity"`
+	Diversity   float64 `json:"diversity"`
+	Consistency float64 `json:"consistency"`
+}
+
+var defaultScoreWeights = ScoreWeights{Revenue: 0.4, Velocity: 0.3, Diversity: 0.15, Consistency: 0.15}
+
+func (cfg *Config) scoreWeights() ScoreWeights {
+	if cfg.ScoreWeights == nil {
+		return defaultScoreWeights
+	}
+	return *cfg.ScoreWeights
+}
+
+func characterMetrics(ch *Character) [4]float64 {
+	var velocity, consistency float64
+	if len(ch.Days) > 0 {
+		velocity = float64(ch.Sales) / float64(len(ch.Days))
+		span := int(ch.LastSeen.Sub(ch.FirstSeen).Hours()/24) + 1
+		consistency = float64(len(ch.Days)) / float64(max(span, len(ch.Days)))
+	}
+	return [4]float64{ch.Revenue().Float(), velocity, float64(len(ch.Items)), consistency}
+}
+
+func efficiencyScores(chars []*Character, w ScoreWeights) map[*Character]float64 {
+	weights := [4]float64{w.Revenue, w.Velocity, w.Diversity, w.Consistency}
+	var maxes [4]float64
+	metrics := make(map[*Character][4]float64, len(chars))
+	for _, ch := range chars {
+		m := characterMetrics(ch)
+		metrics[ch] = m
+		for i := range m {
+			maxes[i] = max(maxes[i], m[i])
+		}
+	}
+	var totalWeight float64
+	for _, wt := range weights {
+		totalWeight += wt
+	}
+
+	scores := make(map[*Character]float64, len(chars))
+	if totalWeight <= 0 {
+		return scores
+	}
+	for ch, m := range metrics {
+		var s float64
+		for i := range m {
+			if maxes[i] > 0 {
+				s += weights[i] * m[i] / maxes[i]
+			}
+		}
+		scores[ch] = s / totalWeight * 100
+	}
+	return scores
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type siteData struct {
+	GeneratedAt time.Time    `json:"generated_at"`
+	FirstSale   time.Time    `json:"first_sale"`
+	LastSale    time.Time    `json:"last_sale"`
+	Servers     []siteServer `json:"servers"`
+	Items       []string     `json:"items"`
+	Totals      []siteItem   `json:"totals,omitempty"`
+	Changes     *siteChanges `json:"changes,omitempty"`
+
+	Dashboards []siteDashboard `json:"dashboards,omitempty"`
+}
+
+type siteServer struct {
+	Name       string          `json:"name"`
+	Characters []siteCharacter `json:"characters"`
+}
+
+type siteCharacter struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	Periods []sitePeriod `json:"periods"`
+}
+
+type sitePeriod struct {
+	Name    string     `json:"name"`
+	Revenue Money      `json:"revenue"`
+	Fees    Money      `json:"fees,omitempty"`
+	Sales   int        `json:"sales"`
+	Items   []siteItem `json:"items"`
+}
+
+type siteItem struct {
+	Name  string  `json:"name"`
+	Count int     `json:"count"`
+	Sum   Money   `json:"sum"`
+	Avg   float64 `json:"avg"`
+	Fees  Money   `json:"fees,omitempty"`
+}
+
+func buildSiteData(sales []Sale, history historySales, cfg *Config, now time.Time) (siteData, error) {
+	data := siteData{GeneratedAt: now}
+	first, last, ok := salesRange(sales)
+
+	periods := cfg.exportPeriods()
+	aggByPeriod := aggregatePeriods(sales, periods, now)
+	itemsSet := make(map[string]struct{})
+	byItem := make(map[string]*ItemStats)
+	for _, s := range sales {
+		itemsSet[s.Item] = struct{}{}
+		addItemTotal(byItem, s)
+	}
+	err := history.each(func(s Sale) error {
+		addPeriodSale(aggByPeriod, periods, s, now)
+		first, last, ok = extendRange(first, last, ok, s.Time)
+		itemsSet[s.Item] = struct{}{}
+		addItemTotal(byItem, s)
+		return nil
+	})
+	if err != nil {
+		return data, fmt.Errorf("не удалось прочитать сброшенные на диск продажи: %w", err)
+	}
+	if ok {
+		data.FirstSale, data.LastSale = first, last
+	}
+	all := aggByPeriod[allTime.name]
+	for _, srvName := range sortedServerKeys(all, cfg.Language) {
+		srv := siteServer{Name: srvName}
+		for _, charID := range sortedCharIDs(all[srvName], cfg.Language) {
+			chAll := all[srvName].Characters[charID]
+			sc := siteCharacter{ID: chAll.ID, Name: chAll.Name}
+			for _, p := range periods {
+				sp := sitePeriod{Name: p.name}
+				if ch := periodCharacter(aggByPeriod[p.name], srvName, charID); ch != nil {
+					sp.Revenue = ch.Revenue()
+					sp.Fees = ch.Fees()
+					sp.Sales = ch.Sales
+					for _, item := range cfg.selected {
+						d := ch.Items[item]
+						if d == nil {
+							continue
+						}
+						si := siteItem{Name: item, Count: d.Count, Sum: d.Sum, Fees: d.Fees}
+						if d.Count > 0 {
+							si.Avg = d.average()
+						}
+						sp.Items = append(sp.Items, si)
+					}
+				}
+				sc.Periods = append(sc.Periods, sp)
+			}
+			srv.Characters = append(srv.Characters, sc)
+		}
+		data.Servers = append(data.Servers, srv)
+	}
+
+	for it := range itemsSet {
+		data.Items = append(data.Items, it)
+	}
+	sortNames(data.Items, cfg.Language)
+	data.Totals = siteTotals(byItem)
+	data.Dashboards = buildDashboards(sales, cfg, now)
+	return data, nil
+}
+
+func writeSite(dir string, sales []Sale, history historySales, cfg *Config, now time.Time) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("не удалось создать %s: %w", dir, err)
+	}
+	data, err := buildSiteData(sales, history, cfg, now)
+	if err != nil {
+		return err
+	}
+	if prev := loadSiteSnapshot(dir, cfg); prev != nil {
+		data.Changes = diffSiteSnapshots(prev, &data)
+	}
+	return renderSite(dir, data, cfg)
+}
+
+func renderSite(dir string, data siteData, cfg *Config) error {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	var page bytes.Buffer
+	if err := siteTemplate(cfg.Language, cfg.TopN).Execute(&page, data); err != nil {
+		return err
+	}
+	html := page.Bytes()
+	if password := cfg.sitePassword(); password != "" {
+		if html, raw, err = lockSite(password, html, raw); err != nil {
+			return fmt.Errorf("не удалось зашифровать отчёт: %w", err)
+		}
+	} else if siteLocked(dir) {
+		return errSiteLocked
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, "data.json"), raw, 0o644); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, "index.html"), html, 0o644)
+}
+
+func siteTemplate(lang string, topN int) *template.Template {
+	return template.Must(template.New("site.html").Funcs(template.FuncMap{
+		"top": func(rows []string) []string {
+			shown, _ := topRows(rows, topN)
+			return shown
+		},
+		"more": func(rows []string) int {
+			_, hidden := topRows(rows, topN)
+			return hidden
+		},
+		"date":     func(t time.Time) string { return formatDate(t, lang) },
+		"datetime": func(t time.Time) string { return formatDateTime(t, lang) },
+		"money":    func(v any) string { return fmt.Sprintf("$%.2f", v) },
+		"percent":  func(v float64) string { return fmt.Sprintf("%+.1f%%", v) },
+	}).ParseFS(assets, "assets/site.html"))
+}
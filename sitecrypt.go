@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+)
+
+const (
+	siteCipher         = "AES-256-GCM/PBKDF2-SHA256"
+	siteKeyIterations  = 600000
+	sitePasswordEnvVar = "MARKET_SITE_PASSWORD"
+)
+
+var errSiteLocked = errors.New("data.json зашифрован: укажите site_password в config.json или " + sitePasswordEnvVar)
+
+type sealedSite struct {
+	Cipher     string `json:"cipher"`
+	Iterations int    `json:"iterations"`
+	Salt       string `json:"salt"`
+	IV         string `json:"iv"`
+	Data       string `json:"data"`
+}
+
+type sitePayload struct {
+	HTML string `json:"html"`
+	Data string `json:"data"`
+}
+
+func (c *Config) sitePassword() string {
+	if c.SitePassword != "" {
+		return c.SitePassword
+	}
+	return os.Getenv(sitePasswordEnvVar)
+}
+
+func siteKey(password string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealSite(password string, plain []byte) (sealedSite, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return sealedSite{}, err
+	}
+	aead, err := siteKey(password, salt, siteKeyIterations)
+	if err != nil {
+		return sealedSite{}, err
+	}
+	iv := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return sealedSite{}, err
+	}
+	enc := base64.StdEncoding
+	return sealedSite{
+		Cipher:     siteCipher,
+		Iterations: siteKeyIterations,
+		Salt:       enc.EncodeToString(salt),
+		IV:         enc.EncodeToString(iv),
+		Data:       enc.EncodeToString(aead.Seal(nil, iv, plain, nil)),
+	}, nil
+}
+
+func (s sealedSite) open(password string) ([]byte, error) {
+	if s.Cipher != siteCipher {
+		return nil, fmt.Errorf("неизвестный шифр %q", s.Cipher)
+	}
+	enc := base64.StdEncoding
+	salt, err := enc.DecodeString(s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := enc.DecodeString(s.IV)
+	if err != nil {
+		return nil, err
+	}
+	data, err := enc.DecodeString(s.Data)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := siteKey(password, salt, s.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aead.NonceSize() {
+		return nil, errors.New("повреждённый вектор инициализации")
+	}
+	plain, err := aead.Open(nil, iv, data, nil)
+	if err != nil {
+		return nil, errors.New("неверный пароль или файл повреждён")
+	}
+	return plain, nil
+}
+
+func sealedSiteData(raw []byte) (sealedSite, bool) {
+	var sealed sealedSite
+	if json.Unmarshal(raw, &sealed) != nil || sealed.Cipher == "" {
+		return sealedSite{}, false
+	}
+	return sealed, true
+}
+
+func siteLocked(dir string) bool {
+	raw, err := os.ReadFile(filepath.Join(dir, "data.json"))
+	if err != nil {
+		return false
+	}
+	_, ok := sealedSiteData(raw)
+	return ok
+}
+
+func readSiteJSON(dir string, cfg *Config) ([]byte, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, "data.json"))
+	if err != nil {
+		return nil, err
+	}
+	sealed, ok := sealedSiteData(raw)
+	if !ok {
+		return raw, nil
+	}
+	password := cfg.sitePassword()
+	if password == "" {
+		return nil, errSiteLocked
+	}
+	plain, err := sealed.open(password)
+	if err != nil {
+		return nil, fmt.Errorf("data.json: %w", err)
+	}
+	return plain, nil
+}
+
+func lockSite(password string, page, data []byte) (lockedPage, lockedData []byte, err error) {
+	payload, err := json.Marshal(sitePayload{HTML: string(page), Data: string(data)})
+	if err != nil {
+		return nil, nil, err
+	}
+	sealedPage, err := sealSite(password, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	if err := template.Must(template.ParseFS(assets, "assets/locked.html")).Execute(&buf, sealedPage); err != nil {
+		return nil, nil, err
+	}
+	sealedData, err := sealSite(password, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lockedData, err = json.MarshalIndent(sealedData, "", "  "); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), lockedData, nil
+}
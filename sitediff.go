@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	siteTopItems     = 5
+	siteMoverPercent = 10.0
+	siteMaxMovers    = 10
+)
+
+type siteChanges struct {
+	Since       time.Time   `json:"since"`
+	NewTopItems []string    `json:"new_top_items,omitempty"`
+	Characters  []siteDelta `json:"characters,omitempty"`
+	Prices      []siteDelta `json:"prices,omitempty"`
+}
+
+type siteDelta struct {
+	Name    string  `json:"name"`
+	Before  float64 `json:"before"`
+	After   float64 `json:"after"`
+	Percent float64 `json:"percent"`
+}
+
+func loadSiteSnapshot(dir string, cfg *Config) *siteData {
+	raw, err := readSiteJSON(dir, cfg)
+	if err != nil {
+		return nil
+	}
+	var data siteData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil
+	}
+	return &data
+}
+
+func addItemTotal(byItem map[string]*ItemStats, s Sale) {
+	st := byItem[s.Item]
+	if st == nil {
+		st = &ItemStats{}
+		byItem[s.Item] = st
+	}
+	st.Count += s.Quantity
+	st.Sum += s.Price
+}
+
+func siteTotals(byItem map[string]*ItemStats) []siteItem {
+	totals := make([]siteItem, 0, len(byItem))
+	for item, st := range byItem {
+		totals = append(totals, siteItem{Name: item, Count: st.Count, Sum: st.Sum, Avg: st.average()})
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Sum != totals[j].Sum {
+			return totals[i].Sum > totals[j].Sum
+		}
+		return totals[i].Name < totals[j].Name
+	})
+	return totals
+}
+
+func characterRevenue(data *siteData) map[string]siteDelta {
+	res := make(map[string]siteDelta)
+	for _, srv := range data.Servers {
+		for _, ch := range srv.Characters {
+			for _, p := range ch.Periods {
+				if p.Name == "all" {
+					res[srv.Name+"/"+ch.ID] = siteDelta{Name: ch.Name + " #" + ch.ID + " (" + srv.Name + ")", After: p.Revenue.Float()}
+				}
+			}
+		}
+	}
+	return res
+}
+
+func percentChange(before, after float64) float64 {
+	if before == 0 {
+		return 0
+	}
+	return (after/before - 1) * 100
+}
+
+func diffSiteSnapshots(prev, cur *siteData) *siteChanges {
+	ch := &siteChanges{Since: prev.GeneratedAt}
+
+	wasTop := make(map[string]bool)
+	for i, it := range prev.Totals {
+		if i == siteTopItems {
+			break
+		}
+		wasTop[it.Name] = true
+	}
+	for i, it := range cur.Totals {
+		if i == siteTopItems {
+			break
+		}
+		if !wasTop[it.Name] {
+			ch.NewTopItems = append(ch.NewTopItems, it.Name)
+		}
+	}
+
+	before := characterRevenue(prev)
+	for key, d := range characterRevenue(cur) {
+		d.Before = before[key].After
+		if d.After == d.Before {
+			continue
+		}
+		d.Percent = percentChange(d.Before, d.After)
+		ch.Characters = append(ch.Characters, d)
+	}
+	sort.Slice(ch.Characters, func(i, j int) bool {
+		return ch.Characters[i].After-ch.Characters[i].Before > ch.Characters[j].After-ch.Characters[j].Before
+	})
+	if len(ch.Characters) > siteMaxMovers {
+		ch.Characters = ch.Characters[:siteMaxMovers]
+	}
+
+	prevAvg := make(map[string]float64)
+	for _, it := range prev.Totals {
+		prevAvg[it.Name] = it.Avg
+	}
+	for _, it := range cur.Totals {
+		old, ok := prevAvg[it.Name]
+		if !ok || old == 0 {
+			continue
+		}
+		if p := percentChange(old, it.Avg); math.Abs(p) >= siteMoverPercent {
+			ch.Prices = append(ch.Prices, siteDelta{Name: it.Name, Before: old, After: it.Avg, Percent: p})
+		}
+	}
+	sort.Slice(ch.Prices, func(i, j int) bool {
+		return math.Abs(ch.Prices[i].Percent) > math.Abs(ch.Prices[j].Percent)
+	})
+	return ch
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"unsafe"
+)
+
+// saleSpool collects sales and returns them sorted by time, keeping at most
+// limit bytes in memory and spilling sorted chunks to disk beyond that.
+// Sales added with a key are stored records: a key seen earlier in the same
+// second is the same sale and is returned once, as first added.
+type saleSpool struct {
+	limit  int64
+	size   int64
+	buf    []spooledSale
+	dir    string
+	chunks []string
+}
+
+type spooledSale struct {
+	Key  string
+	Sale Sale
+}
+
+func newSaleSpool(limitMB int) *saleSpool {
+	return &saleSpool{limit: int64(limitMB) << 20}
+}
+
+func saleSize(s Sale) int64 {
+	return int64(unsafe.Sizeof(s)) + int64(len(s.Server)+len(s.Character)+len(s.Item)+len(s.RawItem)+len(s.Quality)+len(s.Owner)+len(s.Counterparty))
+}
+
+func (sp *saleSpool) Add(s Sale) error {
+	return sp.AddKeyed("", s)
+}
+
+func (sp *saleSpool) AddKeyed(key string, s Sale) error {
+	sp.buf = append(sp.buf, spooledSale{Key: key, Sale: s})
+	sp.size += saleSize(s) + int64(unsafe.Sizeof(key)+uintptr(len(key)))
+	if sp.limit > 0 && sp.size >= sp.limit {
+		return sp.spill()
+	}
+	return nil
+}
+
+func sortSalesByTime(sales []Sale) {
+	sort.SliceStable(sales, func(i, j int) bool { return sales[i].Time.Before(sales[j].Time) })
+}
+
+func sortSpooled(buf []spooledSale) {
+	sort.SliceStable(buf, func(i, j int) bool { return buf[i].Sale.Time.Before(buf[j].Sale.Time) })
+}
+
+func (sp *saleSpool) spill() error {
+	if len(sp.buf) == 0 {
+		return nil
+	}
+	if sp.dir == "" {
+		dir, err := os.MkdirTemp("", "market-spill-")
+		if err != nil {
+			return fmt.Errorf("не удалось создать временную папку: %w", err)
+		}
+		sp.dir = dir
+	}
+	sortSpooled(sp.buf)
+
+	f, err := os.CreateTemp(sp.dir, "chunk-*.gob")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	for _, s := range sp.buf {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	sp.chunks = append(sp.chunks, f.Name())
+	clear(sp.buf)
+	sp.buf, sp.size = sp.buf[:0], 0
+	return nil
+}
+
+type chunkReader struct {
+	f    *os.File
+	dec  *gob.Decoder
+	seq  int
+	head spooledSale
+}
+
+func (r *chunkReader) next() (bool, error) {
+	r.head = spooledSale{}
+	err := r.dec.Decode(&r.head)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+type chunkHeap []*chunkReader
+
+func (h chunkHeap) Len() int      { return len(h) }
+func (h chunkHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *chunkHeap) Push(x any)   { *h = append(*h, x.(*chunkReader)) }
+
+// Less orders equal times by chunk, so sales come out in the order added.
+func (h chunkHeap) Less(i, j int) bool {
+	ti, tj := h[i].head.Sale.Time, h[j].head.Sale.Time
+	return ti.Before(tj) || ti.Equal(tj) && h[i].seq < h[j].seq
+}
+
+func (h *chunkHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// dedup drops stored records returned earlier; keys include the time, so
+// only the keys of the current second are kept.
+type dedup struct {
+	sec  int64
+	keys map[string]bool
+}
+
+func (d *dedup) seen(r spooledSale) bool {
+	if r.Key == "" {
+		return false
+	}
+	if sec := r.Sale.Time.Unix(); d.keys == nil || sec != d.sec {
+		d.sec, d.keys = sec, make(map[string]bool)
+	}
+	if d.keys[r.Key] {
+		return true
+	}
+	d.keys[r.Key] = true
+	return false
+}
+
+func (sp *saleSpool) Each(fn func(Sale) error) error {
+	var d dedup
+	if len(sp.chunks) == 0 {
+		sortSpooled(sp.buf)
+		for _, r := range sp.buf {
+			if d.seen(r) {
+				continue
+			}
+			if err := fn(r.Sale); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := sp.spill(); err != nil {
+		return err
+	}
+
+	h := make(chunkHeap, 0, len(sp.chunks))
+	defer func() {
+		for _, r := range h {
+			r.f.Close()
+		}
+	}()
+	for i, name := range sp.chunks {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		r := &chunkReader{f: f, dec: gob.NewDecoder(bufio.NewReader(f)), seq: i}
+		ok, err := r.next()
+		if err != nil || !ok {
+			f.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		h = append(h, r)
+	}
+	heap.Init(&h)
+	for h.Len() > 0 {
+		r := h[0]
+		if !d.seen(r.head) {
+			if err := fn(r.head.Sale); err != nil {
+				return err
+			}
+		}
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			r.f.Close()
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
+
+func (sp *saleSpool) Close() {
+	if sp != nil && sp.dir != "" {
+		os.RemoveAll(sp.dir)
+	}
+}
+
+// historySales walks sales kept in a spool instead of memory; nil walks none.
+type historySales func(fn func(Sale) error) error
+
+func (h historySales) each(fn func(Sale) error) error {
+	if h == nil {
+		return nil
+	}
+	return h(fn)
+}
+
+func (h historySales) filter(keep func(Sale) (Sale, bool)) historySales {
+	if h == nil {
+		return nil
+	}
+	return func(fn func(Sale) error) error {
+		return h(func(s Sale) error {
+			if s, ok := keep(s); ok {
+				return fn(s)
+			}
+			return nil
+		})
+	}
+}
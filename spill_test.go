@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestHistorySpoolBoundedHeap(t *testing.T) {
+	const (
+		sales   = 300_000
+		peakCap = 16 << 20
+	)
+	if size := sales * int(unsafe.Sizeof(Sale{})); size < 3*peakCap {
+		t.Fatalf("%d sales take %d bytes, too few to tell a bounded heap apart", sales, size)
+	}
+	t.Chdir(t.TempDir())
+	storage = fileStorage{}
+
+	f, err := os.Create(salesLedgerFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	for i := range sales {
+		s := Sale{MsgID: int64(i + 1), Time: start.Add(time.Duration(sales-i) * time.Minute), Server: "Atlanta",
+			Character: fmt.Sprintf("Icy Godless #%d", 288000+i%50), Item: "Адреналин", RawItem: "Адреналин", Quantity: 1, Price: Money(i%900+5) * dollar}
+		e := newLedgerEntry(contentKey(s), s)
+		if err := enc.Encode(e); err != nil {
+			t.Fatal(err)
+		}
+		if i%10 == 0 {
+			if err := enc.Encode(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cfg := &Config{BaseDir: "exports", MemoryLimitMB: 1}
+	if _, err := validateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	liveHeap := func() uint64 {
+		var ms runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&ms)
+		return ms.HeapAlloc
+	}
+	base := liveHeap()
+	done, peaks := make(chan struct{}), make(chan uint64)
+	go func() {
+		var peak uint64
+		tick := time.NewTicker(20 * time.Millisecond)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				peaks <- peak
+				return
+			case <-tick.C:
+				peak = max(peak, liveHeap())
+			}
+		}
+	}()
+
+	n := 0
+	var last time.Time
+	err = eachHistorySale(context.Background(), cfg, func(s Sale) error {
+		if s.Time.Before(last) {
+			return fmt.Errorf("sale %d at %v comes after %v", s.MsgID, s.Time, last)
+		}
+		last, n = s.Time, n+1
+		return nil
+	})
+	close(done)
+	peak := <-peaks
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != sales {
+		t.Errorf("got %d sales, want %d", n, sales)
+	}
+	if peak > base && peak-base > peakCap {
+		t.Errorf("live heap grew by %d bytes, want at most %d", peak-base, peakCap)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const eventExportStale = "export_stale"
+
+type staleExport struct {
+	export exportInfo
+	days   int
+}
+
+func findStaleExport(cfg *Config, exports []exportInfo, now time.Time) (staleExport, bool) {
+	if cfg.StaleExportDays <= 0 || len(exports) == 0 {
+		return staleExport{}, false
+	}
+	newest := exports[0]
+	for _, e := range exports[1:] {
+		if e.Date.After(newest.Date) {
+			newest = e
+		}
+	}
+	if newest.Date.IsZero() {
+		return staleExport{}, false
+	}
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	days := int(today.Sub(newest.Date).Hours() / 24)
+	if days < cfg.StaleExportDays {
+		return staleExport{}, false
+	}
+	return staleExport{export: newest, days: days}, true
+}
+
+func printStaleWarning(cfg *Config, s staleExport) {
+	line := strings.Repeat("!", 60)
+	fmt.Fprintln(out, line)
+	fmt.Fprintf(out, "!! Последний экспорт %s сделан %s — %d дн. назад.\n", filepath.Base(s.export.Path), formatDate(s.export.Date, cfg.Language), s.days)
+	fmt.Fprintln(out, "!! Отчёт может не учитывать свежие продажи: сделайте новый экспорт чата.")
+	fmt.Fprintln(out, line)
+}
+
+func emitStaleExport(cfg *Config, st *appState, s staleExport, now time.Time) {
+	today := now.Format("2006-01-02")
+	if st.StaleAlert == today {
+		return
+	}
+	st.StaleAlert = today
+	emit(cfg, hookEvent{Name: eventExportStale, Time: now, Data: map[string]string{
+		"export": filepath.Base(s.export.Path),
+		"date":   s.export.Date.Format("2006-01-02"),
+		"days":   fmt.Sprint(s.days),
+	}})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const stateFile = "state.json"
+
+type appState struct {
+	KnownItems     []string                   `json:"known_items,omitempty"`
+	RevenueAlerts  map[string]string          `json:"revenue_alerts,omitempty"`
+	AnonymizeSalt  string                     `json:"anonymize_salt,omitempty"`
+	LastSale       time.Time                  `json:"last_sale,omitempty"`
+	Forecasts      []forecastEntry            `json:"forecasts,omitempty"`
+	LiveOffset     int64                      `json:"live_offset,omitempty"`
+	AccountCursor  int64                      `json:"account_cursor,omitempty"`
+	Imports        map[string]importRecord    `json:"imports,omitempty"`
+	ReachedGoals   []string                   `json:"reached_goals,omitempty"`
+	CharacterNames map[string][]characterName `json:"character_names,omitempty"`
+	StaleAlert     string                     `json:"stale_alert,omitempty"`
+	TopSellerDay   string                     `json:"top_seller_day,omitempty"`
+
+	fresh bool
+}
+
+func loadState() (*appState, error) {
+	st := &appState{RevenueAlerts: make(map[string]string)}
+	data, err := storage.Get(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		st.fresh = true
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать %s: %w", stateFile, err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("%s повреждён (market verify --repair заменит его пустым): %w", stateFile, err)
+	}
+	if st.RevenueAlerts == nil {
+		st.RevenueAlerts = make(map[string]string)
+	}
+	return st, nil
+}
+
+func (st *appState) save() error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return storage.Put(stateFile, data)
+}
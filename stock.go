@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const eventLowStock = "low_stock"
+
+type StockConfig struct {
+	WarnDays float64      `json:"warn_days,omitempty"`
+	Items    []StockEntry `json:"items"`
+}
+
+type StockEntry struct {
+	Item     string `json:"item"`
+	Quantity int    `json:"quantity"`
+	Date     string `json:"date"`
+
+	time time.Time
+}
+
+type stockStatus struct {
+	Item      string
+	Remaining int
+	PerDay    float64
+	DaysLeft  float64
+}
+
+func (sc *StockConfig) validate(cfg *Config) error {
+	for i := range sc.Items {
+		e := &sc.Items[i]
+		t, err := time.ParseInLocation("2006-01-02", e.Date, time.Local)
+		if err != nil {
+			return fmt.Errorf("запас «%s»: дата %q не в формате 2006-01-02", e.Item, e.Date)
+		}
+		e.time = t
+		e.Item = cfg.canonicalItem(e.Item)
+	}
+	return nil
+}
+
+func (sc *StockConfig) warnDays() float64 {
+	if sc.WarnDays > 0 {
+		return sc.WarnDays
+	}
+	return 3
+}
+
+func stockStatuses(sc *StockConfig, sales []Sale, now time.Time) []stockStatus {
+	const velocityWindow = 7 * 24 * time.Hour
+	var res []stockStatus
+	for _, e := range sc.Items {
+		st := stockStatus{Item: e.Item, Remaining: e.Quantity, DaysLeft: math.Inf(1)}
+		soldRecently := 0
+		for _, s := range sales {
+			if s.Item != e.Item {
+				continue
+			}
+			if !s.Time.Before(e.time) {
+				st.Remaining -= s.Quantity
+			}
+			if now.Sub(s.Time) <= velocityWindow {
+				soldRecently += s.Quantity
+			}
+		}
+		st.PerDay = float64(soldRecently) / 7
+		if st.PerDay > 0 {
+			st.DaysLeft = math.Max(float64(st.Remaining), 0) / st.PerDay
+		}
+		res = append(res, st)
+	}
+	return res
+}
+
+func printStockReminders(cfg *Config, sales []Sale, now time.Time) []stockStatus {
+	if cfg.Stock == nil || len(cfg.Stock.Items) == 0 {
+		return nil
+	}
+	var low []stockStatus
+	fmt.Fprintln(out, "\nЗапасы:")
+	for _, st := range stockStatuses(cfg.Stock, sales, now) {
+		switch {
+		case st.Remaining <= 0:
+			fmt.Fprintf(out, "  ! %s закончился (остаток %d)\n", st.Item, st.Remaining)
+			low = append(low, st)
+		case st.DaysLeft <= cfg.Stock.warnDays():
+			fmt.Fprintf(out, "  ! %s закончится примерно через %.1f дн. при текущем темпе (%d шт., %.1f шт./день)\n", st.Item, st.DaysLeft, st.Remaining, st.PerDay)
+			low = append(low, st)
+		case math.IsInf(st.DaysLeft, 1):
+			fmt.Fprintf(out, "    %s: %d шт., продаж за неделю не было\n", st.Item, st.Remaining)
+		default:
+			fmt.Fprintf(out, "    %s: %d шт., хватит примерно на %.0f дн.\n", st.Item, st.Remaining, st.DaysLeft)
+		}
+	}
+	return low
+}
+
+func emitLowStock(cfg *Config, low []stockStatus, now time.Time) {
+	for _, st := range low {
+		emit(cfg, hookEvent{Name: eventLowStock, Time: now, Data: map[string]string{
+			"item":      st.Item,
+			"remaining": fmt.Sprint(st.Remaining),
+			"per_day":   fmt.Sprintf("%.2f", st.PerDay),
+			"days_left": fmt.Sprintf("%.1f", st.DaysLeft),
+		}})
+	}
+}
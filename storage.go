@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const boltStoreFile = "market.bolt"
+
+var boltBucket = []byte("files")
+
+type Storage interface {
+	Get(key string) ([]byte, error)
+	Put(key string, data []byte) error
+	Delete(key string) error
+}
+
+var storage Storage = fileStorage{}
+
+func newStorage(kind string) (Storage, error) {
+	switch kind {
+	case "", "files":
+		return fileStorage{}, nil
+	case "bolt":
+		return boltStorage{path: boltStoreFile}, nil
+	}
+	return nil, fmt.Errorf("неизвестное хранилище %q (допустимо: files, bolt)", kind)
+}
+
+type fileStorage struct{}
+
+func (fileStorage) Get(key string) ([]byte, error) {
+	return os.ReadFile(key)
+}
+
+func (fileStorage) Put(key string, data []byte) error {
+	return writeFileAtomic(key, data, 0o644)
+}
+
+func (fileStorage) Delete(key string) error {
+	if err := os.Remove(key); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+type boltStorage struct {
+	path string
+}
+
+func (s boltStorage) open(readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(s.path, 0o644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: readOnly})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, withKind(ErrStoreLocked, fmt.Errorf("%s занят другим процессом", s.path))
+	}
+	return db, err
+}
+
+func (s boltStorage) Get(key string) ([]byte, error) {
+	data, err := s.lookup(key)
+	if err == nil && data == nil {
+		return os.ReadFile(key)
+	}
+	return data, err
+}
+
+func (s boltStorage) lookup(key string) ([]byte, error) {
+	if _, err := os.Stat(s.path); err != nil {
+		return nil, nil
+	}
+	db, err := s.open(true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var data []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(boltBucket); b != nil {
+			if v := b.Get([]byte(key)); v != nil {
+				data = append([]byte(nil), v...)
+			}
+		}
+		return nil
+	})
+	return data, err
+}
+
+func (s boltStorage) Put(key string, data []byte) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(boltBucket)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+func (s boltStorage) Delete(key string) error {
+	if err := (fileStorage{}).Delete(key); err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(boltBucket); b != nil {
+			return b.Delete([]byte(key))
+		}
+		return nil
+	})
+}
+
+type boltStorePurge struct{ cfg *Config }
+
+func (boltStorePurge) Name() string { return boltStoreFile }
+
+// Purge compacts the bolt file after the other targets have deleted records
+// through storage: bolt keeps freed pages, and the deleted data in them, until
+// the file is rewritten. A file left from before storage was switched to
+// files is no longer read or cleaned, so purge refuses to run while it exists.
+func (p boltStorePurge) Purge(_ purgeFilter, dryRun bool) (int, error) {
+	if !fileExists(boltStoreFile) {
+		return 0, nil
+	}
+	if p.cfg.Storage != "bolt" {
+		return 0, errors.New("файл остался от storage: bolt и не очищается — удалите его или верните storage: bolt")
+	}
+	if dryRun {
+		return 0, nil
+	}
+	src, err := boltStorage{path: boltStoreFile}.open(false)
+	if err != nil {
+		return 0, err
+	}
+	tmp := boltStoreFile + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		src.Close()
+		return 0, err
+	}
+	dst, err := bolt.Open(tmp, 0o644, nil)
+	if err != nil {
+		src.Close()
+		return 0, err
+	}
+	err = bolt.Compact(dst, src, 0)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	src.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return 0, os.Rename(tmp, boltStoreFile)
+}
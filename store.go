@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const storeUsage = "использование: market store stats | market store sql ЗАПРОС"
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+func compressStore(data []byte) []byte {
+	return zstdEncoder.EncodeAll(data, nil)
+}
+
+func decodeStore(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, zstdMagic) {
+		return data, nil
+	}
+	return zstdDecoder.DecodeAll(data, nil)
+}
+
+func readStoreFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := decodeStore(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s повреждён: %w", path, err)
+	}
+	return plain, nil
+}
+
+func isCompressedStore(path string) (compressed, empty bool, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, true, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	defer f.Close()
+	head := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(f, head)
+	if n == 0 {
+		return false, true, nil
+	}
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, false, err
+	}
+	return bytes.Equal(head[:n], zstdMagic), false, nil
+}
+
+func appendStoreFile(path string, data []byte) error {
+	compressed, empty, err := isCompressedStore(path)
+	if err != nil {
+		return err
+	}
+	if !compressed && !empty {
+		old, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(path, compressStore(append(old, data...)), 0o644)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(compressStore(data)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type storeStat struct {
+	Name    string
+	Records string
+	Disk    int64
+	Raw     int64
+}
+
+func countLines(data []byte) int {
+	n := 0
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func collectStoreStats() ([]storeStat, error) {
+	type storeFile struct {
+		name  string
+		count func(data []byte) string
+	}
+	files := []storeFile{
+		{parseCacheFile, func(data []byte) string {
+			var c parseCache
+			if gob.NewDecoder(bytes.NewReader(data)).Decode(&c) != nil {
+				return "не читается"
+			}
+			var sales, purchases, trades, anomalies int
+			for _, p := range c.Files {
+				sales += len(p.Sales)
+				purchases += len(p.Purchases)
+				trades += len(p.Trades)
+				anomalies += len(p.Anomalies)
+			}
+			return fmt.Sprintf("страниц: %d (продаж %d, покупок %d, сделок %d, аномалий %d)", len(c.Files), sales, purchases, trades, anomalies)
+		}},
+		{liveMessagesFile, func(data []byte) string { return fmt.Sprintf("сообщений: %d", countLines(data)) }},
+		{correctionsFile, func(data []byte) string { return fmt.Sprintf("исправлений: %d", countLines(data)) }},
+		{salesLedgerFile, func(data []byte) string { return fmt.Sprintf("продаж: %d", countLines(data)) }},
+		{ingestLogFile, func(data []byte) string { return fmt.Sprintf("запусков: %d", countLines(data)) }},
+		{stateFile, func(data []byte) string {
+			var st appState
+			if json.Unmarshal(data, &st) != nil {
+				return "не читается"
+			}
+			return fmt.Sprintf("предметов: %d, оповещений: %d, прогнозов: %d", len(st.KnownItems), len(st.RevenueAlerts), len(st.Forecasts))
+		}},
+		{exportCacheFile, func(data []byte) string {
+			var c exportCache
+			_ = json.Unmarshal(data, &c)
+			return fmt.Sprintf("папок экспорта: %d", len(c.Exports))
+		}},
+		{salesMonthlyFile, func(data []byte) string {
+			var m monthlySnapshot
+			if json.Unmarshal(data, &m) != nil {
+				return "не читается"
+			}
+			return fmt.Sprintf("месяцев: %d, строк итогов: %d", m.months(), len(m.Totals))
+		}},
+		{marketPricesCacheFile, func(data []byte) string {
+			var c marketPricesCache
+			_ = json.Unmarshal(data, &c)
+			return fmt.Sprintf("цен: %d", len(c.Prices))
+		}},
+	}
+
+	var stats []storeStat
+	kv := boltStorage{path: boltStoreFile}
+	for _, f := range files {
+		stored, err := kv.lookup(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			data, err := decodeStore(stored)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%s повреждён: %w", boltStoreFile, f.name, err)
+			}
+			stats = append(stats, storeStat{Name: boltStoreFile + ":" + f.name, Records: f.count(data), Disk: int64(len(stored)), Raw: int64(len(data))})
+		}
+		info, err := os.Stat(f.name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := readStoreFile(f.name)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, storeStat{Name: f.name, Records: f.count(data), Disk: info.Size(), Raw: int64(len(data))})
+	}
+	return stats, nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f МБ", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f КБ", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d Б", n)
+}
+
+func cmdStore(args []string) error {
+	if len(args) == 2 && args[0] == "sql" {
+		cfg, err := loadValidConfig()
+		if err != nil {
+			return err
+		}
+		return runSalesQuery(cfg, args[1])
+	}
+	if len(args) == 0 || args[0] != "stats" {
+		return errors.New(storeUsage)
+	}
+	stats, err := collectStoreStats()
+	if err != nil {
+		return err
+	}
+	if len(stats) == 0 {
+		fmt.Fprintln(out, "Хранилище пусто: файлов данных в текущей папке нет.")
+		return nil
+	}
+	var disk, raw int64
+	w := newTable()
+	fmt.Fprintln(w, "Файл\tНа диске\tБез сжатия\tСжатие\tЗаписи")
+	for _, s := range stats {
+		ratio := "—"
+		if s.Disk < s.Raw {
+			ratio = fmt.Sprintf("×%.1f", float64(s.Raw)/float64(s.Disk))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Name, formatBytes(s.Disk), formatBytes(s.Raw), ratio, s.Records)
+		disk += s.Disk
+		raw += s.Raw
+	}
+	fmt.Fprintf(w, "Всего\t%s\t%s\t\t\n", formatBytes(disk), formatBytes(raw))
+	return w.Flush()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const telegramDownloadsDir = "Telegram Desktop"
+
+func downloadDirs() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	if xdg := xdgDownloadDir(home); xdg != "" {
+		dirs = append(dirs, xdg)
+	}
+	return append(dirs, filepath.Join(home, "Downloads"), filepath.Join(home, "Загрузки"))
+}
+
+func xdgDownloadDir(home string) string {
+	if dir := os.Getenv("XDG_DOWNLOAD_DIR"); dir != "" {
+		return dir
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	f, err := os.Open(filepath.Join(configHome, "user-dirs.dirs"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		value, ok := strings.CutPrefix(strings.TrimSpace(sc.Text()), "XDG_DOWNLOAD_DIR=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		return strings.Replace(value, "$HOME", home, 1)
+	}
+	return ""
+}
+
+func defaultExportDir() (dir string, exports int) {
+	seen := make(map[string]bool)
+	for _, d := range downloadDirs() {
+		d = filepath.Join(d, telegramDownloadsDir)
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		entries, err := os.ReadDir(d)
+		if err != nil {
+			continue
+		}
+		n := 0
+		for _, e := range entries {
+			if exportRe.MatchString(e.Name()) {
+				n++
+			}
+		}
+		if dir == "" || n > exports {
+			dir, exports = d, n
+		}
+	}
+	return dir, exports
+}
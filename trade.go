@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+type Trade struct {
+	MsgID        int64
+	Time         time.Time
+	Server       string
+	Character    string
+	Counterparty string
+	Item         string
+	Quantity     int
+	Price        Money
+	Incoming     bool
+}
+
+func (t Trade) key() string {
+	return tradeKey(t.MsgID, t.Time, t.Server, t.Character, t.Counterparty+"|"+t.Item, t.Quantity, t.Price)
+}
+
+func (t Trade) contentKey() string {
+	t.MsgID = 0
+	return t.key()
+}
+
+type tradeMessageParser struct {
+	trigger  []byte
+	re       *regexp.Regexp
+	labels   [][][]byte
+	currency []byte
+	cfg      *Config
+	limits   Limits
+}
+
+func newTradeParser(trigger func(*tradeFormat) []byte) func(cfg *Config, tf *tradeFormat) Parser {
+	return func(cfg *Config, tf *tradeFormat) Parser {
+		return &tradeMessageParser{trigger: trigger(tf), re: tf.tradeRe, labels: tf.tradeLabels, currency: tf.currency, cfg: cfg, limits: cfg.limits()}
+	}
+}
+
+func (p *tradeMessageParser) Match(text []byte) bool {
+	return bytes.Contains(text, p.trigger)
+}
+
+func (p *tradeMessageParser) Parse(text []byte, t time.Time) (Sale, error) {
+	if !containsAll(text, p.labels) {
+		return Sale{}, errNotTrade
+	}
+	m := p.re.FindSubmatchIndex(text)
+	if m == nil {
+		return Sale{}, errNotTrade
+	}
+	field := func(i int) []byte {
+		if m[2*i] < 0 {
+			return nil
+		}
+		return bytes.TrimSpace(text[m[2*i]:m[2*i+1]])
+	}
+
+	qty, _ := strconv.Atoi(string(field(5)))
+	var price Money
+	if raw := field(6); raw != nil {
+		raw = bytes.TrimSpace(bytes.ReplaceAll(raw, p.currency, nil))
+		var err error
+		if price, err = parseAmount(raw); err != nil {
+			return Sale{}, withKind(ErrMalformedMessage, fmt.Errorf("не удалось разобрать сумму сделки %q", raw))
+		}
+	}
+	checked := price
+	if price == 0 {
+		checked = p.limits.MinPrice
+	}
+	if reason := p.limits.check(checked, qty); reason != "" {
+		return Sale{}, withKind(ErrMalformedMessage, errors.New(reason))
+	}
+
+	rawItem, _ := p.cfg.splitQuality(string(field(4)))
+	return Sale{
+		Time:         t,
+		Server:       string(field(1)),
+		Character:    string(field(2)),
+		Counterparty: string(field(3)),
+		Item:         p.cfg.aliasItem(rawItem),
+		RawItem:      rawItem,
+		Quantity:     qty,
+		Price:        price,
+	}, nil
+}
+
+func addTradeOut(res *parseResult, s Sale, _ func(Sale) error) error {
+	res.Trades = append(res.Trades, tradeFromSale(s, false))
+	return nil
+}
+
+func addTradeIn(res *parseResult, s Sale, _ func(Sale) error) error {
+	res.Trades = append(res.Trades, tradeFromSale(s, true))
+	return nil
+}
+
+func tradeFromSale(s Sale, incoming bool) Trade {
+	return Trade{MsgID: s.MsgID, Time: s.Time, Server: s.Server, Character: s.Character, Counterparty: s.Counterparty, Item: s.Item, Quantity: s.Quantity, Price: s.Price, Incoming: incoming}
+}
+
+func tradesAsOf(trades []Trade, asOf time.Time) []Trade {
+	var res []Trade
+	for _, t := range trades {
+		if !t.Time.After(asOf) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+func anonymizeTrades(trades []Trade, salt string) []Trade {
+	res := make([]Trade, len(trades))
+	for i, t := range trades {
+		t.Character = anonymousCharacter(salt, t.Server, t.Character)
+		t.Counterparty = anonymousCharacter(salt, t.Server, t.Counterparty)
+		res[i] = t
+	}
+	return res
+}
+
+func addTrades(servers map[string]*Server, trades []Trade, now time.Time, p period) {
+	for _, t := range trades {
+		if !p.contains(t.Time, now) {
+			continue
+		}
+		ch := ensureCharacter(servers, t.Server, t.Character, t.Time)
+		ch.Trades++
+		if t.Incoming {
+			ch.TradePaid += t.Price
+		} else {
+			ch.TradeReceived += t.Price
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type monthKey struct {
+	year  int
+	month time.Month
+}
+
+func (k monthKey) prevYear() monthKey { return monthKey{k.year - 1, k.month} }
+
+func (k monthKey) less(o monthKey) bool {
+	return k.year < o.year || k.year == o.year && k.month < o.month
+}
+
+type monthRollup map[string]map[monthKey]*ItemStats
+
+func (r monthRollup) add(g string, s Sale) {
+	if r[g] == nil {
+		r[g] = make(map[monthKey]*ItemStats)
+	}
+	k := monthKey{s.Time.Year(), s.Time.Month()}
+	st := r[g][k]
+	if st == nil {
+		st = &ItemStats{}
+		r[g][k] = st
+	}
+	st.Count += s.Quantity
+	st.Sum += s.Price
+}
+
+func (r monthRollup) fillFromSnapshot(snap *monthlySnapshot, group func(Sale) string, cfg *Config) {
+	pruned := make(monthRollup)
+	for _, t := range snap.Totals {
+		month, err := time.ParseInLocation("2006-01", t.Month, time.Local)
+		if err != nil {
+			continue
+		}
+		s := Sale{Time: month, Server: t.Server, Character: t.Character, Item: cfg.canonicalItem(t.Item), Quantity: t.Quantity, Price: t.Revenue}
+		pruned.add(group(s), s)
+	}
+	for g, months := range pruned {
+		for k, st := range months {
+			if r[g][k] != nil {
+				continue
+			}
+			if r[g] == nil {
+				r[g] = make(map[monthKey]*ItemStats)
+			}
+			r[g][k] = st
+		}
+	}
+}
+
+func cmdTrends(args []string) error {
+	fs := newFlagSet("trends")
+	by := fs.String("by", "character", "группировка: character или item")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var group func(Sale) string
+	switch *by {
+	case "character":
+		group = func(s Sale) string {
+			name, id := splitCharacter(s.Character)
+			if id == "" {
+				return s.Server + " / " + name
+			}
+			return fmt.Sprintf("%s / %s #%s", s.Server, name, id)
+		}
+	case "item":
+		group = func(s Sale) string { return s.Item }
+	default:
+		return fmt.Errorf("неизвестная группировка %q (допустимо: character, item)", *by)
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	ctx, stop := runContext(cfg)
+	defer stop()
+	r := make(monthRollup)
+	err = eachHistorySale(ctx, cfg, func(s Sale) error {
+		r.add(group(s), s)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	snap, err := loadMonthlySnapshot()
+	if err != nil {
+		return err
+	}
+	r.fillFromSnapshot(snap, group, cfg)
+	if len(r) == 0 {
+		return errors.New("в экспорте нет продаж")
+	}
+	printTrends(r, cfg)
+	return nil
+}
+
+func printTrends(r monthRollup, cfg *Config) {
+	groups := make([]string, 0, len(r))
+	for g := range r {
+		groups = append(groups, g)
+	}
+	sortNames(groups, cfg.Language)
+
+	for _, g := range groups {
+		months := r[g]
+		keys := make([]monthKey, 0, len(months))
+		for k := range months {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+		fmt.Fprintf(out, "\nПомесячные итоги: %s\n", g)
+		w := newTable()
+		fmt.Fprintln(w, "Месяц\tКол-во\tВыручка\tГодом ранее\tИзменение")
+		var total Money
+		for _, k := range keys {
+			st := months[k]
+			total += st.Sum
+			prev, change := "-", "-"
+			if p := months[k.prevYear()]; p != nil {
+				prev = fmt.Sprintf("$%.2f", p.Sum)
+				if p.Sum > 0 {
+					change = fmt.Sprintf("%+.1f%%", (st.Sum.Float()/p.Sum.Float()-1)*100)
+				}
+			}
+			fmt.Fprintf(w, "%s\t%d\t$%.2f\t%s\t%s\n", formatMonth(k.year, k.month, cfg.Language), st.Count, st.Sum, prev, change)
+		}
+		w.Flush()
+		fmt.Fprintf(out, "    Всего за %d мес.: $%.2f\n", len(keys), total)
+	}
+}
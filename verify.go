@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type verifyTarget interface {
+	Name() string
+	Verify(repair bool) ([]string, error)
+}
+
+func cmdVerify(args []string) error {
+	fs := newFlagSet("verify")
+	repair := fs.Bool("repair", false, "исправить найденные нарушения")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadValidConfig()
+	if err != nil {
+		return err
+	}
+	ctx, stop := runContext(cfg)
+	defer stop()
+
+	total := 0
+	for _, t := range verifyTargets(ctx, cfg) {
+		problems, err := t.Verify(*repair)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Name(), err)
+		}
+		if len(problems) == 0 {
+			fmt.Fprintf(out, "%s: нарушений нет\n", t.Name())
+			continue
+		}
+		fmt.Fprintf(out, "%s: нарушений — %d\n", t.Name(), len(problems))
+		for _, p := range problems {
+			fmt.Fprintln(out, "  -", p)
+		}
+		total += len(problems)
+	}
+	switch {
+	case total == 0:
+		return nil
+	case *repair:
+		fmt.Fprintln(out, "Нарушения исправлены.")
+		return nil
+	}
+	return fmt.Errorf("найдено нарушений: %d (запустите с --repair, чтобы исправить)", total)
+}
+
+func verifyTargets(ctx context.Context, cfg *Config) []verifyTarget {
+	targets := []verifyTarget{stateVerify{}}
+	if fileExists(salesLedgerFile) {
+		targets = append(targets, ledgerVerify{ctx: ctx, cfg: cfg})
+	}
+	if cfg.SalesDB != "" && (isPostgresDSN(cfg.SalesDB) || fileExists(cfg.SalesDB)) {
+		targets = append(targets, salesDBVerify{ctx: ctx, cfg: cfg})
+	}
+	if cfg.SiteDir != "" {
+		targets = append(targets, siteVerify{dir: cfg.SiteDir, cfg: cfg})
+	}
+	return targets
+}
+
+type stateVerify struct{}
+
+func (stateVerify) Name() string { return stateFile }
+
+func (stateVerify) Verify(repair bool) ([]string, error) {
+	raw, err := storage.Get(stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(raw) {
+		if repair {
+			return []string{"файл повреждён и заменён пустым состоянием"}, (&appState{}).save()
+		}
+		return []string{"файл не является корректным JSON"}, nil
+	}
+
+	st, err := loadState()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var problems []string
+	for key, day := range st.RevenueAlerts {
+		parts := strings.Split(key, "/")
+		bad := ""
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			bad = "неверный ключ"
+		} else if _, err := strconv.ParseFloat(parts[2], 64); err != nil {
+			bad = "неверный порог"
+		} else if d, err := time.ParseInLocation("2006-01-02", day, time.Local); err != nil {
+			bad = "неверная дата"
+		} else if d.After(now) {
+			bad = "дата в будущем"
+		}
+		if bad != "" {
+			problems = append(problems, fmt.Sprintf("оповещение о выручке %q → %q: %s", key, day, bad))
+			delete(st.RevenueAlerts, key)
+		}
+	}
+	if st.LastSale.After(now) {
+		problems = append(problems, fmt.Sprintf("последняя продажа %s в будущем", st.LastSale.Format(time.RFC3339)))
+		st.LastSale = time.Time{}
+	}
+	known := slices.Clone(st.KnownItems)
+	slices.Sort(known)
+	known = slices.Compact(known)
+	known = slices.DeleteFunc(known, func(s string) bool { return strings.TrimSpace(s) == "" })
+	if len(known) != len(st.KnownItems) {
+		problems = append(problems, fmt.Sprintf("список известных предметов содержит %d пустых или повторных записей", len(st.KnownItems)-len(known)))
+		st.KnownItems = known
+	}
+	slices.Sort(problems)
+
+	if repair && len(problems) > 0 {
+		return problems, st.save()
+	}
+	return problems, nil
+}
+
+type siteVerify struct {
+	dir string
+	cfg *Config
+}
+
+func (v siteVerify) Name() string { return filepath.Join(v.dir, "data.json") }
+
+func (v siteVerify) Verify(repair bool) ([]string, error) {
+	data := loadSiteSnapshot(v.dir, v.cfg)
+	var problems []string
+	if data == nil {
+		if _, err := os.Stat(filepath.Join(v.dir, "data.json")); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if siteLocked(v.dir) && v.cfg.sitePassword() == "" {
+			return nil, errSiteLocked
+		}
+		problems = append(problems, "файл повреждён или не является корректным JSON")
+	} else {
+		problems = siteProblems(data, v.cfg.exportPeriods())
+	}
+	if !repair || len(problems) == 0 {
+		return problems, nil
+	}
+
+	ctx, stop := runContext(v.cfg)
+	defer stop()
+	sales, err := loadLatestSales(ctx, v.cfg)
+	if err != nil {
+		return problems, fmt.Errorf("не удалось пересобрать отчёт из экспорта: %w", err)
+	}
+	return problems, writeSite(v.dir, sales, nil, v.cfg, time.Now())
+}
+
+func siteProblems(data *siteData, periods []period) []string {
+	const eps = 0.005
+	var problems []string
+	var charTotal Money
+	for _, srv := range data.Servers {
+		if len(srv.Characters) == 0 {
+			problems = append(problems, fmt.Sprintf("сервер %s без персонажей", srv.Name))
+		}
+		for _, ch := range srv.Characters {
+			who := fmt.Sprintf("%s #%s (%s)", ch.Name, ch.ID, srv.Name)
+			byName := make(map[string]sitePeriod)
+			for _, p := range ch.Periods {
+				byName[p.Name] = p
+				var items Money
+				for _, it := range p.Items {
+					items += it.Sum
+					if it.Count > 0 && math.Abs(it.Sum.Float()/float64(it.Count)-it.Avg) > eps {
+						problems = append(problems, fmt.Sprintf("%s, %s: средняя цена «%s» не равна сумме, делённой на количество", who, p.Name, it.Name))
+					}
+				}
+				if items > p.Revenue {
+					problems = append(problems, fmt.Sprintf("%s, %s: сумма по предметам $%.2f больше выручки $%.2f", who, p.Name, items, p.Revenue))
+				}
+			}
+			all, ok := byName["all"]
+			if !ok || all.Sales == 0 {
+				problems = append(problems, fmt.Sprintf("%s: персонаж без продаж", who))
+			}
+			charTotal += all.Revenue
+			for _, p := range periods {
+				inner, ok := byName[p.name]
+				if !ok {
+					continue
+				}
+				for _, q := range periods {
+					outer, ok := byName[q.name]
+					if !ok || p.name == q.name || !p.within(q, data.GeneratedAt) {
+						continue
+					}
+					if inner.Revenue > outer.Revenue || inner.Sales > outer.Sales {
+						problems = append(problems, fmt.Sprintf("%s: период %s больше периода %s", who, p.name, q.name))
+					}
+				}
+			}
+		}
+	}
+	if len(data.Totals) > 0 {
+		var total Money
+		for _, it := range data.Totals {
+			total += it.Sum
+		}
+		if total != charTotal {
+			problems = append(problems, fmt.Sprintf("итог по предметам $%.2f не совпадает с выручкой персонажей $%.2f", total, charTotal))
+		}
+	}
+	return problems
+}
+
+// saleProblem returns why a stored sale cannot be right, or "" when it can.
+func saleProblem(s Sale, now time.Time) string {
+	switch {
+	case s.Time.IsZero():
+		return "нет времени"
+	case s.Time.After(now):
+		return "время в будущем"
+	case s.Character == "" || s.Item == "":
+		return "нет персонажа или предмета"
+	case s.Quantity <= 0:
+		return fmt.Sprintf("количество %d", s.Quantity)
+	case s.Price < 0:
+		return "отрицательная цена"
+	}
+	return ""
+}
+
+type saleIdentity struct {
+	msgID     int64
+	server    string
+	character string
+	rawItem   string
+	quantity  int
+	price     Money
+}
+
+func identityOf(s Sale) saleIdentity {
+	return saleIdentity{s.MsgID, s.Server, s.Character, s.RawItem, s.Quantity, s.Price}
+}
+
+// saleTimeConflicts finds sales stored with different times under the same
+// message ID in the ledger and the sales database, and returns the time each
+// of them should have: the one in the current exports, or the earliest stored
+// one when the exports no longer have the message.
+func saleTimeConflicts(ctx context.Context, cfg *Config) (map[saleIdentity]time.Time, error) {
+	now := time.Now()
+	times := make(map[saleIdentity][]time.Time)
+	record := func(s Sale) {
+		if s.MsgID == 0 || saleProblem(s, now) != "" {
+			return
+		}
+		id := identityOf(s)
+		for _, t := range times[id] {
+			if t.Unix() == s.Time.Unix() {
+				return
+			}
+		}
+		times[id] = append(times[id], s.Time)
+	}
+	err := eachLedgerLine(salesLedgerFile, func(_ int, data []byte) error {
+		var e ledgerEntry
+		if json.Unmarshal(data, &e) == nil {
+			record(e.sale())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SalesDB != "" && (isPostgresDSN(cfg.SalesDB) || fileExists(cfg.SalesDB)) {
+		rows, err := readSalesDBRows(ctx, cfg.SalesDB)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			if r.err == nil {
+				record(r.sale)
+			}
+		}
+	}
+
+	conflicts := make(map[saleIdentity]time.Time)
+	for id, ts := range times {
+		if len(ts) > 1 {
+			conflicts[id] = slices.MinFunc(ts, time.Time.Compare)
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+	err = eachExportSale(ctx, cfg, func(s Sale) error {
+		if id := identityOf(s); !conflicts[id].IsZero() {
+			conflicts[id] = s.Time
+		}
+		return nil
+	})
+	if errors.Is(err, ErrExportNotFound) {
+		err = nil
+	}
+	return conflicts, err
+}
+
+// keyMatches reports whether key is the one storedSaleKeys gives s; a
+// "#n" suffix tells identical sales apart.
+func keyMatches(key string, s Sale) bool {
+	base, _, _ := strings.Cut(key, "#")
+	return base == contentKey(s)
+}
+
+func timeConflict(s Sale, want time.Time) string {
+	return fmt.Sprintf("сообщение %d (%s, %s): время %s, в других записях и экспорте — %s",
+		s.MsgID, s.Character, s.RawItem, s.Time.Format("02.01.2006 15:04:05"), want.In(time.Local).Format("02.01.2006 15:04:05"))
+}
+
+type ledgerVerify struct {
+	ctx context.Context
+	cfg *Config
+}
+
+func (ledgerVerify) Name() string { return salesLedgerFile }
+
+func (v ledgerVerify) Verify(repair bool) ([]string, error) {
+	now := time.Now()
+	var problems []string
+	var kept []ledgerEntry
+	err := eachLedgerLine(salesLedgerFile, func(line int, data []byte) error {
+		var e ledgerEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			problems = append(problems, fmt.Sprintf("строка %d: не является корректной записью", line))
+			return nil
+		}
+		if bad := saleProblem(e.sale(), now); bad != "" {
+			problems = append(problems, fmt.Sprintf("строка %d: %s", line, bad))
+			return nil
+		}
+		kept = append(kept, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := saleTimeConflicts(v.ctx, v.cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range kept {
+		s := e.sale()
+		if want, ok := conflicts[identityOf(s)]; ok && want.Unix() != s.Time.Unix() {
+			problems = append(problems, timeConflict(s, want))
+			s.Time = want
+			kept[i] = newLedgerEntry(contentKey(s), s)
+		} else if !keyMatches(e.Key, s) {
+			problems = append(problems, fmt.Sprintf("запись %s: ключ не соответствует содержимому", e.Key))
+			kept[i].Key = contentKey(s)
+		}
+	}
+	seen := make(map[string]bool, len(kept))
+	kept = slices.DeleteFunc(kept, func(e ledgerEntry) bool {
+		if seen[e.Key] {
+			problems = append(problems, fmt.Sprintf("запись %s повторяется", e.Key))
+			return true
+		}
+		seen[e.Key] = true
+		return false
+	})
+	if !repair || len(problems) == 0 {
+		return problems, nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, e := range kept {
+		if err := enc.Encode(e); err != nil {
+			return problems, err
+		}
+	}
+	return problems, writeFileAtomic(salesLedgerFile, buf.Bytes(), 0o644)
+}
+
+type salesDBVerify struct {
+	ctx context.Context
+	cfg *Config
+}
+
+func (v salesDBVerify) Name() string { return salesDBName(v.cfg.SalesDB) }
+
+func (v salesDBVerify) Verify(repair bool) ([]string, error) {
+	rows, err := readSalesDBRows(v.ctx, v.cfg.SalesDB)
+	if err != nil {
+		return nil, err
+	}
+	conflicts, err := saleTimeConflicts(v.ctx, v.cfg)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var problems, remove []string
+	fixID := make(map[string]string)
+	var reinsert []Sale
+	for _, r := range rows {
+		bad := saleProblem(r.sale, now)
+		if r.err != nil {
+			bad = r.err.Error()
+		}
+		if bad != "" {
+			problems = append(problems, fmt.Sprintf("запись %s: %s", r.key, bad))
+			remove = append(remove, r.key)
+			continue
+		}
+		if want, ok := conflicts[identityOf(r.sale)]; ok && want.Unix() != r.sale.Time.Unix() {
+			problems = append(problems, timeConflict(r.sale, want))
+			remove = append(remove, r.key)
+			s := r.sale
+			s.Time = want
+			reinsert = append(reinsert, s)
+			continue
+		}
+		if !keyMatches(r.key, r.sale) {
+			problems = append(problems, fmt.Sprintf("запись %s: ключ не соответствует содержимому", r.key))
+			remove = append(remove, r.key)
+			reinsert = append(reinsert, r.sale)
+			continue
+		}
+		if _, id := splitCharacter(r.sale.Character); id != r.characterID {
+			problems = append(problems, fmt.Sprintf("запись %s: character_id %q не совпадает с персонажем %s", r.key, r.characterID, r.sale.Character))
+			fixID[r.key] = id
+		}
+	}
+	if !repair || len(problems) == 0 {
+		return problems, nil
+	}
+
+	db, err := openSalesDB(v.cfg.SalesDB)
+	if err != nil {
+		return problems, err
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(v.ctx, nil)
+	if err != nil {
+		return problems, err
+	}
+	defer tx.Rollback()
+	for _, key := range remove {
+		if _, err := tx.ExecContext(v.ctx, db.rebind("DELETE FROM sales WHERE key = ?"), key); err != nil {
+			return problems, err
+		}
+	}
+	for key, id := range fixID {
+		if _, err := tx.ExecContext(v.ctx, db.rebind("UPDATE sales SET character_id = ? WHERE key = ?"), id, key); err != nil {
+			return problems, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return problems, err
+	}
+	seen := make(map[string]bool, len(reinsert))
+	reinsert = slices.DeleteFunc(reinsert, func(s Sale) bool {
+		k := contentKey(s)
+		defer func() { seen[k] = true }()
+		return seen[k]
+	})
+	_, err = upsertSales(v.ctx, db, reinsert, now)
+	return problems, err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const watchDebounce = 2 * time.Second
+
+func watchReports(cfg *Config, rf runFlags) error {
+	base := cfg.BaseDir
+	if rf.arg != "" {
+		base = rf.arg
+	}
+	ctx, stop := deferShutdown()
+	defer stop()
+
+	refresh := func() {
+		rctx, cancel := withReportTimeout(ctx, cfg)
+		_, _, err := generateReport(rctx, cfg, rf)
+		cancel()
+		if err != nil && !errors.Is(err, errInterrupted) {
+			fmt.Fprintln(out, "Ошибка:", err)
+		}
+		fmt.Fprintf(out, "\nНаблюдение за %s — отчёт обновится при появлении нового экспорта (Ctrl+C — выход)\n", base)
+		flushOut()
+	}
+	return watchExportDir(ctx, base, func(fn func() error) error { return fn() }, refresh, func() {
+		fmt.Fprintf(out, "\n%s\nОбнаружены изменения экспорта, отчёт перестроен:\n", time.Now().Format("15:04:05"))
+		refresh()
+	})
+}
+
+func watchExportDir(ctx context.Context, base string, locked func(fn func() error) error, start, changed func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("не удалось запустить наблюдение за файлами: %w", err)
+	}
+	defer w.Close()
+
+	if err := w.Add(base); err != nil {
+		return fmt.Errorf("не удалось следить за %s: %w", base, err)
+	}
+	watched := map[string]bool{}
+	watchExports := func() {
+		entries, err := os.ReadDir(base)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			dir := filepath.Join(base, e.Name())
+			if e.IsDir() && exportRe.MatchString(e.Name()) && !watched[dir] {
+				if w.Add(dir) == nil {
+					watched[dir] = true
+				}
+			}
+		}
+	}
+	watchExports()
+	start()
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-w.Errors:
+			_ = locked(func() error {
+				fmt.Fprintln(out, "Предупреждение:", err)
+				flushOut()
+				return nil
+			})
+		case ev := <-w.Events:
+			if !watchRelevant(ev) {
+				continue
+			}
+			timer = time.After(watchDebounce)
+		case <-timer:
+			timer = nil
+			watchExports()
+			changed()
+		}
+	}
+}
+
+func watchRelevant(ev fsnotify.Event) bool {
+	if ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename|fsnotify.Remove) == 0 {
+		return false
+	}
+	name := filepath.Base(ev.Name)
+	return exportRe.MatchString(name) || pageRe.MatchString(name) || name == jsonExportFile
+}
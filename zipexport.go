@@ -0,0 +1,93 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const zipEntrySep = "!/"
+
+func isZipExport(p string) bool {
+	return hasZipExt(p) && fileExists(p)
+}
+
+func hasZipExt(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".zip")
+}
+
+func zipSource(archive string, f *zip.File) pageSource {
+	return pageSource{
+		key:     archive + zipEntrySep + f.Name,
+		name:    archive + zipEntrySep + f.Name,
+		size:    int64(f.UncompressedSize64),
+		modTime: f.Modified,
+		hash:    func(context.Context) (string, error) { return "crc32:" + strconv.FormatUint(uint64(f.CRC32), 16), nil },
+		open:    func() (io.ReadCloser, error) { return f.Open() },
+	}
+}
+
+func zipExportSources(archive string, zr *zip.Reader) ([]pageSource, error) {
+	type page struct {
+		f   *zip.File
+		num int
+	}
+	pagesByDir := make(map[string][]page)
+	jsonByDir := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		dir, name := path.Split(f.Name)
+		if name == jsonExportFile {
+			jsonByDir[dir] = f
+			continue
+		}
+		m := pageRe.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		num := 1
+		if m[1] != "" {
+			num, _ = strconv.Atoi(m[1])
+		}
+		pagesByDir[dir] = append(pagesByDir[dir], page{f, num})
+	}
+
+	shallowest := func(dirs []string) string {
+		sort.Slice(dirs, func(i, j int) bool {
+			if a, b := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/"); a != b {
+				return a < b
+			}
+			return dirs[i] < dirs[j]
+		})
+		return dirs[0]
+	}
+	if len(jsonByDir) > 0 {
+		dirs := make([]string, 0, len(jsonByDir))
+		for d := range jsonByDir {
+			dirs = append(dirs, d)
+		}
+		return []pageSource{zipSource(archive, jsonByDir[shallowest(dirs)])}, nil
+	}
+	if len(pagesByDir) == 0 {
+		return nil, fmt.Errorf("в архиве %s нет messages.html или %s: %w", archive, jsonExportFile, os.ErrNotExist)
+	}
+	dirs := make([]string, 0, len(pagesByDir))
+	for d := range pagesByDir {
+		dirs = append(dirs, d)
+	}
+	pages := pagesByDir[shallowest(dirs)]
+	sort.Slice(pages, func(i, j int) bool { return pages[i].num < pages[j].num })
+	sources := make([]pageSource, len(pages))
+	for i, p := range pages {
+		sources[i] = zipSource(archive, p.f)
+	}
+	return sources, nil
+}